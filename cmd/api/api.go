@@ -2,47 +2,126 @@
 package api
 
 import (
-	"database/sql"
+	"context"
 	"log"
 	"net/http"
+	"slices"
+	"time"
 
+	"github.com/davidado/go-api-reference/config"
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/middleware"
+	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
 	"github.com/davidado/go-api-reference/service/cart"
+	"github.com/davidado/go-api-reference/service/category"
+	"github.com/davidado/go-api-reference/service/inventory"
 	"github.com/davidado/go-api-reference/service/order"
+	"github.com/davidado/go-api-reference/service/payment"
 	"github.com/davidado/go-api-reference/service/product"
+	"github.com/davidado/go-api-reference/service/promotion"
+	"github.com/davidado/go-api-reference/service/returns"
+	"github.com/davidado/go-api-reference/service/shipping"
 	"github.com/davidado/go-api-reference/service/user"
+	"github.com/davidado/go-api-reference/types"
 	"github.com/gorilla/mux"
 )
 
+// apiPrefix is the path prefix of every service route.
+const apiPrefix = "/api/v1"
+
 // Server is the main struct for the API server
 type Server struct {
-	addr string
-	db   *sql.DB
+	addr     string
+	db       *db.DB
+	payments types.PaymentProvider
+	taxes    types.TaxCalculator
+}
+
+// NewServer creates a new APIServer instance. The stores use the backend
+// db was opened with; checkout charges orders through payments and works
+// out their tax with taxes.
+func NewServer(addr string, db *db.DB, payments types.PaymentProvider, taxes types.TaxCalculator) *Server {
+	return &Server{addr: addr, db: db, payments: payments, taxes: taxes}
 }
 
-// NewServer creates a new APIServer instance
-func NewServer(addr string, db *sql.DB) *Server {
-	return &Server{addr: addr, db: db}
+// service is implemented by every service handler.
+type service interface {
+	RegisterRoutes(router *mux.Router)
+	Operations() []openapi.Operation
 }
 
-// Run starts the API server
+// Run starts the API server and the sweeper releasing expired stock
+// reservations
 func (s *Server) Run() error {
+	sweeper := inventory.NewSweeper(inventory.NewStore(s.db), order.NewStore(s.db), payment.NewStore(s.db), s.payments)
+	go sweeper.Run(context.Background(), time.Second*time.Duration(config.Envs.ReservationSweepInSeconds))
+
+	log.Println("Listening on", s.addr)
+
+	return http.ListenAndServe(s.addr, s.routes())
+}
+
+// routes builds the router with every service and the API docs.
+func (s *Server) routes() *mux.Router {
 	router := mux.NewRouter()
-	subrouter := router.PathPrefix("/api/v1").Subrouter()
+	router.Use(middleware.RequestID)
+	// The streamed lists lift the deadline with middleware.NoDeadline, so
+	// long exports aren't cut off.
+	router.Use(middleware.Deadline(time.Second * time.Duration(config.Envs.DBTimeoutInSeconds)))
+	subrouter := router.PathPrefix(apiPrefix).Subrouter()
 
-	userStore := user.NewStore(s.db)
-	userHandler := user.NewHandler(userStore)
-	userHandler.RegisterRoutes(subrouter)
+	var ops []openapi.Operation
+	for _, svc := range s.services() {
+		svc.RegisterRoutes(subrouter)
+		ops = append(ops, svc.Operations()...)
+	}
 
-	productStore := product.NewStore(s.db)
-	productHandler := product.NewHandler(productStore)
-	productHandler.RegisterRoutes(subrouter)
+	doc := openapi.Build(openapi.Info{Title: "E-commerce API", Version: "1.0.0"}, apiPrefix, netjson.Problem{}, ops)
+	router.Handle("/openapi.json", openapi.SpecHandler(doc)).Methods(http.MethodGet)
+	router.Handle("/docs", openapi.DocsHandler("/openapi.json")).Methods(http.MethodGet)
 
-	orderStore := order.NewStore(s.db)
+	return router
+}
 
-	cartHandler := cart.NewHandler(orderStore, productStore, userStore)
-	cartHandler.RegisterRoutes(subrouter)
+// Tables lists every table and column the stores expect the migrations to
+// create.
+func Tables() []db.Table {
+	return slices.Concat(user.Tables(), product.Tables(), order.Tables(), payment.Tables(), returns.Tables(), promotion.Tables(), shipping.Tables(), inventory.Tables(), category.Tables())
+}
 
-	log.Println("Listening on", s.addr)
+func (s *Server) services() []service {
+	userStore := user.NewStore(s.db)
+	productStore := product.NewStore(s.db)
+	orderStore := order.NewStore(s.db)
+	paymentStore := payment.NewStore(s.db)
+	returnStore := returns.NewStore(s.db)
+	promotionStore := promotion.NewStore(s.db)
+	shippingStore := shipping.NewStore(s.db)
+	inventoryStore := inventory.NewStore(s.db)
+	categoryStore := category.NewStore(s.db)
 
-	return http.ListenAndServe(s.addr, router)
+	return []service{
+		user.NewHandler(userStore),
+		product.NewHandler(productStore, productStore, categoryStore, userStore),
+		order.NewHandler(orderStore, userStore),
+		cart.NewHandler(cart.Deps{
+			OrderStore:     orderStore,
+			ProductStore:   productStore,
+			VariantStore:   productStore,
+			UserStore:      userStore,
+			PaymentStore:   paymentStore,
+			PromotionStore: promotionStore,
+			CategoryStore:  categoryStore,
+			ShippingStore:  shippingStore,
+			InventoryStore: inventoryStore,
+			Payments:       s.payments,
+			Taxes:          s.taxes,
+		}),
+		payment.NewHandler(paymentStore, orderStore, inventoryStore, config.Envs.PaymentWebhookSecret),
+		returns.NewHandler(returnStore, orderStore, paymentStore, userStore, s.payments),
+		promotion.NewHandler(promotionStore, userStore),
+		shipping.NewHandler(shippingStore, userStore),
+		category.NewHandler(categoryStore, userStore),
+	}
 }
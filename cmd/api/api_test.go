@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidado/go-api-reference/openapi"
+	"github.com/davidado/go-api-reference/service/payment"
+	"github.com/davidado/go-api-reference/service/tax"
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	router := NewServer(":0", nil, payment.NewFakeProvider(), tax.NewTable(nil, false)).routes()
+
+	req, err := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var doc openapi.Document
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should describe every registered route", func(t *testing.T) {
+		err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				return nil
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				return nil
+			}
+
+			rel, ok := strings.CutPrefix(path, apiPrefix)
+			if !ok {
+				return nil
+			}
+			for _, method := range methods {
+				if !doc.Has(method, rel) {
+					t.Errorf("route %s %s has no OpenAPI operation", method, path)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should map validate tags to schema constraints", func(t *testing.T) {
+		payload := doc.Components.Schemas["RegisterUserPayload"]
+		if payload == nil {
+			t.Fatal("missing RegisterUserPayload schema")
+		}
+
+		if len(payload.Required) != 4 {
+			t.Errorf("expected 4 required fields, got %v", payload.Required)
+		}
+		if f := payload.Properties["email"].Format; f != "email" {
+			t.Errorf("expected email format, got %q", f)
+		}
+		password := payload.Properties["password"]
+		if password.MinLength == nil || *password.MinLength != 3 || password.MaxLength == nil || *password.MaxLength != 130 {
+			t.Errorf("unexpected password constraints %+v", password)
+		}
+	})
+}
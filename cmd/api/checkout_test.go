@@ -0,0 +1,735 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/davidado/go-api-reference/config"
+	"github.com/davidado/go-api-reference/mysqltest"
+	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/service/inventory"
+	"github.com/davidado/go-api-reference/service/order"
+	"github.com/davidado/go-api-reference/service/payment"
+	"github.com/davidado/go-api-reference/service/product"
+	"github.com/davidado/go-api-reference/service/promotion"
+	"github.com/davidado/go-api-reference/service/shipping"
+	"github.com/davidado/go-api-reference/service/tax"
+	"github.com/davidado/go-api-reference/types"
+	"github.com/gorilla/mux"
+)
+
+func TestCheckout(t *testing.T) {
+	secret := config.Envs.PaymentWebhookSecret
+	config.Envs.PaymentWebhookSecret = "secret"
+	t.Cleanup(func() { config.Envs.PaymentWebhookSecret = secret })
+
+	// Oregon has no sales tax; the test rates only tax California.
+	oregon := types.Address{Line1: "1 Main St", City: "Portland", Region: "OR", PostalCode: "97201", Country: "US"}
+	conn := mysqltest.New(t)
+	payments := payment.NewFakeProvider()
+	taxes := tax.NewTable([]tax.Rate{{Country: "US", Region: "CA", Rate: 0.1}}, false)
+	router := NewServer(":0", conn, payments, taxes).routes()
+
+	productStore := product.NewStore(conn)
+	mugID, err := productStore.CreateProduct(context.Background(), types.Product{Name: "Mug", Description: "A mug", Image: "mug.jpg", Price: 9.5, Quantity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Picking up is free, so it leaves the totals alone.
+	shippingStore := shipping.NewStore(conn)
+	pickupID, err := shippingStore.CreateShippingMethod(context.Background(), types.ShippingMethod{Name: "Pickup", Kind: types.ShippingPickup})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := registerAndLogin(t, router, "buyer@example.com")
+
+	t.Run("should create a paid order and take the items from stock", func(t *testing.T) {
+		rr := do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: mugID, Quantity: 2}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var res types.CheckoutResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.OrderID == 0 || res.TotalPrice != 19 || res.Status != types.OrderPaid {
+			t.Errorf("unexpected checkout response %+v", res)
+		}
+
+		ps, err := productStore.GetProductsByID(context.Background(), []int{mugID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps[0].Quantity != 1 {
+			t.Errorf("expected 1 mug left in stock, got %d", ps[0].Quantity)
+		}
+
+		rr = do(t, router, http.MethodGet, "/orders", token, nil)
+		var orders []types.Order
+		if err := json.NewDecoder(rr.Body).Decode(&orders); err != nil {
+			t.Fatal(err)
+		}
+		if len(orders) != 1 || orders[0].ID != res.OrderID || orders[0].Total != 19 || orders[0].Status != types.OrderPaid {
+			t.Errorf("unexpected orders %+v", orders)
+		}
+	})
+
+	t.Run("should refuse items that are out of stock", func(t *testing.T) {
+		rr := do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: mugID, Quantity: 5}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusConflict {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body)
+		}
+
+		var p netjson.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Code != "out_of_stock" {
+			t.Errorf("expected code out_of_stock, got %q", p.Code)
+		}
+	})
+
+	t.Run("should refuse declined cards without taking stock", func(t *testing.T) {
+		rr := do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: mugID, Quantity: 1}},
+			PaymentMethod:    payment.CardDecline,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusPaymentRequired {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusPaymentRequired, rr.Code, rr.Body)
+		}
+
+		ps, err := productStore.GetProductsByID(context.Background(), []int{mugID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps[0].Quantity != 1 {
+			t.Errorf("expected 1 mug left in stock, got %d", ps[0].Quantity)
+		}
+	})
+
+	t.Run("should settle 3-D Secure payments from the webhook", func(t *testing.T) {
+		rr := do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: mugID, Quantity: 1}},
+			PaymentMethod:    payment.Card3DS,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var res types.CheckoutResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != types.OrderPending || res.NextActionURL == "" {
+			t.Fatalf("expected a pending order with a next action, got %+v", res)
+		}
+
+		// The last mug is held for the pending order.
+		ps, err := productStore.GetProductsByID(context.Background(), []int{mugID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps[0].Quantity != 1 || ps[0].Available != 0 {
+			t.Errorf("expected the last mug to be held, got %d in stock and %d available", ps[0].Quantity, ps[0].Available)
+		}
+
+		// The fake provider ends its next action URLs with the reference.
+		event, err := payments.Confirm(path.Base(res.NextActionURL), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rr := postWebhook(router, body, "t=1,v1=00"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status code %d for a bad signature, got %d", http.StatusUnauthorized, rr.Code)
+		}
+		if rr := postWebhook(router, body, payment.Sign([]byte("secret"), body, time.Now())); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		rr = do(t, router, http.MethodGet, "/orders", token, nil)
+		var orders []types.Order
+		if err := json.NewDecoder(rr.Body).Decode(&orders); err != nil {
+			t.Fatal(err)
+		}
+		if len(orders) != 2 || orders[0].ID != res.OrderID || orders[0].Status != types.OrderPaid {
+			t.Errorf("expected the order to be paid, got %+v", orders)
+		}
+
+		ps, err = productStore.GetProductsByID(context.Background(), []int{mugID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps[0].Quantity != 0 || ps[0].Available != 0 {
+			t.Errorf("expected the held mug to be taken from stock, got %d in stock and %d available", ps[0].Quantity, ps[0].Available)
+		}
+	})
+
+	t.Run("should release the stock of orders that aren't paid in time", func(t *testing.T) {
+		ctx := context.Background()
+		saucerID, err := productStore.CreateProduct(ctx, types.Product{Name: "Saucer", Description: "A saucer", Image: "saucer.jpg", Price: 4, Quantity: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: saucerID, Quantity: 1}},
+			PaymentMethod:    payment.Card3DS,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var res types.CheckoutResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		rr = do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: saucerID, Quantity: 1}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusConflict {
+			t.Fatalf("expected status code %d for a held saucer, got %d: %s", http.StatusConflict, rr.Code, rr.Body)
+		}
+
+		orderStore := order.NewStore(conn)
+		sweeper := inventory.NewSweeper(inventory.NewStore(conn), orderStore, payment.NewStore(conn), payments)
+		if err := sweeper.Sweep(ctx, time.Now().Add(time.Duration(config.Envs.ReservationTTLInSeconds+1)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+
+		o, err := orderStore.GetOrderByID(ctx, res.OrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Status != types.OrderFailed {
+			t.Errorf("expected the expired order to fail, got %s", o.Status)
+		}
+		ps, err := productStore.GetProductsByID(ctx, []int{saucerID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps[0].Quantity != 1 || ps[0].Available != 1 {
+			t.Errorf("expected the saucer back on sale, got %d in stock and %d available", ps[0].Quantity, ps[0].Available)
+		}
+
+		// The authorization was voided, so confirming it late doesn't
+		// capture it.
+		if _, err := payments.Confirm(path.Base(res.NextActionURL), true); err == nil {
+			t.Error("expected the voided payment not to be confirmed")
+		}
+	})
+
+	t.Run("should not fail expired orders whose payment was captured", func(t *testing.T) {
+		ctx := context.Background()
+		bowlID, err := productStore.CreateProduct(ctx, types.Product{Name: "Bowl", Description: "A bowl", Image: "bowl.jpg", Price: 8, Quantity: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A checkout that captured the payment but failed before the
+		// order was marked paid.
+		orderStore := order.NewStore(conn)
+		orderID, err := orderStore.CreateOrder(ctx, types.Order{UserID: 1, Total: 8, Status: types.OrderPending, Address: oregon.String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		inventoryStore := inventory.NewStore(conn)
+		if err := inventoryStore.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: bowlID, Quantity: 1}}, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		auth, err := payments.Authorize(ctx, types.PaymentRequest{Amount: 8, Method: payment.CardSuccess})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := payments.Capture(ctx, auth.Reference); err != nil {
+			t.Fatal(err)
+		}
+		paymentStore := payment.NewStore(conn)
+		if _, err := paymentStore.CreatePayment(ctx, types.Payment{OrderID: orderID, Provider: payments.Name(), Reference: auth.Reference, Status: types.PaymentCaptured, Amount: 8}); err != nil {
+			t.Fatal(err)
+		}
+
+		sweeper := inventory.NewSweeper(inventoryStore, orderStore, paymentStore, payments)
+		if err := sweeper.Sweep(ctx, time.Now().Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+
+		o, err := orderStore.GetOrderByID(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Status != types.OrderPending {
+			t.Errorf("expected the paid for order to be left for review, got %s", o.Status)
+		}
+		ps, err := paymentStore.GetPaymentsByOrder(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ps) != 1 || ps[0].Status != types.PaymentCaptured {
+			t.Errorf("expected the payment to stay captured, got %+v", ps)
+		}
+	})
+
+	t.Run("should take coupons off the total and count their uses", func(t *testing.T) {
+		plateID, err := productStore.CreateProduct(context.Background(), types.Product{Name: "Plate", Description: "A plate", Image: "plate.jpg", Price: 20, Quantity: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = promotion.NewStore(conn).CreatePromotion(context.Background(), types.Promotion{Name: "Launch", Code: "LAUNCH", Kind: types.PromotionPercent, Value: 25, MaxUses: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cart := types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: plateID, Quantity: 2}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+			CouponCode:       "LAUNCH",
+		}
+
+		rr := do(t, router, http.MethodPost, "/cart/checkout", token, cart)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var res types.CheckoutResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.TotalPrice != 30 || len(res.Discounts) != 1 || res.Discounts[0].Code != "LAUNCH" || res.Discounts[0].Amount != 10 {
+			t.Errorf("unexpected checkout response %+v", res)
+		}
+
+		rr = do(t, router, http.MethodPost, "/cart/checkout", token, cart)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status code %d once the coupon is used up, got %d: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body)
+		}
+		var p netjson.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Code != "coupon_not_applicable" {
+			t.Errorf("expected code coupon_not_applicable, got %q", p.Code)
+		}
+	})
+
+	t.Run("should add the tax of the shipping address", func(t *testing.T) {
+		cupID, err := productStore.CreateProduct(context.Background(), types.Product{Name: "Cup", Description: "A cup", Image: "cup.jpg", Price: 12.5, Quantity: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: cupID, Quantity: 2}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  types.Address{Line1: "1 Market St", City: "San Francisco", Region: "CA", PostalCode: "94105", Country: "US"},
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var res types.CheckoutResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.TotalPrice != 27.5 || res.Tax.Inclusive || res.Tax.Total != 2.5 || len(res.Tax.Lines) != 1 || res.Tax.Lines[0].Rate != 0.1 {
+			t.Errorf("unexpected checkout response %+v", res)
+		}
+
+		items, err := order.NewStore(conn).GetOrderItems(context.Background(), res.OrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 || items[0].TaxRate != 0.1 || items[0].Tax != 2.5 {
+			t.Errorf("expected the tax on the order item, got %+v", items)
+		}
+	})
+
+	t.Run("should quote and charge shipping", func(t *testing.T) {
+		ctx := context.Background()
+		kettleID, err := productStore.CreateProduct(ctx, types.Product{Name: "Kettle", Description: "A kettle", Image: "kettle.jpg", Price: 40, Quantity: 5, Weight: 1.2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		standardID, err := shippingStore.CreateShippingMethod(ctx, types.ShippingMethod{Name: "Standard", Kind: types.ShippingFlat, Price: 5, FreeOver: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expressID, err := shippingStore.CreateShippingMethod(ctx, types.ShippingMethod{Name: "Express", Kind: types.ShippingWeight, Price: 8, PricePerKg: 2, Countries: []string{"CA"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := do(t, router, http.MethodGet, fmt.Sprintf("/cart/shipping-options?items=%d:2&country=ca", kettleID), "", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var options []types.ShippingOption
+		if err := json.NewDecoder(rr.Body).Decode(&options); err != nil {
+			t.Fatal(err)
+		}
+		want := []types.ShippingOption{
+			{MethodID: pickupID, Name: "Pickup", Kind: types.ShippingPickup, Cost: 0},
+			{MethodID: standardID, Name: "Standard", Kind: types.ShippingFlat, Cost: 5},
+			{MethodID: expressID, Name: "Express", Kind: types.ShippingWeight, Cost: 14},
+		}
+		if !slices.Equal(options, want) {
+			t.Errorf("expected options %+v, got %+v", want, options)
+		}
+
+		rr = do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: kettleID, Quantity: 1}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: expressID,
+		})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for a method that doesn't deliver to the US, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		rr = do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: kettleID, Quantity: 1}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: standardID,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var res types.CheckoutResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.TotalPrice != 45 || res.Shipping.MethodID != standardID || res.Shipping.Cost != 5 {
+			t.Errorf("unexpected checkout response %+v", res)
+		}
+
+		o, err := order.NewStore(conn).GetOrderByID(ctx, res.OrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.ShippingMethodID != standardID || o.ShippingMethod != "Standard" || o.ShippingCost != 5 {
+			t.Errorf("expected the shipping line on the order, got %+v", o)
+		}
+	})
+
+	t.Run("should quote a checkout without placing an order", func(t *testing.T) {
+		ctx := context.Background()
+		bowlID, err := productStore.CreateProduct(ctx, types.Product{Name: "Bowl", Description: "A bowl", Image: "bowl.jpg", Price: 12, Quantity: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		california := types.Address{Line1: "1 Market St", City: "San Francisco", Region: "CA", PostalCode: "94105", Country: "US"}
+		// countOrders counts the buyer's orders.
+		countOrders := func() int {
+			var orders []types.Order
+			if err := json.NewDecoder(do(t, router, http.MethodGet, "/orders", token, nil).Body).Decode(&orders); err != nil {
+				t.Fatal(err)
+			}
+			return len(orders)
+		}
+		orders := countOrders()
+
+		rr := do(t, router, http.MethodPost, "/cart/quote", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: bowlID, Quantity: 3}, {ProductID: 9999, Quantity: 1}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  california,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var q types.CartQuote
+		if err := json.NewDecoder(rr.Body).Decode(&q); err != nil {
+			t.Fatal(err)
+		}
+		if q.CanCheckout || q.Subtotal != 36 || q.Tax.Total != 3.6 || q.Total != 39.6 || len(q.Lines) != 2 {
+			t.Fatalf("unexpected quote %+v", q)
+		}
+		if l := q.Lines[0]; l.Name != "Bowl" || l.Price != 12 || l.Total != 36 || l.Tax != 3.6 || l.Available != 2 || l.Problem == nil || l.Problem.Code != "out_of_stock" {
+			t.Errorf("expected the bowls to be out of stock, got %+v", l)
+		}
+		if l := q.Lines[1]; l.Price != 0 || l.Problem == nil || l.Problem.Code != "not_found" {
+			t.Errorf("expected an unknown product, got %+v", l)
+		}
+
+		payload := types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: bowlID, Quantity: 2}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  california,
+			ShippingMethodID: pickupID,
+		}
+		rr = do(t, router, http.MethodPost, "/cart/quote", token, payload)
+		q = types.CartQuote{}
+		if err := json.NewDecoder(rr.Body).Decode(&q); err != nil {
+			t.Fatal(err)
+		}
+		if !q.CanCheckout || q.Total != 26.4 || q.Lines[0].Problem != nil {
+			t.Errorf("unexpected quote %+v", q)
+		}
+
+		ps, err := productStore.GetProductsByID(ctx, []int{bowlID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after := countOrders(); after != orders || ps[0].Available != 2 {
+			t.Errorf("expected quotes to leave orders and stock alone, got %d orders and %d bowls available", after, ps[0].Available)
+		}
+
+		rr = do(t, router, http.MethodPost, "/cart/checkout", token, payload)
+		var res types.CheckoutResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.TotalPrice != q.Total {
+			t.Errorf("expected checkout to charge the quoted %v, got %+v", q.Total, res)
+		}
+	})
+
+	t.Run("should merge duplicate lines and report every refused item", func(t *testing.T) {
+		ctx := context.Background()
+		spoonID, err := productStore.CreateProduct(ctx, types.Product{Name: "Spoon", Description: "A spoon", Image: "spoon.jpg", Price: 2, Quantity: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: spoonID, Quantity: 2}, {ProductID: 9999, Quantity: 1}, {ProductID: spoonID, Quantity: 1}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusNotFound, rr.Code, rr.Body)
+		}
+		var p netjson.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		want := []netjson.ItemProblem{
+			{ProductID: spoonID, Code: "out_of_stock", Detail: fmt.Sprintf("product %d is out of stock", spoonID)},
+			{ProductID: 9999, Code: "not_found", Detail: "product 9999 is not available in the store, please refresh your cart"},
+		}
+		if !slices.Equal(p.Items, want) {
+			t.Errorf("expected item problems %+v, got %+v", want, p.Items)
+		}
+
+		rr = do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: spoonID, Quantity: 1}, {ProductID: spoonID, Quantity: 1}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var res types.CheckoutResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		items, err := order.NewStore(conn).GetOrderItems(ctx, res.OrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.TotalPrice != 4 || len(items) != 1 || items[0].Quantity != 2 {
+			t.Errorf("expected a single line of 2 spoons, got %+v and %+v", res, items)
+		}
+	})
+
+	t.Run("should sell variants at their price and from their stock", func(t *testing.T) {
+		ctx := context.Background()
+		hoodieID, err := productStore.CreateProduct(ctx, types.Product{Name: "Hoodie", Description: "A hoodie", Image: "hoodie.jpg", Price: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+		xl := 35.0
+		smallID, err := productStore.CreateVariant(ctx, types.Variant{ProductID: hoodieID, SKU: "HOODIE-S", Quantity: 2, Options: []types.VariantOption{{Name: "size", Value: "S"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		xlID, err := productStore.CreateVariant(ctx, types.Variant{ProductID: hoodieID, SKU: "HOODIE-XL", Price: &xl, Quantity: 1, Options: []types.VariantOption{{Name: "size", Value: "XL"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: hoodieID, Quantity: 1}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected a product sold in variants to need one, got %d: %s", rr.Code, rr.Body)
+		}
+
+		rr = do(t, router, http.MethodPost, "/cart/quote", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: hoodieID, VariantID: xlID, Quantity: 2}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var q types.CartQuote
+		if err := json.NewDecoder(rr.Body).Decode(&q); err != nil {
+			t.Fatal(err)
+		}
+		if line := q.Lines[0]; q.CanCheckout || line.SKU != "HOODIE-XL" || line.Price != 35 || line.Available != 1 || line.Problem == nil || line.Problem.Code != "out_of_stock" {
+			t.Errorf("expected the XL hoodie out of stock at its own price, got %+v", q)
+		}
+
+		rr = do(t, router, http.MethodPost, "/cart/checkout", token, types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: hoodieID, VariantID: smallID, Quantity: 2}, {ProductID: hoodieID, VariantID: xlID, Quantity: 1}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var res types.CheckoutResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.TotalPrice != 95 {
+			t.Errorf("expected 2 hoodies at 30 and one at 35, got %+v", res)
+		}
+
+		items, err := order.NewStore(conn).GetOrderItems(ctx, res.OrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 2 || items[0].VariantID != smallID || items[0].Price != 30 || items[1].VariantID != xlID || items[1].Price != 35 {
+			t.Errorf("expected a line per variant, got %+v", items)
+		}
+
+		variants, err := productStore.GetVariantsByProduct(ctx, []int{hoodieID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range variants {
+			if v.Quantity != 0 || v.Available != 0 {
+				t.Errorf("expected variant %d sold out, got %+v", v.ID, v)
+			}
+		}
+
+		rr = do(t, router, http.MethodGet, fmt.Sprintf("/products/%d", hoodieID), "", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var detail types.ProductDetail
+		if err := json.NewDecoder(rr.Body).Decode(&detail); err != nil {
+			t.Fatal(err)
+		}
+		if len(detail.Options) != 1 || len(detail.Options[0].Values) != 2 || len(detail.Variants) != 2 {
+			t.Errorf("unexpected product detail %+v", detail)
+		}
+	})
+
+	t.Run("should require a token", func(t *testing.T) {
+		rr := do(t, router, http.MethodPost, "/cart/checkout", "", types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: mugID, Quantity: 1}},
+			PaymentMethod:    payment.CardSuccess,
+			ShippingAddress:  oregon,
+			ShippingMethodID: pickupID,
+		})
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
+
+// registerAndLogin registers a user and returns its token.
+func registerAndLogin(t *testing.T, router *mux.Router, email string) string {
+	t.Helper()
+
+	rr := do(t, router, http.MethodPost, "/register", "", types.RegisterUserPayload{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Email:     email,
+		Password:  "password",
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("register: expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+	}
+
+	rr = do(t, router, http.MethodPost, "/login", "", types.LoginUserPayload{Email: email, Password: "password"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("login: expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+
+	var res types.LoginResponse
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Token
+}
+
+// postWebhook sends a payment webhook with the given signature.
+func postWebhook(router *mux.Router, body []byte, signature string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, apiPrefix+"/webhooks/payments", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(payment.SignatureHeader, signature)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+// do sends a JSON request to a path under the API prefix.
+func do(t *testing.T, router *mux.Router, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, apiPrefix+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidado/go-api-reference/cmd/migrate/migrations"
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/mysqltest"
+)
+
+// TestMigrationsMatchStores checks that the migrations of every backend
+// create the tables and columns the stores query.
+func TestMigrationsMatchStores(t *testing.T) {
+	backends := map[string]func(t *testing.T) *db.DB{
+		db.MySQL: func(t *testing.T) *db.DB { return mysqltest.New(t) },
+		db.SQLite: func(t *testing.T) *db.DB {
+			sqlDB, err := db.NewSQLiteStorage(filepath.Join(t.TempDir(), "ecom.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { sqlDB.Close() })
+
+			dialect, err := db.DialectFor(db.SQLite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn := db.New(sqlDB, dialect)
+			if err := migrations.Up(conn); err != nil {
+				t.Fatal(err)
+			}
+			return conn
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			drifts, err := open(t).CheckSchema(context.Background(), Tables())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range drifts {
+				t.Error(d)
+			}
+		})
+	}
+}
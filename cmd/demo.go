@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/service/product"
+	"github.com/davidado/go-api-reference/service/shipping"
+	"github.com/davidado/go-api-reference/service/user"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// demoUser can log in to a seeded database with the password "demo1234".
+var demoUser = types.User{
+	FirstName: "Demo",
+	LastName:  "User",
+	Email:     "demo@example.com",
+	Password:  "demo1234",
+}
+
+var demoProducts = []types.Product{
+	{Name: "Espresso Cup", Description: "Porcelain cup, 90 ml.", Image: "espresso-cup.jpg", Price: 8.5, Quantity: 120, Weight: 0.15, Length: 8, Width: 8, Height: 6},
+	{Name: "Pour-Over Kettle", Description: "Gooseneck kettle, 1 l.", Image: "kettle.jpg", Price: 45, Quantity: 30, Weight: 0.9, Length: 30, Width: 16, Height: 20},
+	{Name: "Burr Grinder", Description: "Conical burr grinder with 40 settings.", Image: "grinder.jpg", Price: 129.99, Quantity: 15, Weight: 2.4, Length: 25, Width: 15, Height: 35},
+	{Name: "Coffee Beans", Description: "Single-origin, 250 g.", Image: "beans.jpg", Price: 14.25, Quantity: 200, Weight: 0.25, Length: 18, Width: 10, Height: 6},
+	{Name: "Paper Filters", Description: "Pack of 100.", Image: "filters.jpg", Price: 5, Quantity: 500, Weight: 0.2, Length: 16, Width: 12, Height: 4},
+}
+
+var demoShippingMethods = []types.ShippingMethod{
+	{Name: "Standard", Kind: types.ShippingFlat, Price: 4.95, FreeOver: 50},
+	{Name: "Express", Kind: types.ShippingWeight, Price: 9, PricePerKg: 2.5, MaxWeight: 30},
+	{Name: "Pick up in store", Kind: types.ShippingPickup, Countries: []string{"US"}},
+}
+
+// seedDemoData adds a demo user, catalog and shipping methods to a
+// database without products. It does nothing if products already exist.
+func seedDemoData(ctx context.Context, conn *db.DB) error {
+	productStore := product.NewStore(conn)
+	shippingStore := shipping.NewStore(conn)
+	userStore := user.NewStore(conn)
+
+	ps, err := productStore.GetProducts(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ps) > 0 {
+		log.Println("DB: Demo data skipped, products already exist")
+		return nil
+	}
+
+	for _, p := range demoProducts {
+		if _, err := productStore.CreateProduct(ctx, p); err != nil {
+			return fmt.Errorf("seed product %q: %w", p.Name, err)
+		}
+	}
+
+	for _, m := range demoShippingMethods {
+		if _, err := shippingStore.CreateShippingMethod(ctx, m); err != nil {
+			return fmt.Errorf("seed shipping method %q: %w", m.Name, err)
+		}
+	}
+
+	u := demoUser
+	u.Password, err = auth.HashPassword(demoUser.Password)
+	if err != nil {
+		return err
+	}
+	if err := userStore.CreateUser(ctx, u); err != nil && !errors.Is(err, types.ErrConflict) {
+		return fmt.Errorf("seed user: %w", err)
+	}
+
+	log.Printf("DB: Demo data loaded, log in as %s / %s", demoUser.Email, demoUser.Password)
+	return nil
+}
@@ -2,41 +2,124 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 
 	"github.com/davidado/go-api-reference/cmd/api"
+	"github.com/davidado/go-api-reference/cmd/migrate/migrations"
 	"github.com/davidado/go-api-reference/config"
 	"github.com/davidado/go-api-reference/db"
-	"github.com/go-sql-driver/mysql"
+	"github.com/davidado/go-api-reference/service/payment"
+	"github.com/davidado/go-api-reference/service/tax"
 )
 
 func main() {
-	db, err := db.NewMySQLStorage(mysql.Config{
-		User:                 config.Envs.DBUser,
-		Passwd:               config.Envs.DBPassword,
-		Addr:                 config.Envs.DBAddress,
-		DBName:               config.Envs.DBName,
-		Net:                  "tcp",
-		AllowNativePasswords: true,
-		ParseTime:            true,
-	})
+	dbURL := flag.String("db", "", "database URL overriding the DB_* settings, e.g. sqlite://ecom.db")
+	seed := flag.Bool("seed", false, "load demo data into an empty database")
+	flag.Parse()
+
+	cfg := config.Envs
+	if *dbURL != "" {
+		if err := cfg.SetDatabaseURL(*dbURL); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	db, err := db.Open(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	initStorage(db)
+	initStorage(db, cfg.SchemaCheck, *seed)
+
+	if err := checkPayments(cfg); err != nil {
+		log.Fatal(err)
+	}
+	payments, err := payment.NewProvider(cfg.PaymentProvider)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	server := api.NewServer(":8080", db)
+	rates, err := tax.ParseRates(cfg.TaxRates)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := api.NewServer(":8080", db, payments, tax.NewTable(rates, cfg.TaxInclusive))
 	if err := server.Run(); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func initStorage(db *sql.DB) {
-	err := db.Ping()
+func initStorage(conn *db.DB, schemaCheck string, seed bool) {
+	err := conn.Ping()
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Println("DB: Successfully connected!")
+	log.Printf("DB: Successfully connected to %s!", conn.Dialect.Name())
+
+	// A SQLite database is private to this binary, so keep its schema
+	// current instead of requiring a separate migrate step.
+	if conn.Dialect.Name() == db.SQLite {
+		if err := migrations.Up(conn); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("DB: Migrations applied")
+	}
+
+	if err := checkSchema(context.Background(), conn, schemaCheck); err != nil {
+		log.Fatal(err)
+	}
+
+	if seed {
+		if err := seedDemoData(db.WithPrimary(context.Background()), conn); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// checkPayments refuses to take orders without a real payment provider and
+// webhook secret, unless DEV_MODE is set: the fake provider charges no one.
+func checkPayments(cfg config.Config) error {
+	if cfg.PaymentProvider == "" {
+		return errors.New("PAYMENT_PROVIDER is not set")
+	}
+	if cfg.PaymentProvider == payment.FakeName && !cfg.DevMode {
+		return fmt.Errorf("the %s payment provider charges no one, set DEV_MODE=true to use it", payment.FakeName)
+	}
+	if cfg.PaymentWebhookSecret == "" {
+		return errors.New("PAYMENT_WEBHOOK_SECRET is not set")
+	}
+	return nil
+}
+
+// checkSchema compares the database with the tables the stores expect. Every
+// drift is logged; in strict mode a drift that breaks the stores' queries
+// also refuses to start.
+func checkSchema(ctx context.Context, conn *db.DB, mode string) error {
+	switch mode {
+	case "off":
+		return nil
+	case "strict", "warn":
+	default:
+		return fmt.Errorf("invalid SCHEMA_CHECK %q, want strict, warn or off", mode)
+	}
+
+	drifts, err := conn.CheckSchema(ctx, api.Tables())
+	if err != nil {
+		return err
+	}
+
+	breaking := false
+	for _, d := range drifts {
+		log.Printf("DB: schema drift: %s", d)
+		breaking = breaking || d.Breaking()
+	}
+	if breaking && mode == "strict" {
+		return errors.New("DB: the schema doesn't match the stores, run the migrations or set SCHEMA_CHECK=warn")
+	}
+	return nil
 }
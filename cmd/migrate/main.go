@@ -1,61 +1,365 @@
 // Package main : Database migration
+//
+// Usage:
+//
+//	migrate [--db URL] <command> [arguments]
+//
+// The migrations are embedded in the binary, so it runs from any directory.
+// See usage for the commands.
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/davidado/go-api-reference/cmd/api"
+	"github.com/davidado/go-api-reference/cmd/migrate/migrations"
 	"github.com/davidado/go-api-reference/config"
 	"github.com/davidado/go-api-reference/db"
-	_ "github.com/go-sql-driver/mysql" // mysql driver
-	mysqlDriver "github.com/go-sql-driver/mysql"
 	"github.com/golang-migrate/migrate/v4"
-	mysqlMigrate "github.com/golang-migrate/migrate/v4/database/mysql"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source"
 )
 
+const usageText = `Usage: migrate [--db URL] <command> [arguments]
+
+Commands:
+  status                show the current version and every migration
+  up [N] [--dry-run]    apply all or the next N pending migrations
+  down [N] [--dry-run]  revert all or the last N applied migrations
+  goto V [--dry-run]    migrate up or down to version V
+  force V               set the version without running migrations, e.g. to
+                        clear a dirty state after a failed migration (-1 for none)
+  create NAME [--dir DIR]
+                        create empty up and down files for every backend
+  drop --confirm        drop every table, including the version table
+  verify                compare the schema with the tables the stores expect
+
+--dry-run prints the SQL that would run instead of applying it.
+`
+
 func main() {
-	cfg := mysqlDriver.Config{
-		User:                 config.Envs.DBUser,
-		Passwd:               config.Envs.DBPassword,
-		Addr:                 config.Envs.DBAddress,
-		DBName:               config.Envs.DBName,
-		Net:                  "tcp",
-		AllowNativePasswords: true,
-		ParseTime:            true,
+	log.SetFlags(0)
+
+	dbURL := flag.String("db", "", "database URL overriding the DB_* settings, e.g. sqlite://ecom.db")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usageText, "\nFlags:\n")
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	db, err := db.NewMySQLStorage(cfg)
-	if err != nil {
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := config.Envs
+	if *dbURL != "" {
+		if err := cfg.SetDatabaseURL(*dbURL); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := run(cfg, flag.Arg(0), flag.Args()[1:]); err != nil {
 		log.Fatal(err)
 	}
+}
+
+// run runs one command.
+func run(cfg config.Config, cmd string, args []string) error {
+	switch cmd {
+	case "create":
+		// create only writes files, so it works without a database.
+		return create(args)
+	case "status", "up", "down", "goto", "force", "drop", "verify":
+	default:
+		return fmt.Errorf("unknown command %q, run migrate --help for usage", cmd)
+	}
+
+	// A migration may hold several statements.
+	cfg.DBMultiStatements = true
 
-	driver, err := mysqlMigrate.WithInstance(db, &mysqlMigrate.Config{})
+	conn, err := db.Open(cfg)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer conn.Close()
 
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://cmd/migrate/migrations",
-		"mysql",
-		driver,
-	)
+	m, err := migrations.New(conn)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	src, err := migrations.Source(conn.Dialect.Name())
+	if err != nil {
+		return err
 	}
 
-	v, d, _ := m.Version()
-	log.Printf("Version: %d, dirty: %v", v, d)
+	switch cmd {
+	case "status":
+		return status(os.Stdout, m, src)
+	case "up", "down", "goto":
+		return migrateTo(m, src, cmd, args)
+	case "force":
+		return force(m, args)
+	case "verify":
+		return verify(os.Stdout, conn)
+	default:
+		return drop(conn, args)
+	}
+}
 
-	cmd := os.Args[len(os.Args)-1]
-	if cmd == "up" {
-		if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal(err)
+// status prints the current version and whether each migration is applied.
+func status(w io.Writer, m *migrate.Migrate, src source.Driver) error {
+	current, dirty, err := currentVersion(m)
+	if err != nil {
+		return err
+	}
+
+	if current == noVersion {
+		fmt.Fprintln(w, "Version: none")
+	} else {
+		fmt.Fprintf(w, "Version: %d, dirty: %v\n", current, dirty)
+	}
+
+	all, err := versions(src)
+	if err != nil {
+		return err
+	}
+	for _, v := range all {
+		r, identifier, err := src.ReadUp(v)
+		if err != nil {
+			return err
 		}
+		r.Close()
+
+		state := "pending"
+		if int(v) <= current {
+			state = "applied"
+		}
+		fmt.Fprintf(w, "%-8s %d %s\n", state, v, identifier)
 	}
-	if cmd == "down" {
-		if err := m.Down(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal(err)
+	return nil
+}
+
+// migrateTo runs up, down and goto. They only differ in how they pick the
+// target version.
+func migrateTo(m *migrate.Migrate, src source.Driver, cmd string, args []string) error {
+	flags := flag.NewFlagSet(cmd, flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of applying it")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 || (cmd == "goto" && len(args) != 1) {
+		return fmt.Errorf("wrong number of arguments for %s, run migrate --help for usage", cmd)
+	}
+
+	current, dirty, err := currentVersion(m)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("version %d is dirty: fix the schema by hand, then run force with the last version that applied cleanly", current)
+	}
+
+	all, err := versions(src)
+	if err != nil {
+		return err
+	}
+
+	var n int
+	if cmd != "goto" && len(args) == 1 {
+		if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of migrations %q", args[0])
+		}
+	}
+
+	var target int
+	switch {
+	case cmd == "goto":
+		v, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil || indexOf(all, int(v)) == noVersion {
+			return fmt.Errorf("unknown version %q", args[0])
+		}
+		target = int(v)
+	case cmd == "up" && n == 0:
+		target = current
+		if len(all) > 0 {
+			target = max(current, int(all[len(all)-1]))
+		}
+	case cmd == "up":
+		target, err = stepTarget(all, current, n)
+	case n == 0:
+		target = noVersion
+	default:
+		target, err = stepTarget(all, current, -n)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		return printPlan(os.Stdout, src, plan(all, current, target))
+	}
+
+	if target == current {
+		log.Println("No change")
+		return nil
+	}
+	if target == noVersion {
+		err = m.Down()
+	} else {
+		err = m.Migrate(uint(target))
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
+	return logVersion(m)
+}
+
+// force sets the version without running any migration.
+func force(m *migrate.Migrate, args []string) error {
+	if len(args) != 1 {
+		return errors.New("force needs a version, run migrate --help for usage")
+	}
+	v, err := strconv.Atoi(args[0])
+	if err != nil || v < noVersion {
+		return fmt.Errorf("invalid version %q", args[0])
+	}
+
+	if err := m.Force(v); err != nil {
+		return err
+	}
+	return logVersion(m)
+}
+
+// drop deletes every table once the caller confirmed it.
+func drop(conn *db.DB, args []string) error {
+	flags := flag.NewFlagSet("drop", flag.ExitOnError)
+	confirm := flags.Bool("confirm", false, "confirm dropping every table")
+	if _, err := parseArgs(flags, args); err != nil {
+		return err
+	}
+	if !*confirm {
+		return errors.New("drop deletes every table and its data, rerun with --confirm")
+	}
+
+	if err := migrations.Drop(context.Background(), conn); err != nil {
+		return err
+	}
+	log.Println("Dropped every table")
+	return nil
+}
+
+// verify prints the drift between the schema and the stores, and fails if
+// it breaks their queries.
+func verify(w io.Writer, conn *db.DB) error {
+	drifts, err := conn.CheckSchema(context.Background(), api.Tables())
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		fmt.Fprintln(w, "Schema matches the stores")
+		return nil
+	}
+
+	breaking := false
+	for _, d := range drifts {
+		fmt.Fprintln(w, d)
+		breaking = breaking || d.Breaking()
+	}
+	if breaking {
+		return errors.New("the schema doesn't match the stores")
+	}
+	return nil
+}
+
+// nonSlug matches the characters replaced in migration names.
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// create writes empty up and down migrations for every backend, sharing one
+// version so the backends stay in step.
+func create(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	dir := flags.String("dir", filepath.Join("cmd", "migrate", "migrations"), "directory holding one migrations directory per backend")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("create needs a name, run migrate --help for usage")
+	}
+
+	name := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(args[0]), "-"), "-")
+	if name == "" {
+		return fmt.Errorf("invalid migration name %q", args[0])
+	}
+	version := time.Now().UTC().Format("20060102150405")
+
+	for _, driver := range []string{db.MySQL, db.Postgres, db.SQLite} {
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(*dir, driver, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+			if err != nil {
+				return err
+			}
+			f.Close()
+			log.Println("Created", path)
+		}
+	}
+	log.Println("Rebuild to embed the new migrations")
+	return nil
+}
+
+// currentVersion returns the applied version, or noVersion before the
+// first migration.
+func currentVersion(m *migrate.Migrate) (int, bool, error) {
+	v, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return noVersion, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return int(v), dirty, nil
+}
+
+func logVersion(m *migrate.Migrate) error {
+	v, dirty, err := currentVersion(m)
+	if err != nil {
+		return err
+	}
+	if v == noVersion {
+		log.Println("Version: none")
+		return nil
+	}
+	log.Printf("Version: %d, dirty: %v", v, dirty)
+	return nil
+}
+
+// parseArgs parses flags that may come before or after the positional
+// arguments, and returns the positional ones.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
 		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
 }
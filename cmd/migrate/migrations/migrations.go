@@ -0,0 +1,119 @@
+// Package migrations : SQL migrations for every database backend
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	mysqlMigrate "github.com/golang-migrate/migrate/v4/database/mysql"
+	pgxMigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	sqliteMigrate "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// FS holds the migrations of every backend, one directory per driver.
+//
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var FS embed.FS
+
+// New creates a migrator for conn that reads the embedded migrations of
+// its backend.
+func New(conn *db.DB) (*migrate.Migrate, error) {
+	name := conn.Dialect.Name()
+
+	var driver database.Driver
+	var err error
+	switch name {
+	case db.MySQL:
+		driver, err = mysqlMigrate.WithInstance(conn.DB, &mysqlMigrate.Config{})
+	case db.Postgres:
+		driver, err = pgxMigrate.WithInstance(conn.DB, &pgxMigrate.Config{})
+	case db.SQLite:
+		driver, err = sqliteMigrate.WithInstance(conn.DB, &sqliteMigrate.Config{})
+	default:
+		return nil, fmt.Errorf("migrations: unsupported driver %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := Source(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewWithInstance("iofs", src, name, driver)
+}
+
+// Source returns the embedded migrations of a backend.
+func Source(driver string) (source.Driver, error) {
+	return iofs.New(FS, driver)
+}
+
+// Up applies every pending migration to conn.
+func Up(conn *db.DB) error {
+	m, err := New(conn)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Drop deletes every table of conn, including the version table.
+func Drop(ctx context.Context, conn *db.DB) error {
+	if conn.Dialect.Name() != db.SQLite {
+		m, err := New(conn)
+		if err != nil {
+			return err
+		}
+		return m.Drop()
+	}
+
+	// The sqlite driver also drops SQLite's internal tables, which fails.
+	// A single connection keeps the pragma in effect for every statement.
+	c, err := conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	rows, err := c.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := c.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer c.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	for _, table := range tables {
+		if _, err := c.ExecContext(ctx, fmt.Sprintf("DROP TABLE %q", table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
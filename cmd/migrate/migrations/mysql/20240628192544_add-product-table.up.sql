@@ -5,6 +5,6 @@ CREATE TABLE IF NOT EXISTS products (
   `image` VARCHAR(255) NOT NULL,
   `price` DECIMAL(10, 2) NOT NULL,
   `quantity` INT UNSIGNED NOT NULL,
-  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   PRIMARY KEY (id)
 );
\ No newline at end of file
@@ -1,11 +1,11 @@
 CREATE TABLE IF NOT EXISTS orders (
   `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
-  `userId` INT UNSIGNED NOT NULL,
+  `user_id` INT UNSIGNED NOT NULL,
   `total` DECIMAL(10, 2) NOT NULL,
   `status` ENUM('pending', 'completed', 'cancelled') NOT NULL DEFAULT 'pending',
   `address` TEXT NOT NULL,
-  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 
   PRIMARY KEY (`id`),
-  FOREIGN KEY (`userId`) REFERENCES users(`id`)
+  CONSTRAINT `fk_orders_user` FOREIGN KEY (`user_id`) REFERENCES users(`id`)
 );
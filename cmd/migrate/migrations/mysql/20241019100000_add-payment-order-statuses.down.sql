@@ -0,0 +1,3 @@
+UPDATE orders SET `status` = 'completed' WHERE `status` = 'paid';
+UPDATE orders SET `status` = 'cancelled' WHERE `status` = 'failed';
+ALTER TABLE orders MODIFY `status` ENUM('pending', 'completed', 'cancelled') NOT NULL DEFAULT 'pending';
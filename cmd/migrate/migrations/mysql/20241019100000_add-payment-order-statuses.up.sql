@@ -0,0 +1 @@
+ALTER TABLE orders MODIFY `status` ENUM('pending', 'paid', 'failed', 'completed', 'cancelled') NOT NULL DEFAULT 'pending';
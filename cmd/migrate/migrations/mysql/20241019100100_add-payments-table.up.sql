@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS payments (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `order_id` INT UNSIGNED NOT NULL,
+  `provider` VARCHAR(50) NOT NULL,
+  `reference` VARCHAR(255) NOT NULL,
+  `status` ENUM('authorized', 'requires_action', 'captured', 'voided', 'refunded', 'failed') NOT NULL,
+  `amount` DECIMAL(10, 2) NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uq_payments_provider_reference` (`provider`, `reference`),
+  CONSTRAINT `fk_payments_order` FOREIGN KEY (`order_id`) REFERENCES orders(`id`)
+);
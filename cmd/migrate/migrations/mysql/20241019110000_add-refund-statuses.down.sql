@@ -0,0 +1,3 @@
+ALTER TABLE payments DROP COLUMN `refunded`;
+UPDATE orders SET `status` = 'paid' WHERE `status` IN ('partially_refunded', 'refunded');
+ALTER TABLE orders MODIFY `status` ENUM('pending', 'paid', 'failed', 'completed', 'cancelled') NOT NULL DEFAULT 'pending';
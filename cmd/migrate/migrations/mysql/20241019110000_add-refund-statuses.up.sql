@@ -0,0 +1,2 @@
+ALTER TABLE orders MODIFY `status` ENUM('pending', 'paid', 'failed', 'partially_refunded', 'refunded', 'completed', 'cancelled') NOT NULL DEFAULT 'pending';
+ALTER TABLE payments ADD COLUMN `refunded` DECIMAL(10, 2) NOT NULL DEFAULT 0;
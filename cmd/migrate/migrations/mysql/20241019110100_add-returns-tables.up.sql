@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS returns (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `order_id` INT UNSIGNED NOT NULL,
+  `user_id` INT UNSIGNED NOT NULL,
+  `status` ENUM('requested', 'approved', 'rejected') NOT NULL DEFAULT 'requested',
+  `reason` TEXT NOT NULL,
+  `refund` DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_returns_order` FOREIGN KEY (`order_id`) REFERENCES orders(`id`),
+  CONSTRAINT `fk_returns_user` FOREIGN KEY (`user_id`) REFERENCES users(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS return_items (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `return_id` INT UNSIGNED NOT NULL,
+  `order_item_id` INT UNSIGNED NOT NULL,
+  `quantity` INT NOT NULL,
+
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_return_items_return` FOREIGN KEY (`return_id`) REFERENCES returns(`id`),
+  CONSTRAINT `fk_return_items_order_item` FOREIGN KEY (`order_item_id`) REFERENCES order_items(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS return_events (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `return_id` INT UNSIGNED NOT NULL,
+  `actor_id` INT UNSIGNED NOT NULL,
+  `action` VARCHAR(50) NOT NULL,
+  `detail` TEXT NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_return_events_return` FOREIGN KEY (`return_id`) REFERENCES returns(`id`),
+  CONSTRAINT `fk_return_events_actor` FOREIGN KEY (`actor_id`) REFERENCES users(`id`)
+);
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS promotions (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(255) NOT NULL,
+  `code` VARCHAR(50) NULL,
+  `kind` ENUM('percent', 'fixed') NOT NULL,
+  `value` DECIMAL(10, 2) NOT NULL,
+  `min_spend` DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  `max_uses` INT UNSIGNED NOT NULL DEFAULT 0,
+  `max_uses_per_user` INT UNSIGNED NOT NULL DEFAULT 0,
+  `uses` INT UNSIGNED NOT NULL DEFAULT 0,
+  `stackable` BOOLEAN NOT NULL DEFAULT FALSE,
+  `starts_at` TIMESTAMP NULL,
+  `ends_at` TIMESTAMP NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uq_promotions_code` (`code`)
+);
+
+CREATE TABLE IF NOT EXISTS promotion_products (
+  `promotion_id` INT UNSIGNED NOT NULL,
+  `product_id` INT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (`promotion_id`, `product_id`),
+  CONSTRAINT `fk_promotion_products_promotion` FOREIGN KEY (`promotion_id`) REFERENCES promotions(`id`),
+  CONSTRAINT `fk_promotion_products_product` FOREIGN KEY (`product_id`) REFERENCES products(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS order_discounts (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `order_id` INT UNSIGNED NOT NULL,
+  `promotion_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `code` VARCHAR(50) NOT NULL DEFAULT '',
+  `amount` DECIMAL(10, 2) NOT NULL,
+
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_order_discounts_order` FOREIGN KEY (`order_id`) REFERENCES orders(`id`),
+  CONSTRAINT `fk_order_discounts_promotion` FOREIGN KEY (`promotion_id`) REFERENCES promotions(`id`)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE order_items ADD COLUMN `tax_rate` DECIMAL(6, 4) NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN `tax` DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN `tax_inclusive` BOOLEAN NOT NULL DEFAULT FALSE;
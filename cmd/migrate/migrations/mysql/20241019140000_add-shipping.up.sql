@@ -0,0 +1,23 @@
+ALTER TABLE products ADD COLUMN `weight` DECIMAL(10, 3) NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN `length` DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN `width` DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN `height` DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS shipping_methods (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(255) NOT NULL,
+  `kind` ENUM('flat', 'weight', 'pickup') NOT NULL,
+  `price` DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  `price_per_kg` DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  `free_over` DECIMAL(10, 2) NOT NULL DEFAULT 0,
+  `max_weight` DECIMAL(10, 3) NOT NULL DEFAULT 0,
+  `countries` VARCHAR(255) NOT NULL DEFAULT '',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`id`)
+);
+
+ALTER TABLE orders ADD COLUMN `shipping_method_id` INT UNSIGNED NULL;
+ALTER TABLE orders ADD COLUMN `shipping_method` VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN `shipping_cost` DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD CONSTRAINT `fk_orders_shipping_method` FOREIGN KEY (`shipping_method_id`) REFERENCES shipping_methods(`id`);
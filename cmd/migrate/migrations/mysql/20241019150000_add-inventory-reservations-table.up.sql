@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS inventory_reservations (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `order_id` INT UNSIGNED NOT NULL,
+  `product_id` INT UNSIGNED NOT NULL,
+  `quantity` INT UNSIGNED NOT NULL,
+  `status` ENUM('active', 'committed', 'released') NOT NULL DEFAULT 'active',
+  `expires_at` TIMESTAMP NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`id`),
+  KEY `idx_inventory_reservations_status_expires_at` (`status`, `expires_at`),
+  CONSTRAINT `fk_inventory_reservations_order` FOREIGN KEY (`order_id`) REFERENCES orders(`id`),
+  CONSTRAINT `fk_inventory_reservations_product` FOREIGN KEY (`product_id`) REFERENCES products(`id`)
+);
@@ -0,0 +1,10 @@
+ALTER TABLE inventory_reservations DROP FOREIGN KEY `fk_inventory_reservations_variant`;
+ALTER TABLE inventory_reservations DROP COLUMN `variant_id`;
+
+ALTER TABLE order_items DROP FOREIGN KEY `fk_order_items_variant`;
+ALTER TABLE order_items DROP COLUMN `variant_id`;
+
+DROP TABLE IF EXISTS product_variant_values;
+DROP TABLE IF EXISTS product_variants;
+DROP TABLE IF EXISTS product_option_values;
+DROP TABLE IF EXISTS product_options;
@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS product_options (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `product_id` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(50) NOT NULL,
+  `sort_order` INT UNSIGNED NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uq_product_options_product_name` (`product_id`, `name`),
+  CONSTRAINT `fk_product_options_product` FOREIGN KEY (`product_id`) REFERENCES products(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS product_option_values (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `option_id` INT UNSIGNED NOT NULL,
+  `value` VARCHAR(100) NOT NULL,
+  `sort_order` INT UNSIGNED NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uq_product_option_values_option_value` (`option_id`, `value`),
+  CONSTRAINT `fk_product_option_values_option` FOREIGN KEY (`option_id`) REFERENCES product_options(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS product_variants (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `product_id` INT UNSIGNED NOT NULL,
+  `sku` VARCHAR(64) NOT NULL,
+  `price` DECIMAL(10, 2) NULL,
+  `quantity` INT UNSIGNED NOT NULL DEFAULT 0,
+  `image` VARCHAR(255) NOT NULL DEFAULT '',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uq_product_variants_sku` (`sku`),
+  CONSTRAINT `fk_product_variants_product` FOREIGN KEY (`product_id`) REFERENCES products(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS product_variant_values (
+  `variant_id` INT UNSIGNED NOT NULL,
+  `option_value_id` INT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (`variant_id`, `option_value_id`),
+  CONSTRAINT `fk_product_variant_values_variant` FOREIGN KEY (`variant_id`) REFERENCES product_variants(`id`),
+  CONSTRAINT `fk_product_variant_values_option_value` FOREIGN KEY (`option_value_id`) REFERENCES product_option_values(`id`)
+);
+
+ALTER TABLE order_items ADD COLUMN `variant_id` INT UNSIGNED NULL;
+ALTER TABLE order_items ADD CONSTRAINT `fk_order_items_variant` FOREIGN KEY (`variant_id`) REFERENCES product_variants(`id`);
+
+ALTER TABLE inventory_reservations ADD COLUMN `variant_id` INT UNSIGNED NULL;
+ALTER TABLE inventory_reservations ADD CONSTRAINT `fk_inventory_reservations_variant` FOREIGN KEY (`variant_id`) REFERENCES product_variants(`id`);
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS categories (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `parent_id` INT UNSIGNED NULL,
+  `name` VARCHAR(100) NOT NULL,
+  `slug` VARCHAR(100) NOT NULL,
+  `sort_order` INT NOT NULL DEFAULT 0,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uq_categories_slug` (`slug`),
+  CONSTRAINT `fk_categories_parent` FOREIGN KEY (`parent_id`) REFERENCES categories(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(50) NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uq_tags_name` (`name`)
+);
+
+CREATE TABLE IF NOT EXISTS product_categories (
+  `product_id` INT UNSIGNED NOT NULL,
+  `category_id` INT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (`product_id`, `category_id`),
+  CONSTRAINT `fk_product_categories_product` FOREIGN KEY (`product_id`) REFERENCES products(`id`),
+  CONSTRAINT `fk_product_categories_category` FOREIGN KEY (`category_id`) REFERENCES categories(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS product_tags (
+  `product_id` INT UNSIGNED NOT NULL,
+  `tag_id` INT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (`product_id`, `tag_id`),
+  CONSTRAINT `fk_product_tags_product` FOREIGN KEY (`product_id`) REFERENCES products(`id`),
+  CONSTRAINT `fk_product_tags_tag` FOREIGN KEY (`tag_id`) REFERENCES tags(`id`)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS promotion_categories (
+  `promotion_id` INT UNSIGNED NOT NULL,
+  `category_id` INT UNSIGNED NOT NULL,
+
+  PRIMARY KEY (`promotion_id`, `category_id`),
+  CONSTRAINT `fk_promotion_categories_promotion` FOREIGN KEY (`promotion_id`) REFERENCES promotions(`id`),
+  CONSTRAINT `fk_promotion_categories_category` FOREIGN KEY (`category_id`) REFERENCES categories(`id`)
+);
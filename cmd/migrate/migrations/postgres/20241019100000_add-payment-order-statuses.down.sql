@@ -0,0 +1,4 @@
+UPDATE orders SET status = 'completed' WHERE status = 'paid';
+UPDATE orders SET status = 'cancelled' WHERE status = 'failed';
+ALTER TABLE orders DROP CONSTRAINT orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('pending', 'completed', 'cancelled'));
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS payments (
+  id SERIAL PRIMARY KEY,
+  order_id INTEGER NOT NULL REFERENCES orders (id),
+  provider VARCHAR(50) NOT NULL,
+  reference VARCHAR(255) NOT NULL,
+  status VARCHAR(20) NOT NULL CHECK (status IN ('authorized', 'requires_action', 'captured', 'voided', 'refunded', 'failed')),
+  amount NUMERIC(10, 2) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  UNIQUE (provider, reference)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE payments DROP COLUMN refunded;
+UPDATE orders SET status = 'paid' WHERE status IN ('partially_refunded', 'refunded');
+ALTER TABLE orders DROP CONSTRAINT orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('pending', 'paid', 'failed', 'completed', 'cancelled'));
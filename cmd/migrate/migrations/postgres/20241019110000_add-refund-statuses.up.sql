@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP CONSTRAINT orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('pending', 'paid', 'failed', 'partially_refunded', 'refunded', 'completed', 'cancelled'));
+ALTER TABLE payments ADD COLUMN refunded NUMERIC(10, 2) NOT NULL DEFAULT 0;
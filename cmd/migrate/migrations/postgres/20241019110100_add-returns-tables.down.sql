@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS return_events;
+DROP TABLE IF EXISTS return_items;
+DROP TABLE IF EXISTS returns;
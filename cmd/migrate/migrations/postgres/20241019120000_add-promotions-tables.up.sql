@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS promotions (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  code VARCHAR(50) UNIQUE,
+  kind VARCHAR(20) NOT NULL CHECK (kind IN ('percent', 'fixed')),
+  value NUMERIC(10, 2) NOT NULL CHECK (value > 0),
+  min_spend NUMERIC(10, 2) NOT NULL DEFAULT 0,
+  max_uses INTEGER NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+  max_uses_per_user INTEGER NOT NULL DEFAULT 0 CHECK (max_uses_per_user >= 0),
+  uses INTEGER NOT NULL DEFAULT 0,
+  stackable BOOLEAN NOT NULL DEFAULT FALSE,
+  starts_at TIMESTAMPTZ,
+  ends_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS promotion_products (
+  promotion_id INTEGER NOT NULL REFERENCES promotions (id),
+  product_id INTEGER NOT NULL REFERENCES products (id),
+
+  PRIMARY KEY (promotion_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_discounts (
+  id SERIAL PRIMARY KEY,
+  order_id INTEGER NOT NULL REFERENCES orders (id),
+  promotion_id INTEGER NOT NULL REFERENCES promotions (id),
+  name VARCHAR(255) NOT NULL,
+  code VARCHAR(50) NOT NULL DEFAULT '',
+  amount NUMERIC(10, 2) NOT NULL
+);
@@ -0,0 +1,20 @@
+ALTER TABLE products ADD COLUMN weight NUMERIC(10, 3) NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN length NUMERIC(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN width NUMERIC(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN height NUMERIC(10, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS shipping_methods (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  kind VARCHAR(20) NOT NULL CHECK (kind IN ('flat', 'weight', 'pickup')),
+  price NUMERIC(10, 2) NOT NULL DEFAULT 0,
+  price_per_kg NUMERIC(10, 2) NOT NULL DEFAULT 0,
+  free_over NUMERIC(10, 2) NOT NULL DEFAULT 0,
+  max_weight NUMERIC(10, 3) NOT NULL DEFAULT 0,
+  countries VARCHAR(255) NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE orders ADD COLUMN shipping_method_id INTEGER NULL REFERENCES shipping_methods (id);
+ALTER TABLE orders ADD COLUMN shipping_method VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN shipping_cost NUMERIC(10, 2) NOT NULL DEFAULT 0;
@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS product_options (
+  id SERIAL PRIMARY KEY,
+  product_id INTEGER NOT NULL REFERENCES products (id),
+  name VARCHAR(50) NOT NULL,
+  sort_order INTEGER NOT NULL DEFAULT 0,
+
+  UNIQUE (product_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS product_option_values (
+  id SERIAL PRIMARY KEY,
+  option_id INTEGER NOT NULL REFERENCES product_options (id),
+  value VARCHAR(100) NOT NULL,
+  sort_order INTEGER NOT NULL DEFAULT 0,
+
+  UNIQUE (option_id, value)
+);
+
+CREATE TABLE IF NOT EXISTS product_variants (
+  id SERIAL PRIMARY KEY,
+  product_id INTEGER NOT NULL REFERENCES products (id),
+  sku VARCHAR(64) NOT NULL UNIQUE,
+  price NUMERIC(10, 2),
+  quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+  image VARCHAR(255) NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS product_variant_values (
+  variant_id INTEGER NOT NULL REFERENCES product_variants (id),
+  option_value_id INTEGER NOT NULL REFERENCES product_option_values (id),
+
+  PRIMARY KEY (variant_id, option_value_id)
+);
+
+ALTER TABLE order_items ADD COLUMN variant_id INTEGER NULL REFERENCES product_variants (id);
+ALTER TABLE inventory_reservations ADD COLUMN variant_id INTEGER NULL REFERENCES product_variants (id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS products (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  description TEXT NOT NULL,
+  image TEXT NOT NULL,
+  price REAL NOT NULL,
+  quantity INTEGER NOT NULL CHECK (quantity >= 0),
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
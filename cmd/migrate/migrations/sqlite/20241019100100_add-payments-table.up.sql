@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS payments (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  order_id INTEGER NOT NULL REFERENCES orders (id),
+  provider TEXT NOT NULL,
+  reference TEXT NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('authorized', 'requires_action', 'captured', 'voided', 'refunded', 'failed')),
+  amount REAL NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  UNIQUE (provider, reference)
+);
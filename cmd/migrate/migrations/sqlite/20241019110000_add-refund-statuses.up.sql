@@ -0,0 +1,22 @@
+-- SQLite can't alter a CHECK constraint, so the table is rebuilt. Deferring
+-- the foreign keys lets the tables referencing orders point at it while
+-- it's re-created.
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE orders_old AS SELECT * FROM orders;
+DROP TABLE orders;
+
+CREATE TABLE orders (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users (id),
+  total REAL NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'failed', 'partially_refunded', 'refunded', 'completed', 'cancelled')),
+  address TEXT NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO orders (id, user_id, total, status, address, created_at)
+SELECT id, user_id, total, status, address, created_at FROM orders_old;
+DROP TABLE orders_old;
+
+ALTER TABLE payments ADD COLUMN refunded REAL NOT NULL DEFAULT 0;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS returns (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  order_id INTEGER NOT NULL REFERENCES orders (id),
+  user_id INTEGER NOT NULL REFERENCES users (id),
+  status TEXT NOT NULL DEFAULT 'requested' CHECK (status IN ('requested', 'approved', 'rejected')),
+  reason TEXT NOT NULL,
+  refund REAL NOT NULL DEFAULT 0,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS return_items (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  return_id INTEGER NOT NULL REFERENCES returns (id),
+  order_item_id INTEGER NOT NULL REFERENCES order_items (id),
+  quantity INTEGER NOT NULL CHECK (quantity > 0)
+);
+
+CREATE TABLE IF NOT EXISTS return_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  return_id INTEGER NOT NULL REFERENCES returns (id),
+  actor_id INTEGER NOT NULL REFERENCES users (id),
+  action TEXT NOT NULL,
+  detail TEXT NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
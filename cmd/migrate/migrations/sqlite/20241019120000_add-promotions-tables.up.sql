@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS promotions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  code TEXT UNIQUE,
+  kind TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+  value REAL NOT NULL CHECK (value > 0),
+  min_spend REAL NOT NULL DEFAULT 0,
+  max_uses INTEGER NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+  max_uses_per_user INTEGER NOT NULL DEFAULT 0 CHECK (max_uses_per_user >= 0),
+  uses INTEGER NOT NULL DEFAULT 0,
+  stackable BOOLEAN NOT NULL DEFAULT FALSE,
+  starts_at DATETIME,
+  ends_at DATETIME,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS promotion_products (
+  promotion_id INTEGER NOT NULL REFERENCES promotions (id),
+  product_id INTEGER NOT NULL REFERENCES products (id),
+
+  PRIMARY KEY (promotion_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS order_discounts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  order_id INTEGER NOT NULL REFERENCES orders (id),
+  promotion_id INTEGER NOT NULL REFERENCES promotions (id),
+  name TEXT NOT NULL,
+  code TEXT NOT NULL DEFAULT '',
+  amount REAL NOT NULL
+);
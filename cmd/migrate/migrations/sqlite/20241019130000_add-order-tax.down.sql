@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP COLUMN tax_inclusive;
+ALTER TABLE order_items DROP COLUMN tax;
+ALTER TABLE order_items DROP COLUMN tax_rate;
@@ -0,0 +1,10 @@
+ALTER TABLE orders DROP COLUMN shipping_cost;
+ALTER TABLE orders DROP COLUMN shipping_method;
+ALTER TABLE orders DROP COLUMN shipping_method_id;
+
+DROP TABLE IF EXISTS shipping_methods;
+
+ALTER TABLE products DROP COLUMN height;
+ALTER TABLE products DROP COLUMN width;
+ALTER TABLE products DROP COLUMN length;
+ALTER TABLE products DROP COLUMN weight;
@@ -0,0 +1,20 @@
+ALTER TABLE products ADD COLUMN weight REAL NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN length REAL NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN width REAL NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN height REAL NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS shipping_methods (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  kind TEXT NOT NULL CHECK (kind IN ('flat', 'weight', 'pickup')),
+  price REAL NOT NULL DEFAULT 0,
+  price_per_kg REAL NOT NULL DEFAULT 0,
+  free_over REAL NOT NULL DEFAULT 0,
+  max_weight REAL NOT NULL DEFAULT 0,
+  countries TEXT NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE orders ADD COLUMN shipping_method_id INTEGER NULL REFERENCES shipping_methods (id);
+ALTER TABLE orders ADD COLUMN shipping_method TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN shipping_cost REAL NOT NULL DEFAULT 0;
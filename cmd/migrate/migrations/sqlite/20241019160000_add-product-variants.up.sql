@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS product_options (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  product_id INTEGER NOT NULL REFERENCES products (id),
+  name TEXT NOT NULL,
+  sort_order INTEGER NOT NULL DEFAULT 0,
+
+  UNIQUE (product_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS product_option_values (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  option_id INTEGER NOT NULL REFERENCES product_options (id),
+  value TEXT NOT NULL,
+  sort_order INTEGER NOT NULL DEFAULT 0,
+
+  UNIQUE (option_id, value)
+);
+
+CREATE TABLE IF NOT EXISTS product_variants (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  product_id INTEGER NOT NULL REFERENCES products (id),
+  sku TEXT NOT NULL UNIQUE,
+  price REAL,
+  quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+  image TEXT NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS product_variant_values (
+  variant_id INTEGER NOT NULL REFERENCES product_variants (id),
+  option_value_id INTEGER NOT NULL REFERENCES product_option_values (id),
+
+  PRIMARY KEY (variant_id, option_value_id)
+);
+
+ALTER TABLE order_items ADD COLUMN variant_id INTEGER NULL REFERENCES product_variants (id);
+ALTER TABLE inventory_reservations ADD COLUMN variant_id INTEGER NULL REFERENCES product_variants (id);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS categories (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  parent_id INTEGER NULL REFERENCES categories (id),
+  name TEXT NOT NULL,
+  slug TEXT NOT NULL UNIQUE,
+  sort_order INTEGER NOT NULL DEFAULT 0,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS product_categories (
+  product_id INTEGER NOT NULL REFERENCES products (id),
+  category_id INTEGER NOT NULL REFERENCES categories (id),
+
+  PRIMARY KEY (product_id, category_id)
+);
+
+CREATE TABLE IF NOT EXISTS product_tags (
+  product_id INTEGER NOT NULL REFERENCES products (id),
+  tag_id INTEGER NOT NULL REFERENCES tags (id),
+
+  PRIMARY KEY (product_id, tag_id)
+);
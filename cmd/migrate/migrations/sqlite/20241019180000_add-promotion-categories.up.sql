@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS promotion_categories (
+  promotion_id INTEGER NOT NULL REFERENCES promotions (id),
+  category_id INTEGER NOT NULL REFERENCES categories (id),
+
+  PRIMARY KEY (promotion_id, category_id)
+);
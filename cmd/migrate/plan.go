@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// noVersion is the version of a database without applied migrations.
+const noVersion = -1
+
+// step : One migration file applied in one direction
+type step struct {
+	version uint
+	up      bool
+}
+
+// versions lists every migration version of src in ascending order.
+func versions(src source.Driver) ([]uint, error) {
+	v, err := src.First()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	all := []uint{v}
+	for {
+		v, err = src.Next(v)
+		if errors.Is(err, fs.ErrNotExist) {
+			return all, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, v)
+	}
+}
+
+// stepTarget returns the version n steps away from current, going down
+// when n is negative.
+func stepTarget(all []uint, current, n int) (int, error) {
+	i := indexOf(all, current)
+	if current != noVersion && i == noVersion {
+		return 0, fmt.Errorf("version %d has no migration file", current)
+	}
+
+	to := i + n
+	switch {
+	case to >= len(all):
+		return 0, fmt.Errorf("only %d migrations are pending", len(all)-1-i)
+	case to < noVersion:
+		return 0, fmt.Errorf("only %d migrations are applied", i+1)
+	case to == noVersion:
+		return noVersion, nil
+	}
+	return int(all[to]), nil
+}
+
+// plan lists the steps that migrate the database from current to target.
+func plan(all []uint, current, target int) []step {
+	var steps []step
+	if target >= current {
+		for _, v := range all {
+			if int(v) > current && int(v) <= target {
+				steps = append(steps, step{version: v, up: true})
+			}
+		}
+		return steps
+	}
+
+	for i := len(all) - 1; i >= 0; i-- {
+		if v := all[i]; int(v) <= current && int(v) > target {
+			steps = append(steps, step{version: v})
+		}
+	}
+	return steps
+}
+
+// indexOf returns the position of version in all, or noVersion.
+func indexOf(all []uint, version int) int {
+	for i, v := range all {
+		if int(v) == version {
+			return i
+		}
+	}
+	return noVersion
+}
+
+// printPlan writes the SQL of every step to w without running it.
+func printPlan(w io.Writer, src source.Driver, steps []step) error {
+	if len(steps) == 0 {
+		fmt.Fprintln(w, "-- no change")
+		return nil
+	}
+
+	for _, s := range steps {
+		read, direction := src.ReadDown, "down"
+		if s.up {
+			read, direction = src.ReadUp, "up"
+		}
+
+		r, identifier, err := read(s.version)
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "-- %d (%s): no migration file\n\n", s.version, direction)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "-- %d %s (%s)\n", s.version, identifier, direction)
+		_, err = io.Copy(w, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, "\n\n")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/davidado/go-api-reference/cmd/migrate/migrations"
+	"github.com/davidado/go-api-reference/db"
+)
+
+func TestPlan(t *testing.T) {
+	all := []uint{10, 20, 30}
+
+	tests := []struct {
+		name            string
+		current, target int
+		want            []step
+	}{
+		{"up from nothing", noVersion, 30, []step{{10, true}, {20, true}, {30, true}}},
+		{"up part of the way", 10, 20, []step{{20, true}}},
+		{"down to nothing", 30, noVersion, []step{{30, false}, {20, false}, {10, false}}},
+		{"down part of the way", 30, 10, []step{{30, false}, {20, false}}},
+		{"no change", 20, 20, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := plan(all, tt.current, tt.target)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestStepTarget(t *testing.T) {
+	all := []uint{10, 20, 30}
+
+	tests := []struct {
+		name       string
+		current, n int
+		want       int
+		wantErr    bool
+	}{
+		{"up one from nothing", noVersion, 1, 10, false},
+		{"up two", 10, 2, 30, false},
+		{"up past the last", 20, 2, 0, true},
+		{"down one", 20, -1, 10, false},
+		{"down to nothing", 20, -2, noVersion, false},
+		{"down past the first", 20, -3, 0, true},
+		{"unknown current version", 15, 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stepTarget(all, tt.current, tt.n)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	src, err := migrations.Source(db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := versions(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := printPlan(&buf, src, plan(all, noVersion, int(all[0]))); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "-- 20240628192527 add-user-table (up)\n") || !strings.Contains(out, "CREATE TABLE IF NOT EXISTS users") {
+		t.Errorf("unexpected plan %q", out)
+	}
+}
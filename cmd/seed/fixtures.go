@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fixtures : Users, products and orders to load into a database
+//
+// Orders refer to their user by email and to products by name, so a fixture
+// file doesn't depend on the IDs the database assigns.
+type Fixtures struct {
+	Users    []UserFixture    `json:"users" yaml:"users"`
+	Products []ProductFixture `json:"products" yaml:"products"`
+	Orders   []OrderFixture   `json:"orders" yaml:"orders"`
+}
+
+// UserFixture : A user with a plain text password, hashed when seeded
+type UserFixture struct {
+	FirstName string `json:"first_name" yaml:"first_name"`
+	LastName  string `json:"last_name" yaml:"last_name"`
+	Email     string `json:"email" yaml:"email"`
+	Password  string `json:"password" yaml:"password"`
+}
+
+// ProductFixture : A product, identified by its name
+type ProductFixture struct {
+	Name        string  `json:"name" yaml:"name"`
+	Description string  `json:"description" yaml:"description"`
+	Image       string  `json:"image" yaml:"image"`
+	Price       float64 `json:"price" yaml:"price"`
+	Quantity    int     `json:"quantity" yaml:"quantity"`
+	Weight      float64 `json:"weight" yaml:"weight"`
+	Length      float64 `json:"length" yaml:"length"`
+	Width       float64 `json:"width" yaml:"width"`
+	Height      float64 `json:"height" yaml:"height"`
+}
+
+// OrderFixture : An order of a user. The total is the sum of its items at
+// the products' current prices.
+type OrderFixture struct {
+	User    string             `json:"user" yaml:"user"`
+	Status  string             `json:"status" yaml:"status"`
+	Address string             `json:"address" yaml:"address"`
+	Items   []OrderItemFixture `json:"items" yaml:"items"`
+}
+
+// OrderItemFixture : A product and quantity of an order
+type OrderItemFixture struct {
+	Product  string `json:"product" yaml:"product"`
+	Quantity int    `json:"quantity" yaml:"quantity"`
+}
+
+// loadFixtures reads a .yaml, .yml or .json fixture file.
+func loadFixtures(path string) (Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Fixtures{}, err
+	}
+
+	var f Fixtures
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&f)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&f)
+	default:
+		return Fixtures{}, fmt.Errorf("%s: unsupported fixture format %q, want .yaml, .yml or .json", path, ext)
+	}
+	if err != nil {
+		return Fixtures{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return f, nil
+}
+
+// merge appends the fixtures of other.
+func (f *Fixtures) merge(other Fixtures) {
+	f.Users = append(f.Users, other.Users...)
+	f.Products = append(f.Products, other.Products...)
+	f.Orders = append(f.Orders, other.Orders...)
+}
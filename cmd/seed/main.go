@@ -0,0 +1,84 @@
+// Package main : Seed a database with fixtures
+//
+// Usage:
+//
+//	seed [flags] [fixture.yaml|fixture.json ...]
+//
+// Fixture files are loaded in order. --users and --products add generated
+// rows for load testing on top of them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/davidado/go-api-reference/config"
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/service/order"
+	"github.com/davidado/go-api-reference/service/product"
+	"github.com/davidado/go-api-reference/service/user"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	dbURL := flag.String("db", "", "database URL overriding the DB_* settings, e.g. sqlite://ecom.db")
+	upsert := flag.Bool("upsert", false, "keep existing users, update existing products by name and skip orders of users who have orders, so seeding can be repeated")
+	users := flag.Int("users", 0, "number of random users to generate, all with the password \""+randomPassword+"\"")
+	products := flag.Int("products", 0, "number of random products to generate")
+	seed := flag.Uint64("seed", 1, "seed of the random generator; the same seed generates the same rows")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: seed [flags] [fixture.yaml|fixture.json ...]\n\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Flags may also follow the fixture files.
+	var files []string
+	for args := flag.Args(); len(args) > 0; args = flag.Args() {
+		files = append(files, args[0])
+		flag.CommandLine.Parse(args[1:])
+	}
+
+	if len(files) == 0 && *users == 0 && *products == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *users < 0 || *products < 0 {
+		log.Fatal("--users and --products can't be negative")
+	}
+
+	var fixtures Fixtures
+	for _, path := range files {
+		f, err := loadFixtures(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fixtures.merge(f)
+	}
+	fixtures.merge(randomFixtures(*seed, *users, *products))
+
+	cfg := config.Envs
+	if *dbURL != "" {
+		if err := cfg.SetDatabaseURL(*dbURL); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	conn, err := db.Open(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	s := newSeeder(user.NewStore(conn), product.NewStore(conn), order.NewStore(conn), *upsert)
+	// Upserts look up existing rows, which a lagging replica may not have.
+	st, err := s.seed(db.WithPrimary(context.Background()), fixtures)
+	if err != nil {
+		log.Fatalf("Seeding stopped after %s: %v", st, err)
+	}
+	log.Printf("Seeded %s", st)
+}
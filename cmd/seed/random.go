@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// randomPassword is the password of every generated user.
+const randomPassword = "password"
+
+var (
+	adjectives = []string{"Classic", "Compact", "Deluxe", "Handmade", "Insulated", "Large", "Matte", "Organic", "Portable", "Vintage"}
+	nouns      = []string{"Mug", "Kettle", "Grinder", "Tumbler", "Scale", "Dripper", "Filter", "Carafe", "Press", "Canister"}
+	firstNames = []string{"Ada", "Alan", "Grace", "Edsger", "Barbara", "Donald", "Frances", "Ken", "Margaret", "Niklaus"}
+	lastNames  = []string{"Lovelace", "Turing", "Hopper", "Dijkstra", "Liskov", "Knuth", "Allen", "Thompson", "Hamilton", "Wirth"}
+)
+
+// randomFixtures generates users and products for load testing. The same
+// seed always generates the same fixtures, and names and emails are
+// numbered so that upserting them again finds the earlier rows.
+func randomFixtures(seed uint64, users, products int) Fixtures {
+	r := rand.New(rand.NewPCG(seed, seed))
+
+	var f Fixtures
+	for i := 1; i <= users; i++ {
+		f.Users = append(f.Users, UserFixture{
+			FirstName: pick(r, firstNames),
+			LastName:  pick(r, lastNames),
+			Email:     fmt.Sprintf("user%05d@example.com", i),
+			Password:  randomPassword,
+		})
+	}
+	for i := 1; i <= products; i++ {
+		name := fmt.Sprintf("%s %s %05d", pick(r, adjectives), pick(r, nouns), i)
+		f.Products = append(f.Products, ProductFixture{
+			Name:        name,
+			Description: "Generated product " + name + ".",
+			Image:       fmt.Sprintf("product-%05d.jpg", i),
+			Price:       math.Round((1+r.Float64()*199)*100) / 100,
+			Quantity:    r.IntN(500),
+		})
+	}
+	return f
+}
+
+func pick(r *rand.Rand, words []string) string {
+	return words[r.IntN(len(words))]
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davidado/go-api-reference/memstore"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/types"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	t.Run("should load YAML fixtures", func(t *testing.T) {
+		f, err := loadFixtures("../../fixtures/example.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Users) != 2 || len(f.Products) != 3 || len(f.Orders) != 3 {
+			t.Fatalf("unexpected fixtures %+v", f)
+		}
+		if item := f.Orders[0].Items[1]; item.Product != "Coffee Beans" || item.Quantity != 1 {
+			t.Errorf("unexpected order item %+v", item)
+		}
+	})
+
+	t.Run("should load JSON fixtures", func(t *testing.T) {
+		f, err := loadFixtures("testdata/fixtures.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Users) != 1 || f.Users[0].FirstName != "Grace" || f.Products[0].Price != 129.99 {
+			t.Errorf("unexpected fixtures %+v", f)
+		}
+	})
+
+	t.Run("should reject unknown fields", func(t *testing.T) {
+		if _, err := loadFixtures("testdata/unknown-field.yaml"); err == nil || !strings.Contains(err.Error(), "colour") {
+			t.Errorf("expected an unknown field error, got %v", err)
+		}
+	})
+}
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+
+	f, err := loadFixtures("../../fixtures/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should create users, products and orders", func(t *testing.T) {
+		store := memstore.New()
+		st, err := newSeeder(store, store, store, false).seed(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (stats{UsersCreated: 2, ProductsCreated: 3, OrdersCreated: 3}); st != want {
+			t.Errorf("expected %v, got %v", want, st)
+		}
+
+		u, err := store.GetUserByEmail(ctx, "ada@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !auth.ComparePasswords(u.Password, []byte("password")) {
+			t.Error("expected the password to be hashed")
+		}
+
+		var orders []types.Order
+		err = store.StreamOrdersByUser(ctx, u.ID, func(o types.Order) error {
+			orders = append(orders, o)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Newest first.
+		if len(orders) != 2 || orders[0].Total != 45 || orders[1].Total != 31.25 || orders[1].Status != "completed" || orders[0].Status != "pending" {
+			t.Errorf("unexpected orders %+v", orders)
+		}
+	})
+
+	t.Run("should fail on existing users without upsert", func(t *testing.T) {
+		store := memstore.New()
+		if _, err := newSeeder(store, store, store, false).seed(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newSeeder(store, store, store, false).seed(ctx, f); err == nil || !strings.Contains(err.Error(), "--upsert") {
+			t.Errorf("expected an error suggesting --upsert, got %v", err)
+		}
+	})
+
+	t.Run("should be idempotent with upsert", func(t *testing.T) {
+		store := memstore.New()
+		if _, err := newSeeder(store, store, store, true).seed(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+
+		changed := f
+		changed.Products = append([]ProductFixture(nil), f.Products...)
+		changed.Products[0].Price = 9
+
+		st, err := newSeeder(store, store, store, true).seed(ctx, changed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (stats{UsersKept: 2, ProductsUpdated: 3, OrdersSkipped: 3}); st != want {
+			t.Errorf("expected %v, got %v", want, st)
+		}
+
+		ps, err := store.GetProducts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ps) != 3 || ps[0].Price != 9 {
+			t.Errorf("unexpected products %+v", ps)
+		}
+	})
+
+	t.Run("should reject orders of unknown products", func(t *testing.T) {
+		store := memstore.New()
+		bad := Fixtures{
+			Users:  f.Users[:1],
+			Orders: []OrderFixture{{User: "ada@example.com", Items: []OrderItemFixture{{Product: "Teapot", Quantity: 1}}}},
+		}
+		if _, err := newSeeder(store, store, store, false).seed(ctx, bad); err == nil || !strings.Contains(err.Error(), "Teapot") {
+			t.Errorf("expected an unknown product error, got %v", err)
+		}
+	})
+}
+
+func TestRandomFixtures(t *testing.T) {
+	a := randomFixtures(42, 3, 5)
+	if len(a.Users) != 3 || len(a.Products) != 5 {
+		t.Fatalf("unexpected fixtures %+v", a)
+	}
+	if !reflect.DeepEqual(a, randomFixtures(42, 3, 5)) {
+		t.Error("expected the same seed to generate the same fixtures")
+	}
+	if reflect.DeepEqual(a.Products, randomFixtures(7, 3, 5).Products) {
+		t.Error("expected another seed to generate other products")
+	}
+	if a.Users[2].Email != "user00003@example.com" {
+		t.Errorf("unexpected email %q", a.Users[2].Email)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// orderStatuses are the statuses the orders table accepts.
+var orderStatuses = map[string]bool{"pending": true, "completed": true, "cancelled": true}
+
+// seeder loads fixtures through the store interfaces, so it works on every
+// backend.
+//
+// By default it inserts everything and fails on a user that already exists.
+// With upsert it can run repeatedly: existing users are kept, existing
+// products are updated by name and the orders of users who already have
+// orders are skipped.
+type seeder struct {
+	users    types.UserStore
+	products types.ProductStore
+	orders   types.OrderStore
+	upsert   bool
+
+	// hashes caches password hashes. bcrypt is slow on purpose and the
+	// generated users share one password.
+	hashes map[string]string
+}
+
+// stats : What a seed run changed
+type stats struct {
+	UsersCreated, UsersKept          int
+	ProductsCreated, ProductsUpdated int
+	OrdersCreated, OrdersSkipped     int
+}
+
+func (s stats) String() string {
+	return fmt.Sprintf("users: %d created, %d kept; products: %d created, %d updated; orders: %d created, %d skipped",
+		s.UsersCreated, s.UsersKept, s.ProductsCreated, s.ProductsUpdated, s.OrdersCreated, s.OrdersSkipped)
+}
+
+func newSeeder(users types.UserStore, products types.ProductStore, orders types.OrderStore, upsert bool) *seeder {
+	return &seeder{
+		users:    users,
+		products: products,
+		orders:   orders,
+		upsert:   upsert,
+		hashes:   map[string]string{},
+	}
+}
+
+// seed loads f: users first, then products, then the orders referring to
+// both. Orders don't change product stock.
+func (s *seeder) seed(ctx context.Context, f Fixtures) (stats, error) {
+	var st stats
+	if err := s.seedUsers(ctx, f.Users, &st); err != nil {
+		return st, err
+	}
+	products, err := s.seedProducts(ctx, f.Products, &st)
+	if err != nil {
+		return st, err
+	}
+	if err := s.seedOrders(ctx, f.Orders, products, &st); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+func (s *seeder) seedUsers(ctx context.Context, users []UserFixture, st *stats) error {
+	for _, u := range users {
+		if s.upsert {
+			_, err := s.users.GetUserByEmail(ctx, u.Email)
+			if err == nil {
+				st.UsersKept++
+				continue
+			}
+			if !errors.Is(err, types.ErrNotFound) {
+				return fmt.Errorf("user %s: %w", u.Email, err)
+			}
+		}
+
+		hash, err := s.hash(u.Password)
+		if err != nil {
+			return fmt.Errorf("user %s: %w", u.Email, err)
+		}
+
+		err = s.users.CreateUser(ctx, types.User{
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+			Email:     u.Email,
+			Password:  hash,
+		})
+		if errors.Is(err, types.ErrConflict) {
+			return fmt.Errorf("user %s already exists, seed with --upsert to keep existing rows", u.Email)
+		}
+		if err != nil {
+			return fmt.Errorf("user %s: %w", u.Email, err)
+		}
+		st.UsersCreated++
+	}
+	return nil
+}
+
+// seedProducts returns every product by name, including existing ones that
+// orders may refer to.
+func (s *seeder) seedProducts(ctx context.Context, products []ProductFixture, st *stats) (map[string]types.Product, error) {
+	byName := map[string]types.Product{}
+	err := s.products.StreamProducts(ctx, types.ProductFilter{}, func(p types.Product) error {
+		byName[p.Name] = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pf := range products {
+		p := types.Product{
+			Name:        pf.Name,
+			Description: pf.Description,
+			Image:       pf.Image,
+			Price:       pf.Price,
+			Quantity:    pf.Quantity,
+			Weight:      pf.Weight,
+			Length:      pf.Length,
+			Width:       pf.Width,
+			Height:      pf.Height,
+		}
+
+		if existing, ok := byName[p.Name]; ok && s.upsert {
+			p.ID = existing.ID
+			p.CreatedAt = existing.CreatedAt
+			if err := s.products.UpdateProduct(ctx, p); err != nil {
+				return nil, fmt.Errorf("product %q: %w", p.Name, err)
+			}
+			byName[p.Name] = p
+			st.ProductsUpdated++
+			continue
+		}
+
+		p.ID, err = s.products.CreateProduct(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("product %q: %w", p.Name, err)
+		}
+		byName[p.Name] = p
+		st.ProductsCreated++
+	}
+	return byName, nil
+}
+
+func (s *seeder) seedOrders(ctx context.Context, orders []OrderFixture, products map[string]types.Product, st *stats) error {
+	// hadOrders records, per user, whether the user had orders before this
+	// run, so upserting a file with several orders of a user creates all
+	// of them once.
+	hadOrders := map[int]bool{}
+
+	for i, of := range orders {
+		u, err := s.users.GetUserByEmail(ctx, of.User)
+		if errors.Is(err, types.ErrNotFound) {
+			return fmt.Errorf("order %d: unknown user %s", i+1, of.User)
+		}
+		if err != nil {
+			return fmt.Errorf("order %d: %w", i+1, err)
+		}
+
+		status := of.Status
+		if status == "" {
+			status = "pending"
+		}
+		if !orderStatuses[status] {
+			return fmt.Errorf("order %d: invalid status %q", i+1, status)
+		}
+		if len(of.Items) == 0 {
+			return fmt.Errorf("order %d: no items", i+1)
+		}
+
+		items := make([]types.OrderItem, len(of.Items))
+		total := 0.0
+		for j, item := range of.Items {
+			p, ok := products[item.Product]
+			if !ok {
+				return fmt.Errorf("order %d: unknown product %q", i+1, item.Product)
+			}
+			if item.Quantity <= 0 {
+				return fmt.Errorf("order %d: invalid quantity %d of %q", i+1, item.Quantity, item.Product)
+			}
+			items[j] = types.OrderItem{ProductID: p.ID, Quantity: item.Quantity, Price: p.Price}
+			total += p.Price * float64(item.Quantity)
+		}
+
+		if s.upsert {
+			had, checked := hadOrders[u.ID]
+			if !checked {
+				had, err = s.hasOrders(ctx, u.ID)
+				if err != nil {
+					return fmt.Errorf("order %d: %w", i+1, err)
+				}
+				hadOrders[u.ID] = had
+			}
+			if had {
+				st.OrdersSkipped++
+				continue
+			}
+		}
+
+		orderID, err := s.orders.CreateOrder(ctx, types.Order{
+			UserID:  u.ID,
+			Total:   math.Round(total*100) / 100,
+			Status:  status,
+			Address: of.Address,
+		})
+		if err != nil {
+			return fmt.Errorf("order %d: %w", i+1, err)
+		}
+		for _, item := range items {
+			item.OrderID = orderID
+			if err := s.orders.CreateOrderItem(ctx, item); err != nil {
+				return fmt.Errorf("order %d: %w", i+1, err)
+			}
+		}
+		st.OrdersCreated++
+	}
+	return nil
+}
+
+// errFound stops streaming at the first order.
+var errFound = errors.New("found")
+
+func (s *seeder) hasOrders(ctx context.Context, userID int) (bool, error) {
+	err := s.orders.StreamOrdersByUser(ctx, userID, func(types.Order) error {
+		return errFound
+	})
+	if errors.Is(err, errFound) {
+		return true, nil
+	}
+	return false, err
+}
+
+func (s *seeder) hash(password string) (string, error) {
+	if hash, ok := s.hashes[password]; ok {
+		return hash, nil
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+	s.hashes[password] = hash
+	return hash, nil
+}
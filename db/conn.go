@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DB : Database handle that adapts store queries to its dialect
+//
+// Stores write their SQL with ? placeholders; DB rebinds them for the
+// backend before running the query. Read-only queries go through Read,
+// which may pick a replica. Reads are retried after transient errors as
+// set by SetRetry; writes only through RetryTx.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+
+	router *router
+	retry  retryPolicy
+}
+
+// New wraps an open *sql.DB.
+func New(sqlDB *sql.DB, dialect Dialect) *DB {
+	return &DB{DB: sqlDB, Dialect: dialect}
+}
+
+// QueryContext runs a read-only query that returns rows. It's retried
+// after transient errors, so it must not change data.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query = db.Dialect.Rebind(query)
+
+	var rows *sql.Rows
+	err := db.withRetry(ctx, func() (err error) {
+		rows, err = db.DB.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// QueryRowContext runs a query that returns at most one row. Its error
+// only shows on Scan, so it isn't retried.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.Dialect.Rebind(query), args...)
+}
+
+// ExecContext runs a query that doesn't return rows
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := db.DB.ExecContext(ctx, db.Dialect.Rebind(query), args...)
+	db.wrote()
+	return res, err
+}
+
+// InsertID runs an INSERT and returns the id of the new row, using
+// RETURNING id where the backend supports it and LastInsertId otherwise.
+func (db *DB) InsertID(ctx context.Context, query string, args ...any) (int, error) {
+	id, err := insertID(ctx, db, db.Dialect, query, args)
+	db.wrote()
+	return id, err
+}
+
+// execer : What InsertID needs of a DB or Tx
+type execer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertID(ctx context.Context, e execer, dialect Dialect, query string, args []any) (int, error) {
+	if dialect.Returning() {
+		var id int
+		err := e.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	res, err := e.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
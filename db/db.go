@@ -2,23 +2,226 @@
 package db
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
 	"time"
 
+	"github.com/davidado/go-api-reference/config"
 	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib" // postgres driver
+	_ "modernc.org/sqlite"             // sqlite driver
 )
 
+// Open connects to the database backend selected by cfg.DBDriver.
+func Open(cfg config.Config) (*DB, error) {
+	dialect, err := DialectFor(cfg.DBDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conn := New(sqlDB, dialect)
+	conn.SetRetry(int(cfg.DBRetries), time.Millisecond*time.Duration(cfg.DBRetryBackoffInMilliseconds))
+
+	if len(cfg.DBReplicas) == 0 {
+		return conn, nil
+	}
+	if cfg.DBDriver == SQLite {
+		sqlDB.Close()
+		return nil, errors.New("db: sqlite doesn't support replicas")
+	}
+
+	replicas := make([]*sql.DB, 0, len(cfg.DBReplicas))
+	for i, rawURL := range cfg.DBReplicas {
+		replicaCfg := cfg
+		err := replicaCfg.SetDatabaseURL(rawURL)
+		if err == nil && replicaCfg.DBDriver != cfg.DBDriver {
+			err = fmt.Errorf("driver %s differs from the primary's %s", replicaCfg.DBDriver, cfg.DBDriver)
+		}
+		var replica *sql.DB
+		if err == nil {
+			replica, err = open(replicaCfg)
+		}
+		if err != nil {
+			for _, r := range replicas {
+				r.Close()
+			}
+			sqlDB.Close()
+			return nil, fmt.Errorf("db: replica %d: %w", i, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	conn.UseReplicas(replicas, time.Second*time.Duration(cfg.DBReplicaPinInSeconds))
+	conn.StartReplicaChecks(time.Second * time.Duration(cfg.DBReplicaCheckInSeconds))
+	return conn, nil
+}
+
+// open connects to the database cfg describes and sizes its pool.
+func open(cfg config.Config) (*sql.DB, error) {
+	var sqlDB *sql.DB
+	switch cfg.DBDriver {
+	case MySQL:
+		mysqlCfg, err := MySQLConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sqlDB, err = NewMySQLStorage(mysqlCfg)
+		if err != nil {
+			return nil, err
+		}
+	case Postgres:
+		dsn, err := PostgresDSN(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sqlDB, err = NewPostgresStorage(dsn)
+		if err != nil {
+			return nil, err
+		}
+	case SQLite:
+		// SQLite keeps its single connection; see NewSQLiteStorage.
+		return NewSQLiteStorage(cfg.DBPath)
+	default:
+		return nil, errors.New("db: unsupported driver " + strconv.Quote(cfg.DBDriver))
+	}
+
+	sqlDB.SetMaxOpenConns(int(cfg.DBMaxOpenConns))
+	sqlDB.SetMaxIdleConns(int(cfg.DBMaxIdleConns))
+	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(cfg.DBConnMaxLifetimeInSeconds))
+	sqlDB.SetConnMaxIdleTime(time.Second * time.Duration(cfg.DBConnMaxIdleTimeInSeconds))
+
+	return sqlDB, nil
+}
+
+// TLS modes of config.Envs.DBTLS.
+const (
+	TLSDisable = "disable" // plain text
+	TLSPrefer  = "prefer"  // TLS if the server supports it, unverified
+	TLSRequire = "require" // TLS without verifying the server certificate
+	TLSVerify  = "verify"  // TLS with a verified certificate and host name
+)
+
+// MySQLConfig builds the MySQL driver config from the app config
+func MySQLConfig(cfg config.Config) (mysql.Config, error) {
+	mysqlCfg := *mysql.NewConfig()
+	mysqlCfg.User = cfg.DBUser
+	mysqlCfg.Passwd = cfg.DBPassword
+	mysqlCfg.Addr = cfg.DBAddress
+	mysqlCfg.DBName = cfg.DBName
+	mysqlCfg.Net = "tcp"
+	mysqlCfg.ParseTime = true
+	mysqlCfg.Timeout = time.Second * time.Duration(cfg.DBConnectTimeoutInSeconds)
+	mysqlCfg.ReadTimeout = time.Second * time.Duration(cfg.DBReadTimeoutInSeconds)
+	mysqlCfg.WriteTimeout = time.Second * time.Duration(cfg.DBWriteTimeoutInSeconds)
+	mysqlCfg.MultiStatements = cfg.DBMultiStatements
+
+	switch cfg.DBTLS {
+	case TLSDisable, "":
+	case TLSPrefer:
+		mysqlCfg.TLSConfig = "preferred"
+	case TLSRequire:
+		mysqlCfg.TLS = &tls.Config{InsecureSkipVerify: true}
+	case TLSVerify:
+		host, _, err := net.SplitHostPort(cfg.DBAddress)
+		if err != nil {
+			host = cfg.DBAddress
+		}
+		mysqlCfg.TLS = &tls.Config{ServerName: host}
+		if cfg.DBTLSCA != "" {
+			pem, err := os.ReadFile(cfg.DBTLSCA)
+			if err != nil {
+				return mysql.Config{}, fmt.Errorf("db: read TLS CA: %w", err)
+			}
+			mysqlCfg.TLS.RootCAs = x509.NewCertPool()
+			if !mysqlCfg.TLS.RootCAs.AppendCertsFromPEM(pem) {
+				return mysql.Config{}, fmt.Errorf("db: no certificates in %s", cfg.DBTLSCA)
+			}
+		}
+	default:
+		return mysql.Config{}, fmt.Errorf("db: invalid DB_TLS %q, want disable, prefer, require or verify", cfg.DBTLS)
+	}
+
+	return mysqlCfg, nil
+}
+
+// PostgresDSN builds a postgres:// connection URL from the app config.
+// PostgreSQL has no per-read or per-write timeouts; the request deadline
+// bounds its queries.
+func PostgresDSN(cfg config.Config) (string, error) {
+	q := url.Values{}
+	q.Set("connect_timeout", strconv.FormatInt(cfg.DBConnectTimeoutInSeconds, 10))
+
+	switch cfg.DBTLS {
+	case TLSDisable, "":
+		q.Set("sslmode", "disable")
+	case TLSPrefer:
+		q.Set("sslmode", "prefer")
+	case TLSRequire:
+		q.Set("sslmode", "require")
+	case TLSVerify:
+		q.Set("sslmode", "verify-full")
+		if cfg.DBTLSCA != "" {
+			q.Set("sslrootcert", cfg.DBTLSCA)
+		}
+	default:
+		return "", fmt.Errorf("db: invalid DB_TLS %q, want disable, prefer, require or verify", cfg.DBTLS)
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.DBUser, cfg.DBPassword),
+		Host:     cfg.DBAddress,
+		Path:     "/" + cfg.DBName,
+		RawQuery: q.Encode(),
+	}
+	return u.String(), nil
+}
+
 // NewMySQLStorage creates a new MySQL storage instance
 func NewMySQLStorage(cfg mysql.Config) (*sql.DB, error) {
-	db, err := sql.Open("mysql", cfg.FormatDSN())
+	connector, err := mysql.NewConnector(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("open mysql: %w", err)
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// NewPostgresStorage creates a new PostgreSQL storage instance
+func NewPostgresStorage(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres: %w", err)
+	}
+	return db, nil
+}
+
+// NewSQLiteStorage creates a new SQLite storage instance backed by the file
+// at path, or an in-memory database for ":memory:".
+func NewSQLiteStorage(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
 
-	db.SetConnMaxLifetime(time.Minute * 3)
-	db.SetMaxOpenConns(151)
-	db.SetMaxIdleConns(151)
+	// SQLite allows a single writer, and every connection to ":memory:"
+	// would get its own empty database.
+	db.SetMaxOpenConns(1)
 
 	return db, nil
 }
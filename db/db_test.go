@@ -0,0 +1,87 @@
+package db
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/davidado/go-api-reference/config"
+)
+
+func TestDriverConfig(t *testing.T) {
+	cfg := config.Config{
+		DBUser:                    "app",
+		DBPassword:                "secret",
+		DBAddress:                 "db.internal:5432",
+		DBName:                    "ecom",
+		DBConnectTimeoutInSeconds: 3,
+		DBReadTimeoutInSeconds:    20,
+		DBWriteTimeoutInSeconds:   10,
+	}
+
+	t.Run("should set the MySQL timeouts and TLS", func(t *testing.T) {
+		cfg := cfg
+		cfg.DBTLS = TLSVerify
+
+		mysqlCfg, err := MySQLConfig(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mysqlCfg.Timeout != 3*time.Second || mysqlCfg.ReadTimeout != 20*time.Second || mysqlCfg.WriteTimeout != 10*time.Second {
+			t.Errorf("unexpected timeouts %v, %v, %v", mysqlCfg.Timeout, mysqlCfg.ReadTimeout, mysqlCfg.WriteTimeout)
+		}
+		if mysqlCfg.TLS == nil || mysqlCfg.TLS.ServerName != "db.internal" || mysqlCfg.TLS.InsecureSkipVerify {
+			t.Errorf("expected a verifying TLS config for db.internal, got %+v", mysqlCfg.TLS)
+		}
+	})
+
+	t.Run("should set the Postgres timeout and sslmode", func(t *testing.T) {
+		tests := map[string]string{
+			TLSDisable: "disable",
+			TLSPrefer:  "prefer",
+			TLSRequire: "require",
+			TLSVerify:  "verify-full",
+		}
+		for mode, sslmode := range tests {
+			cfg := cfg
+			cfg.DBTLS = mode
+
+			dsn, err := PostgresDSN(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.Query().Get("sslmode"); got != sslmode {
+				t.Errorf("DB_TLS=%s: expected sslmode %s, got %s", mode, sslmode, got)
+			}
+			if got := u.Query().Get("connect_timeout"); got != "3" {
+				t.Errorf("expected connect_timeout 3, got %s", got)
+			}
+		}
+	})
+
+	t.Run("should reject unknown TLS modes", func(t *testing.T) {
+		cfg := cfg
+		cfg.DBTLS = "sometimes"
+
+		if _, err := MySQLConfig(cfg); err == nil {
+			t.Error("expected MySQLConfig to fail")
+		}
+		if _, err := PostgresDSN(cfg); err == nil {
+			t.Error("expected PostgresDSN to fail")
+		}
+	})
+
+	t.Run("should fail on a missing CA file", func(t *testing.T) {
+		cfg := cfg
+		cfg.DBTLS = TLSVerify
+		cfg.DBTLSCA = t.TempDir() + "/missing.pem"
+
+		if _, err := MySQLConfig(cfg); err == nil {
+			t.Error("expected MySQLConfig to fail")
+		}
+	})
+}
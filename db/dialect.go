@@ -0,0 +1,155 @@
+package db
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Supported database drivers, as set in config.Envs.DBDriver.
+const (
+	MySQL    = "mysql"
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Dialect : SQL differences between database backends
+type Dialect interface {
+	// Name returns the driver name, e.g. "mysql".
+	Name() string
+
+	// Rebind converts the ? placeholders stores write their queries with
+	// to the backend's placeholder syntax.
+	Rebind(query string) string
+
+	// Returning reports whether INSERT ... RETURNING id is supported.
+	Returning() bool
+
+	// IsUniqueViolation reports whether err is a unique key violation.
+	IsUniqueViolation(err error) bool
+
+	// IsTransient reports whether err may go away when the statement or
+	// transaction is run again and guarantees the transaction was rolled
+	// back, e.g. a deadlock. Lost connections aren't transient: the
+	// statement may have been applied before the connection dropped.
+	IsTransient(err error) bool
+
+	// ColumnsQuery returns a query listing the column names of the table
+	// given as its only argument.
+	ColumnsQuery() string
+}
+
+// DialectFor returns the dialect of a driver.
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case MySQL:
+		return mysqlDialect{}, nil
+	case Postgres:
+		return postgresDialect{}, nil
+	case SQLite:
+		return sqliteDialect{}, nil
+	}
+	return nil, errors.New("db: unsupported driver " + strconv.Quote(driver))
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string               { return MySQL }
+func (mysqlDialect) Rebind(query string) string { return query }
+func (mysqlDialect) Returning() bool            { return false }
+
+func (mysqlDialect) IsUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 // ER_DUP_ENTRY
+}
+
+func (mysqlDialect) IsTransient(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) &&
+		(mysqlErr.Number == 1213 || // ER_LOCK_DEADLOCK
+			mysqlErr.Number == 1205) // ER_LOCK_WAIT_TIMEOUT
+}
+
+func (mysqlDialect) ColumnsQuery() string {
+	return "SELECT column_name FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?"
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string    { return Postgres }
+func (postgresDialect) Returning() bool { return true }
+
+// Rebind numbers the placeholders: "a = ? AND b = ?" becomes
+// "a = $1 AND b = $2". Question marks inside quoted strings are kept.
+func (postgresDialect) Rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func (postgresDialect) IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" // unique_violation
+}
+
+func (postgresDialect) IsTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		(pgErr.Code == "40001" || // serialization_failure
+			pgErr.Code == "40P01") // deadlock_detected
+}
+
+func (postgresDialect) ColumnsQuery() string {
+	return "SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ?"
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string               { return SQLite }
+func (sqliteDialect) Rebind(query string) string { return query }
+func (sqliteDialect) Returning() bool            { return true }
+
+func (sqliteDialect) IsUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// IsTransient reports SQLITE_BUSY and SQLITE_LOCKED, which outlast
+// busy_timeout only under heavy write contention.
+func (sqliteDialect) IsTransient(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code() & 0xff // primary result code
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// SQLite has no information_schema.
+func (sqliteDialect) ColumnsQuery() string {
+	return "SELECT name FROM pragma_table_info(?)"
+}
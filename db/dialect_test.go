@@ -0,0 +1,52 @@
+package db
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestPostgresRebind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM users WHERE id = ?", "SELECT * FROM users WHERE id = $1"},
+		{"INSERT INTO t (a, b) VALUES (?, ?)", "INSERT INTO t (a, b) VALUES ($1, $2)"},
+		{"SELECT '?' FROM t WHERE a = ?", "SELECT '?' FROM t WHERE a = $1"},
+		{"SELECT 1", "SELECT 1"},
+	}
+
+	for _, tt := range tests {
+		if got := (postgresDialect{}).Rebind(tt.query); got != tt.want {
+			t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		err     error
+		want    bool
+	}{
+		{mysqlDialect{}, &mysql.MySQLError{Number: 1213}, true},
+		{mysqlDialect{}, &mysql.MySQLError{Number: 1205}, true},
+		{mysqlDialect{}, &mysql.MySQLError{Number: 1062}, false},
+		{mysqlDialect{}, mysql.ErrInvalidConn, false},
+		{mysqlDialect{}, driver.ErrBadConn, false},
+		{postgresDialect{}, &pgconn.PgError{Code: "40001"}, true},
+		{postgresDialect{}, &pgconn.PgError{Code: "40P01"}, true},
+		{postgresDialect{}, &pgconn.PgError{Code: "08006"}, false},
+		{postgresDialect{}, &pgconn.PgError{Code: "57P01"}, false},
+		{postgresDialect{}, driver.ErrBadConn, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.dialect.IsTransient(tt.err); got != tt.want {
+			t.Errorf("%s IsTransient(%v) = %v, want %v", tt.dialect.Name(), tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replica : A read replica and whether its last health check passed
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// router : Read replicas shared by a primary DB
+type router struct {
+	replicas []*replica
+	next     atomic.Uint64
+
+	// pin keeps reads on the primary this long after a write, so a client
+	// reads its own writes despite replication lag.
+	pin       time.Duration
+	lastWrite atomic.Int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+}
+
+type primaryKey struct{}
+
+// WithPrimary marks ctx so reads made with it go to the primary, e.g.
+// reads that a following write depends on.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryKey{}).(bool)
+	return pinned
+}
+
+// UseReplicas routes the reads of Read to replicas, which must run the
+// primary's schema. Replicas start healthy; CheckReplicas ejects and
+// restores them.
+func (db *DB) UseReplicas(replicas []*sql.DB, pinAfterWrite time.Duration) {
+	r := &router{pin: pinAfterWrite, stop: make(chan struct{})}
+	for _, sqlDB := range replicas {
+		rep := &replica{db: sqlDB}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+	db.router = r
+}
+
+// Read returns the handle read-only queries should use: a healthy replica
+// in turn, or the primary when ctx is marked WithPrimary, a write happened
+// within the pin window, or no replica is healthy.
+func (db *DB) Read(ctx context.Context) *DB {
+	r := db.router
+	if r == nil || usePrimary(ctx) {
+		return db
+	}
+	if r.pin > 0 && time.Since(time.Unix(0, r.lastWrite.Load())) < r.pin {
+		return db
+	}
+
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if rep := r.replicas[(start+i)%n]; rep.healthy.Load() {
+			return &DB{DB: rep.db, Dialect: db.Dialect, retry: db.retry}
+		}
+	}
+	return db
+}
+
+// wrote records a write for the pin window.
+func (db *DB) wrote() {
+	if db.router != nil && db.router.pin > 0 {
+		db.router.lastWrite.Store(time.Now().UnixNano())
+	}
+}
+
+// CheckReplicas pings every replica once and ejects those that fail until
+// a later check passes.
+func (db *DB) CheckReplicas(ctx context.Context, timeout time.Duration) {
+	if db.router == nil {
+		return
+	}
+
+	for i, rep := range db.router.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := rep.db.PingContext(pingCtx)
+		cancel()
+
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("DB: replica %d is healthy again", i)
+			} else {
+				log.Printf("DB: replica %d ejected: %v", i, err)
+			}
+		}
+	}
+}
+
+// StartReplicaChecks runs CheckReplicas every interval until Close.
+func (db *DB) StartReplicaChecks(interval time.Duration) {
+	r := db.router
+	if r == nil || interval <= 0 {
+		return
+	}
+
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				db.CheckReplicas(context.Background(), interval)
+			}
+		}
+	}()
+}
+
+// Close stops the replica checks and closes the replicas and the primary.
+func (db *DB) Close() error {
+	if r := db.router; r != nil {
+		r.stopOnce.Do(func() { close(r.stop) })
+		r.done.Wait()
+		for _, rep := range r.replicas {
+			rep.db.Close()
+		}
+	}
+	return db.DB.Close()
+}
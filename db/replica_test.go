@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// namedDB opens an in-memory database that knows its own name.
+func namedDB(t *testing.T, name string) *sql.DB {
+	t.Helper()
+
+	sqlDB, err := NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if _, err := sqlDB.Exec("CREATE TABLE whoami (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlDB.Exec("INSERT INTO whoami VALUES (?)", name); err != nil {
+		t.Fatal(err)
+	}
+	return sqlDB
+}
+
+func readFrom(t *testing.T, ctx context.Context, conn *DB) string {
+	t.Helper()
+
+	var name string
+	if err := conn.Read(ctx).QueryRowContext(ctx, "SELECT name FROM whoami").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestReplicaRouting(t *testing.T) {
+	ctx := context.Background()
+
+	newConn := func(t *testing.T, pin time.Duration) *DB {
+		conn := New(namedDB(t, "primary"), sqliteDialect{})
+		conn.UseReplicas([]*sql.DB{namedDB(t, "replica-a"), namedDB(t, "replica-b")}, pin)
+		return conn
+	}
+
+	t.Run("should spread reads over the replicas", func(t *testing.T) {
+		conn := newConn(t, 0)
+
+		seen := map[string]int{}
+		for i := 0; i < 4; i++ {
+			seen[readFrom(t, ctx, conn)]++
+		}
+		if seen["replica-a"] != 2 || seen["replica-b"] != 2 {
+			t.Errorf("expected reads to alternate between replicas, got %v", seen)
+		}
+	})
+
+	t.Run("should read from the primary when asked", func(t *testing.T) {
+		conn := newConn(t, 0)
+
+		if got := readFrom(t, WithPrimary(ctx), conn); got != "primary" {
+			t.Errorf("expected the primary, got %s", got)
+		}
+	})
+
+	t.Run("should pin reads to the primary after a write", func(t *testing.T) {
+		conn := newConn(t, time.Hour)
+
+		if got := readFrom(t, ctx, conn); got == "primary" {
+			t.Fatal("expected a replica before any write")
+		}
+		if _, err := conn.ExecContext(ctx, "UPDATE whoami SET name = name"); err != nil {
+			t.Fatal(err)
+		}
+		if got := readFrom(t, ctx, conn); got != "primary" {
+			t.Errorf("expected the primary after a write, got %s", got)
+		}
+	})
+
+	t.Run("should eject unhealthy replicas", func(t *testing.T) {
+		primary := namedDB(t, "primary")
+		healthy := namedDB(t, "replica-a")
+		broken, err := sql.Open("sqlite", "file:/nonexistent/dir/db.sqlite?mode=ro")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer broken.Close()
+
+		conn := New(primary, sqliteDialect{})
+		conn.UseReplicas([]*sql.DB{healthy, broken}, 0)
+		conn.CheckReplicas(ctx, time.Second)
+
+		for i := 0; i < 3; i++ {
+			if got := readFrom(t, ctx, conn); got != "replica-a" {
+				t.Errorf("expected the healthy replica, got %s", got)
+			}
+		}
+
+		healthy.Close()
+		conn.CheckReplicas(ctx, time.Second)
+		if got := readFrom(t, ctx, conn); got != "primary" {
+			t.Errorf("expected the primary without healthy replicas, got %s", got)
+		}
+	})
+}
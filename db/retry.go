@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// maxBackoff caps the wait between two attempts.
+const maxBackoff = 2 * time.Second
+
+// retryPolicy : How often and how fast transient errors are retried
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+}
+
+// SetRetry makes reads and RetryTx run again up to retries times after a
+// transient error, first waiting about backoff, then twice as long each
+// time. Retries are off until it's called.
+func (db *DB) SetRetry(retries int, backoff time.Duration) {
+	db.retry = retryPolicy{retries: max(retries, 0), backoff: backoff}
+}
+
+// withRetry runs fn until it succeeds, fails with an error the dialect
+// doesn't consider transient or with a failed commit, runs out of retries
+// or ctx is done.
+func (db *DB) withRetry(ctx context.Context, fn func() error) error {
+	delay := db.retry.backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= db.retry.retries || !db.Dialect.IsTransient(err) || errors.As(err, new(commitError)) {
+			return err
+		}
+
+		// Jitter keeps clients that failed together from retrying together.
+		wait := delay/2 + rand.N(delay/2+1)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, maxBackoff)
+	}
+}
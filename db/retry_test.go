@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky")
+
+// flakyDialect treats errFlaky as transient, and lost connections too, so
+// tests can check failed commits aren't run again whatever the dialect
+// says.
+type flakyDialect struct{ sqliteDialect }
+
+func (flakyDialect) IsTransient(err error) bool {
+	return errors.Is(err, errFlaky) || errors.Is(err, driver.ErrBadConn)
+}
+
+// lostCommitConnector connects to a database that loses the connection of
+// every commit.
+type lostCommitConnector struct{}
+
+func (c lostCommitConnector) Connect(context.Context) (driver.Conn, error) {
+	return lostCommitConn{}, nil
+}
+func (c lostCommitConnector) Driver() driver.Driver { return nil }
+
+type lostCommitConn struct{}
+
+func (lostCommitConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (lostCommitConn) Close() error                        { return nil }
+func (lostCommitConn) Begin() (driver.Tx, error)           { return lostCommitConn{}, nil }
+func (lostCommitConn) Commit() error                       { return driver.ErrBadConn }
+func (lostCommitConn) Rollback() error                     { return nil }
+
+func TestRetryTx(t *testing.T) {
+	ctx := context.Background()
+
+	newConn := func(t *testing.T, retries int) *DB {
+		conn := New(namedDB(t, "primary"), flakyDialect{})
+		conn.SetRetry(retries, time.Millisecond)
+		return conn
+	}
+
+	// rename renames the row, failing with failErr for the first failures runs.
+	rename := func(failures int, failErr error, runs *int) func(tx *Tx) error {
+		return func(tx *Tx) error {
+			*runs++
+			if _, err := tx.ExecContext(ctx, "UPDATE whoami SET name = ?", "renamed"); err != nil {
+				return err
+			}
+			if *runs <= failures {
+				return failErr
+			}
+			return nil
+		}
+	}
+
+	t.Run("should run again after a transient error", func(t *testing.T) {
+		conn := newConn(t, 3)
+
+		var runs int
+		if err := conn.RetryTx(ctx, rename(2, errFlaky, &runs)); err != nil {
+			t.Fatal(err)
+		}
+		if runs != 3 {
+			t.Errorf("expected 3 runs, got %d", runs)
+		}
+		if got := readFrom(t, ctx, conn); got != "renamed" {
+			t.Errorf("expected the transaction to commit, got %s", got)
+		}
+	})
+
+	t.Run("should give up after the last retry", func(t *testing.T) {
+		conn := newConn(t, 2)
+
+		var runs int
+		if err := conn.RetryTx(ctx, rename(5, errFlaky, &runs)); !errors.Is(err, errFlaky) {
+			t.Fatalf("expected errFlaky, got %v", err)
+		}
+		if runs != 3 {
+			t.Errorf("expected 3 runs, got %d", runs)
+		}
+		if got := readFrom(t, ctx, conn); got != "primary" {
+			t.Errorf("expected the transaction to roll back, got %s", got)
+		}
+	})
+
+	t.Run("should not retry other errors", func(t *testing.T) {
+		conn := newConn(t, 3)
+		errOther := errors.New("other")
+
+		var runs int
+		if err := conn.RetryTx(ctx, rename(1, errOther, &runs)); !errors.Is(err, errOther) {
+			t.Fatalf("expected errOther, got %v", err)
+		}
+		if runs != 1 {
+			t.Errorf("expected 1 run, got %d", runs)
+		}
+	})
+
+	t.Run("should not run again after a failed commit", func(t *testing.T) {
+		conn := New(sql.OpenDB(lostCommitConnector{}), flakyDialect{})
+		conn.SetRetry(3, time.Millisecond)
+
+		var runs int
+		err := conn.RetryTx(ctx, func(tx *Tx) error {
+			runs++
+			return nil
+		})
+		if !errors.Is(err, driver.ErrBadConn) {
+			t.Fatalf("expected driver.ErrBadConn, got %v", err)
+		}
+		if runs != 1 {
+			t.Errorf("expected 1 run, got %d", runs)
+		}
+	})
+
+	t.Run("should not retry InTx", func(t *testing.T) {
+		conn := newConn(t, 3)
+
+		var runs int
+		if err := conn.InTx(ctx, rename(1, errFlaky, &runs)); !errors.Is(err, errFlaky) {
+			t.Fatalf("expected errFlaky, got %v", err)
+		}
+		if runs != 1 {
+			t.Errorf("expected 1 run, got %d", runs)
+		}
+	})
+
+	t.Run("should stop when the context is done", func(t *testing.T) {
+		conn := New(namedDB(t, "primary"), flakyDialect{})
+		conn.SetRetry(3, time.Hour)
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		var runs int
+		if err := conn.RetryTx(ctx, rename(5, errFlaky, &runs)); !errors.Is(err, errFlaky) {
+			t.Fatalf("expected errFlaky, got %v", err)
+		}
+		if runs != 1 {
+			t.Errorf("expected 1 run, got %d", runs)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Table : A table and the columns a store reads and writes
+//
+// Stores build their SELECTs from it, so the columns checked against the
+// database are the ones the queries use.
+type Table struct {
+	Name    string
+	Columns []string
+}
+
+// Select returns a query selecting the table's columns in order, followed
+// by clause, e.g. "WHERE id = ?".
+func (t Table) Select(clause string) string {
+	query := "SELECT " + strings.Join(t.Columns, ", ") + " FROM " + t.Name
+	if clause != "" {
+		query += " " + clause
+	}
+	return query
+}
+
+// SelectWith is Select with exprs selected after the columns, e.g.
+// subqueries computing a value from other tables.
+func (t Table) SelectWith(clause string, exprs ...string) string {
+	query := "SELECT " + strings.Join(append(slices.Clip(t.Columns), exprs...), ", ") + " FROM " + t.Name
+	if clause != "" {
+		query += " " + clause
+	}
+	return query
+}
+
+// Drift : Difference between a table a store expects and the database
+type Drift struct {
+	Table string
+	// NoTable is set when the table doesn't exist at all.
+	NoTable bool
+	// Missing lists the columns the store uses that the table lacks.
+	Missing []string
+	// Extra lists the table's columns the store doesn't know about.
+	Extra []string
+}
+
+// Breaking reports whether the store's queries fail against the table.
+// Extra columns only matter if they can't be left out of an INSERT.
+func (d Drift) Breaking() bool {
+	return d.NoTable || len(d.Missing) > 0
+}
+
+func (d Drift) String() string {
+	if d.NoTable {
+		return fmt.Sprintf("table %s is missing", d.Table)
+	}
+
+	var parts []string
+	if len(d.Missing) > 0 {
+		parts = append(parts, "missing columns "+strings.Join(d.Missing, ", "))
+	}
+	if len(d.Extra) > 0 {
+		parts = append(parts, "unexpected columns "+strings.Join(d.Extra, ", "))
+	}
+	return fmt.Sprintf("table %s: %s", d.Table, strings.Join(parts, "; "))
+}
+
+// CheckSchema compares tables against the database and returns the drift
+// of every table that differs.
+func (db *DB) CheckSchema(ctx context.Context, tables []Table) ([]Drift, error) {
+	var drifts []Drift
+	for _, t := range tables {
+		actual, err := db.columns(ctx, t.Name)
+		if err != nil {
+			return nil, fmt.Errorf("list columns of %s: %w", t.Name, err)
+		}
+
+		d := Drift{Table: t.Name, NoTable: len(actual) == 0}
+		if !d.NoTable {
+			for _, c := range t.Columns {
+				if !containsFold(actual, c) {
+					d.Missing = append(d.Missing, c)
+				}
+			}
+			for _, c := range actual {
+				if !containsFold(t.Columns, c) {
+					d.Extra = append(d.Extra, c)
+				}
+			}
+		}
+
+		if d.NoTable || len(d.Missing) > 0 || len(d.Extra) > 0 {
+			drifts = append(drifts, d)
+		}
+	}
+	return drifts, nil
+}
+
+// columns lists the column names of a table, none if it doesn't exist.
+func (db *DB) columns(ctx context.Context, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, db.Dialect.ColumnsQuery(), table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// containsFold reports whether names holds name, ignoring case like MySQL
+// column names do.
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
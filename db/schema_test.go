@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCheckSchema(t *testing.T) {
+	sqlDB, err := NewSQLiteStorage(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	conn := New(sqlDB, sqliteDialect{})
+	ctx := context.Background()
+
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY, firstName TEXT, email TEXT, nickname TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	drifts, err := conn.CheckSchema(ctx, []Table{
+		{Name: "users", Columns: []string{"id", "first_name", "email"}},
+		{Name: "products", Columns: []string{"id", "name"}},
+		{Name: "orders", Columns: []string{"id"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Drift{
+		{Table: "users", Missing: []string{"first_name"}, Extra: []string{"firstName", "nickname"}},
+		{Table: "orders", NoTable: true},
+	}
+	if !reflect.DeepEqual(drifts, want) {
+		t.Fatalf("expected %+v, got %+v", want, drifts)
+	}
+
+	if got := drifts[0].String(); got != "table users: missing columns first_name; unexpected columns firstName, nickname" {
+		t.Errorf("unexpected description %q", got)
+	}
+	if !drifts[0].Breaking() || !drifts[1].Breaking() {
+		t.Error("expected missing tables and columns to be breaking")
+	}
+	if (Drift{Table: "users", Extra: []string{"nickname"}}).Breaking() {
+		t.Error("expected extra columns not to be breaking")
+	}
+}
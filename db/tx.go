@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Tx : Transaction that adapts store queries to its dialect, like DB
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+}
+
+// QueryContext runs a query that returns rows
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.Dialect.Rebind(query), args...)
+}
+
+// QueryRowContext runs a query that returns at most one row
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.Dialect.Rebind(query), args...)
+}
+
+// ExecContext runs a query that doesn't return rows
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.Dialect.Rebind(query), args...)
+}
+
+// InsertID runs an INSERT and returns the id of the new row, like
+// DB.InsertID.
+func (tx *Tx) InsertID(ctx context.Context, query string, args ...any) (int, error) {
+	return insertID(ctx, tx, tx.Dialect, query, args)
+}
+
+// InTx runs fn in a transaction, which is committed if fn returns nil and
+// rolled back otherwise.
+func (db *DB) InTx(ctx context.Context, fn func(tx *Tx) error) error {
+	return unwrapCommit(db.inTx(ctx, fn))
+}
+
+// RetryTx is InTx for transactions that are safe to run again: when fn
+// fails with a transient error, e.g. a deadlock, the transaction is rolled
+// back and run anew as set by SetRetry. A failed commit is never run again,
+// since the transaction may have been applied anyway. fn must not have
+// effects outside tx, since it may run more than once.
+func (db *DB) RetryTx(ctx context.Context, fn func(tx *Tx) error) error {
+	return unwrapCommit(db.withRetry(ctx, func() error { return db.inTx(ctx, fn) }))
+}
+
+// inTx is InTx, reporting the errors of the commit as a commitError.
+func (db *DB) inTx(ctx context.Context, fn func(tx *Tx) error) error {
+	sqlTx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&Tx{Tx: sqlTx, Dialect: db.Dialect}); err != nil {
+		return errors.Join(err, ignoreDone(sqlTx.Rollback()))
+	}
+	err = sqlTx.Commit()
+	db.wrote()
+	if err != nil {
+		return commitError{err}
+	}
+	return nil
+}
+
+// commitError : Error of a commit, whose transaction may or may not have
+// been applied
+type commitError struct{ err error }
+
+func (e commitError) Error() string { return e.err.Error() }
+func (e commitError) Unwrap() error { return e.err }
+
+// unwrapCommit returns the error of a commit as the driver reported it.
+func unwrapCommit(err error) error {
+	var commitErr commitError
+	if errors.As(err, &commitErr) {
+		return commitErr.err
+	}
+	return err
+}
+
+// ignoreDone drops the error of rolling back a transaction the driver
+// already ended, e.g. after a deadlock.
+func ignoreDone(err error) error {
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,1454 @@
+// Package memstore : In-memory implementations of the store interfaces
+//
+// The stores behave like the SQL ones, including conflict and not-found
+// errors, so tests can exercise handlers against real behaviour without a
+// database.
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// Store : Thread-safe in-memory store implementing every store interface
+type Store struct {
+	mu sync.RWMutex
+
+	users      map[int]types.User
+	products   map[int]types.Product
+	orders     map[int]types.Order
+	orderItems map[int]types.OrderItem
+	payments   map[int]types.Payment
+	returns    map[int]types.Return
+	events     map[int]types.ReturnEvent
+	promotions map[int]types.Promotion
+	discounts  map[int]types.OrderDiscount
+	shipping   map[int]types.ShippingMethod
+	holds      map[int]types.Reservation
+	variants   map[int]types.Variant
+	// options holds the option matrix of each product, by product ID.
+	options    map[int][]types.ProductOption
+	categories map[int]types.Category
+	tags       map[int]types.Tag
+	// productCategories and productTags hold the category and tag IDs of
+	// each product, by product ID.
+	productCategories map[int][]int
+	productTags       map[int][]int
+
+	lastID map[string]int
+}
+
+var (
+	_ types.UserStore      = (*Store)(nil)
+	_ types.ProductStore   = (*Store)(nil)
+	_ types.VariantStore   = (*Store)(nil)
+	_ types.OrderStore     = (*Store)(nil)
+	_ types.PaymentStore   = (*Store)(nil)
+	_ types.ReturnStore    = (*Store)(nil)
+	_ types.PromotionStore = (*Store)(nil)
+	_ types.ShippingStore  = (*Store)(nil)
+	_ types.InventoryStore = (*Store)(nil)
+	_ types.CategoryStore  = (*Store)(nil)
+)
+
+// New creates an empty store
+func New() *Store {
+	return &Store{
+		users:      map[int]types.User{},
+		products:   map[int]types.Product{},
+		orders:     map[int]types.Order{},
+		orderItems: map[int]types.OrderItem{},
+		payments:   map[int]types.Payment{},
+		returns:    map[int]types.Return{},
+		events:     map[int]types.ReturnEvent{},
+		promotions: map[int]types.Promotion{},
+		discounts:  map[int]types.OrderDiscount{},
+		shipping:   map[int]types.ShippingMethod{},
+		holds:      map[int]types.Reservation{},
+		variants:   map[int]types.Variant{},
+		options:    map[int][]types.ProductOption{},
+		categories: map[int]types.Category{},
+		tags:       map[int]types.Tag{},
+		lastID:     map[string]int{},
+
+		productCategories: map[int][]int{},
+		productTags:       map[int][]int{},
+	}
+}
+
+// nextID returns the next auto-increment id of a table. Callers must hold
+// the write lock.
+func (s *Store) nextID(table string) int {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+// now matches the second precision of the SQL timestamp columns.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// GetUserByEmail : Get user by email
+func (s *Store) GetUserByEmail(_ context.Context, email string) (*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, types.Errorf(types.ErrNotFound, "user not found")
+}
+
+// GetUserByID : Get user by ID
+func (s *Store) GetUserByID(_ context.Context, id int) (*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, types.Errorf(types.ErrNotFound, "user %d not found", id)
+	}
+	return &u, nil
+}
+
+// CreateUser : Create a new user
+func (s *Store) CreateUser(_ context.Context, u types.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Email == u.Email {
+			return types.Errorf(types.ErrConflict, "user with email %s already exists", u.Email)
+		}
+	}
+
+	u.ID = s.nextID("users")
+	u.CreatedAt = now()
+	s.users[u.ID] = u
+	return nil
+}
+
+// GetProducts : Get all products
+func (s *Store) GetProducts(ctx context.Context) ([]types.Product, error) {
+	products := make([]types.Product, 0)
+	err := s.StreamProducts(ctx, types.ProductFilter{}, func(p types.Product) error {
+		products = append(products, p)
+		return nil
+	})
+	return products, err
+}
+
+// StreamProducts : Call fn for every product the filter matches in ID order
+func (s *Store) StreamProducts(ctx context.Context, filter types.ProductFilter, fn func(types.Product) error) error {
+	s.mu.RLock()
+	products := make([]types.Product, 0, len(s.products))
+	for _, id := range sortedKeys(s.products) {
+		if s.matches(id, filter) {
+			products = append(products, s.withAvailable(s.products[id]))
+		}
+	}
+	s.mu.RUnlock()
+
+	// fn runs without the lock so it may call back into the store.
+	for _, p := range products {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetProductsByID : Get products by ID, skipping IDs that don't exist
+func (s *Store) GetProductsByID(_ context.Context, ids []int) ([]types.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	products := []types.Product{}
+	for _, id := range sortedKeys(s.products) {
+		if wanted[id] {
+			products = append(products, s.withAvailable(s.products[id]))
+		}
+	}
+	return products, nil
+}
+
+// LookupProducts : Get products by ID and report the IDs that don't exist
+func (s *Store) LookupProducts(ctx context.Context, ids []int) (types.ProductLookup, error) {
+	products, err := s.GetProductsByID(ctx, ids)
+	if err != nil {
+		return types.ProductLookup{}, err
+	}
+	return types.NewProductLookup(ids, products), nil
+}
+
+// CreateProduct : Create a new product
+func (s *Store) CreateProduct(_ context.Context, p types.Product) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p.ID = s.nextID("products")
+	p.CreatedAt = now()
+	p.Available = 0
+	s.products[p.ID] = p
+	return p.ID, nil
+}
+
+// UpdateProduct : Update a product. Like an SQL UPDATE, unknown IDs are a
+// no-op.
+func (s *Store) UpdateProduct(_ context.Context, p types.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.products[p.ID]
+	if !ok {
+		return nil
+	}
+
+	p.CreatedAt = existing.CreatedAt
+	p.Available = 0
+	s.products[p.ID] = p
+	return nil
+}
+
+// CreateOrder creates a new order
+func (s *Store) CreateOrder(_ context.Context, o types.Order) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[o.UserID]; !ok {
+		return 0, types.Errorf(types.ErrNotFound, "user %d not found", o.UserID)
+	}
+	if _, ok := s.shipping[o.ShippingMethodID]; o.ShippingMethodID != 0 && !ok {
+		return 0, types.Errorf(types.ErrNotFound, "shipping method %d not found", o.ShippingMethodID)
+	}
+
+	for _, d := range o.Discounts {
+		if err := s.redeem(o.UserID, d); err != nil {
+			return 0, err
+		}
+	}
+
+	o.ID = s.nextID("orders")
+	o.CreatedAt = now()
+	if o.Status == "" {
+		o.Status = "pending"
+	}
+	for _, d := range o.Discounts {
+		p := s.promotions[d.PromotionID]
+		p.Uses++
+		s.promotions[p.ID] = p
+
+		d.ID = s.nextID("order_discounts")
+		d.OrderID = o.ID
+		s.discounts[d.ID] = d
+	}
+	o.Discounts = nil
+	s.orders[o.ID] = o
+	return o.ID, nil
+}
+
+// redeem checks that the promotion of d has a use left for a user. Callers
+// must hold the write lock.
+func (s *Store) redeem(userID int, d types.OrderDiscount) error {
+	p, ok := s.promotions[d.PromotionID]
+	if !ok || (p.MaxUses > 0 && p.Uses >= p.MaxUses) {
+		return types.Errorf(types.ErrCoupon, "promotion %s has been used up", d.Name)
+	}
+	if p.MaxUsesPerUser > 0 && s.countUserRedemptions(p.ID, userID) >= p.MaxUsesPerUser {
+		return types.Errorf(types.ErrCoupon, "promotion %s can only be used %d times", d.Name, p.MaxUsesPerUser)
+	}
+	return nil
+}
+
+// CreateOrderItem creates a new order item
+func (s *Store) CreateOrderItem(_ context.Context, oi types.OrderItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[oi.OrderID]; !ok {
+		return types.Errorf(types.ErrNotFound, "order %d not found", oi.OrderID)
+	}
+	if _, ok := s.products[oi.ProductID]; !ok {
+		return types.Errorf(types.ErrNotFound, "product %d not found", oi.ProductID)
+	}
+
+	oi.ID = s.nextID("order_items")
+	oi.CreatedAt = now()
+	s.orderItems[oi.ID] = oi
+	return nil
+}
+
+// GetOrderByID gets an order by ID
+func (s *Store) GetOrderByID(_ context.Context, id int) (*types.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		return nil, types.Errorf(types.ErrNotFound, "order %d not found", id)
+	}
+	return &o, nil
+}
+
+// GetOrderItems gets the items of an order in ID order
+func (s *Store) GetOrderItems(_ context.Context, orderID int) ([]types.OrderItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var items []types.OrderItem
+	for _, id := range sortedKeys(s.orderItems) {
+		if oi := s.orderItems[id]; oi.OrderID == orderID {
+			items = append(items, oi)
+		}
+	}
+	return items, nil
+}
+
+// GetOrderDiscounts gets the discount lines of an order in ID order
+func (s *Store) GetOrderDiscounts(_ context.Context, orderID int) ([]types.OrderDiscount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var discounts []types.OrderDiscount
+	for _, id := range sortedKeys(s.discounts) {
+		if d := s.discounts[id]; d.OrderID == orderID {
+			discounts = append(discounts, d)
+		}
+	}
+	return discounts, nil
+}
+
+// UpdateOrderStatus sets the status of an order. Like an SQL UPDATE,
+// unknown IDs are a no-op.
+func (s *Store) UpdateOrderStatus(_ context.Context, id int, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[id]
+	if !ok || o.Status == status {
+		return nil
+	}
+	o.Status = status
+	s.orders[id] = o
+
+	// A failed order gives back the uses of its promotions.
+	if status == types.OrderFailed {
+		for _, d := range s.discounts {
+			if p, ok := s.promotions[d.PromotionID]; ok && d.OrderID == id && p.Uses > 0 {
+				p.Uses--
+				s.promotions[d.PromotionID] = p
+			}
+		}
+	}
+	return nil
+}
+
+// StreamOrdersByUser calls fn for every order of a user, newest first
+func (s *Store) StreamOrdersByUser(ctx context.Context, userID int, fn func(types.Order) error) error {
+	s.mu.RLock()
+	var orders []types.Order
+	ids := sortedKeys(s.orders)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if o := s.orders[ids[i]]; o.UserID == userID {
+			orders = append(orders, o)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, o := range orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreatePayment records a payment of an order
+func (s *Store) CreatePayment(_ context.Context, p types.Payment) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[p.OrderID]; !ok {
+		return 0, types.Errorf(types.ErrNotFound, "order %d not found", p.OrderID)
+	}
+	for _, existing := range s.payments {
+		if existing.Provider == p.Provider && existing.Reference == p.Reference {
+			return 0, types.Errorf(types.ErrConflict, "payment %s of %s already exists", p.Reference, p.Provider)
+		}
+	}
+
+	p.ID = s.nextID("payments")
+	p.CreatedAt = now()
+	s.payments[p.ID] = p
+	return p.ID, nil
+}
+
+// GetPaymentByReference gets a payment by the provider's reference
+func (s *Store) GetPaymentByReference(_ context.Context, provider, reference string) (*types.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.payments {
+		if p.Provider == provider && p.Reference == reference {
+			return &p, nil
+		}
+	}
+	return nil, types.Errorf(types.ErrNotFound, "payment %s of %s not found", reference, provider)
+}
+
+// UpdatePaymentStatus sets the status of a payment. Like an SQL UPDATE,
+// unknown IDs are a no-op.
+func (s *Store) UpdatePaymentStatus(_ context.Context, id int, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.payments[id]; ok {
+		p.Status = status
+		s.payments[id] = p
+	}
+	return nil
+}
+
+// GetPaymentsByOrder gets the payments of an order in ID order
+func (s *Store) GetPaymentsByOrder(_ context.Context, orderID int) ([]types.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var payments []types.Payment
+	for _, id := range sortedKeys(s.payments) {
+		if p := s.payments[id]; p.OrderID == orderID {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
+
+// CreateReturn creates a return with its items
+func (s *Store) CreateReturn(_ context.Context, r types.Return) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[r.OrderID]; !ok {
+		return 0, types.Errorf(types.ErrNotFound, "order %d not found", r.OrderID)
+	}
+	left := map[int]int{}
+	for _, oi := range s.orderItems {
+		if oi.OrderID == r.OrderID {
+			left[oi.ID] = oi.Quantity
+		}
+	}
+	for _, other := range s.returns {
+		if other.OrderID != r.OrderID || other.Status == types.ReturnRejected {
+			continue
+		}
+		for _, item := range other.Items {
+			left[item.OrderItemID] -= item.Quantity
+		}
+	}
+	if err := types.CheckReturnItems(r.OrderID, left, r.Items); err != nil {
+		return 0, err
+	}
+
+	r.ID = s.nextID("returns")
+	r.Status = types.ReturnRequested
+	r.Refund = 0
+	r.CreatedAt = now()
+	r.Events = nil
+	items := make([]types.ReturnItem, len(r.Items))
+	for i, item := range r.Items {
+		item.ID = s.nextID("return_items")
+		item.ReturnID = r.ID
+		items[i] = item
+	}
+	r.Items = items
+	s.returns[r.ID] = r
+	return r.ID, nil
+}
+
+// GetReturn gets a return with its items and events
+func (s *Store) GetReturn(_ context.Context, id int) (*types.Return, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.returns[id]
+	if !ok {
+		return nil, types.Errorf(types.ErrNotFound, "return %d not found", id)
+	}
+	r.Items = append([]types.ReturnItem{}, r.Items...)
+	for _, eventID := range sortedKeys(s.events) {
+		if e := s.events[eventID]; e.ReturnID == id {
+			r.Events = append(r.Events, e)
+		}
+	}
+	return &r, nil
+}
+
+// ListReturns lists the returns matching filter with their items, newest
+// first
+func (s *Store) ListReturns(_ context.Context, filter types.ReturnFilter) ([]types.Return, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	returns := []types.Return{}
+	ids := sortedKeys(s.returns)
+	for i := len(ids) - 1; i >= 0; i-- {
+		r := s.returns[ids[i]]
+		if (filter.UserID != 0 && r.UserID != filter.UserID) ||
+			(filter.OrderID != 0 && r.OrderID != filter.OrderID) ||
+			(filter.Status != "" && r.Status != filter.Status) {
+			continue
+		}
+		r.Items = append([]types.ReturnItem{}, r.Items...)
+		returns = append(returns, r)
+	}
+	return returns, nil
+}
+
+// SetReturnStatus moves a return from one status to another, failing with
+// ErrConflict when it's no longer in status from
+func (s *Store) SetReturnStatus(_ context.Context, id int, from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.returns[id]
+	if !ok || r.Status != from {
+		return types.Errorf(types.ErrConflict, "return %d is no longer %s", id, from)
+	}
+	r.Status = to
+	s.returns[id] = r
+	return nil
+}
+
+// cents rounds an amount of money to cents.
+func cents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// ClaimRefund approves a requested return and adds its refund to its
+// payment at once, failing with ErrConflict when the return is no longer
+// requested or the payment has less than refund left
+func (s *Store) ClaimRefund(_ context.Context, returnID, paymentID int, refund float64) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.returns[returnID]
+	if !ok || r.Status != types.ReturnRequested {
+		return 0, types.Errorf(types.ErrConflict, "return %d is no longer %s", returnID, types.ReturnRequested)
+	}
+	p, ok := s.payments[paymentID]
+	if !ok {
+		return 0, types.Errorf(types.ErrNotFound, "payment %d not found", paymentID)
+	}
+	if cents(p.Refunded+refund) > cents(p.Amount) {
+		return 0, types.Errorf(types.ErrConflict, "only %.2f of payment %d is left to refund", p.Amount-p.Refunded, paymentID)
+	}
+
+	r.Status = types.ReturnApproved
+	r.Refund = refund
+	s.returns[returnID] = r
+	p.Refunded += refund
+	s.payments[paymentID] = p
+	return p.Refunded, nil
+}
+
+// ReleaseRefund reopens a claimed return and takes its refund off its
+// payment again
+func (s *Store) ReleaseRefund(_ context.Context, returnID, paymentID int, refund float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.returns[returnID]; ok && r.Status == types.ReturnApproved {
+		r.Status = types.ReturnRequested
+		r.Refund = 0
+		s.returns[returnID] = r
+	}
+	if p, ok := s.payments[paymentID]; ok {
+		p.Refunded -= refund
+		s.payments[paymentID] = p
+	}
+	return nil
+}
+
+// ApproveReturn records what the refund of a claimed return changed,
+// restocks its items and updates its payment and order at once
+func (s *Store) ApproveReturn(_ context.Context, a types.ReturnApproval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range a.Events {
+		if _, ok := s.returns[e.ReturnID]; !ok {
+			return types.Errorf(types.ErrNotFound, "return %d not found", e.ReturnID)
+		}
+		if _, ok := s.users[e.ActorID]; !ok {
+			return types.Errorf(types.ErrNotFound, "user %d not found", e.ActorID)
+		}
+	}
+
+	if p, ok := s.payments[a.PaymentID]; ok {
+		p.Status = a.PaymentStatus
+		s.payments[a.PaymentID] = p
+	}
+	for _, item := range a.Restock {
+		if item.VariantID != 0 {
+			if v, ok := s.variants[item.VariantID]; ok {
+				v.Quantity += item.Quantity
+				s.variants[item.VariantID] = v
+			}
+			continue
+		}
+		if p, ok := s.products[item.ProductID]; ok {
+			p.Quantity += item.Quantity
+			s.products[item.ProductID] = p
+		}
+	}
+	if o, ok := s.orders[a.OrderID]; ok {
+		o.Status = a.OrderStatus
+		s.orders[a.OrderID] = o
+	}
+	for _, e := range a.Events {
+		e.ID = s.nextID("return_events")
+		e.CreatedAt = now()
+		s.events[e.ID] = e
+	}
+	return nil
+}
+
+// AddReturnEvent appends an entry to the audit trail of a return
+func (s *Store) AddReturnEvent(_ context.Context, e types.ReturnEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.returns[e.ReturnID]; !ok {
+		return types.Errorf(types.ErrNotFound, "return %d not found", e.ReturnID)
+	}
+	if _, ok := s.users[e.ActorID]; !ok {
+		return types.Errorf(types.ErrNotFound, "user %d not found", e.ActorID)
+	}
+
+	e.ID = s.nextID("return_events")
+	e.CreatedAt = now()
+	s.events[e.ID] = e
+	return nil
+}
+
+// CreatePromotion creates a promotion with the products it's limited to
+func (s *Store) CreatePromotion(_ context.Context, p types.Promotion) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.promotions {
+		if p.Code != "" && existing.Code == p.Code {
+			return 0, types.Errorf(types.ErrConflict, "promotion code %s already exists", p.Code)
+		}
+	}
+	for _, productID := range p.ProductIDs {
+		if _, ok := s.products[productID]; !ok {
+			return 0, types.Errorf(types.ErrNotFound, "product %d not found", productID)
+		}
+	}
+	for _, categoryID := range p.CategoryIDs {
+		if _, ok := s.categories[categoryID]; !ok {
+			return 0, types.Errorf(types.ErrNotFound, "category %d not found", categoryID)
+		}
+	}
+
+	p.ID = s.nextID("promotions")
+	p.Uses = 0
+	p.CreatedAt = now()
+	p.StartsAt = utc(p.StartsAt)
+	p.EndsAt = utc(p.EndsAt)
+	p.ProductIDs = append([]int{}, p.ProductIDs...)
+	sort.Ints(p.ProductIDs)
+	p.CategoryIDs = append([]int{}, p.CategoryIDs...)
+	sort.Ints(p.CategoryIDs)
+	s.promotions[p.ID] = p
+	return p.ID, nil
+}
+
+// GetPromotionByCode gets a promotion by its coupon code
+func (s *Store) GetPromotionByCode(_ context.Context, code string) (*types.Promotion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.promotions {
+		if code != "" && p.Code == code {
+			p.ProductIDs = append([]int{}, p.ProductIDs...)
+			return &p, nil
+		}
+	}
+	return nil, types.Errorf(types.ErrNotFound, "promotion %s not found", code)
+}
+
+// GetAutomaticPromotions gets the promotions without a code in ID order
+func (s *Store) GetAutomaticPromotions(_ context.Context) ([]types.Promotion, error) {
+	return s.listPromotions(func(p types.Promotion) bool { return p.Code == "" }), nil
+}
+
+// ListPromotions lists every promotion in ID order
+func (s *Store) ListPromotions(_ context.Context) ([]types.Promotion, error) {
+	return s.listPromotions(func(types.Promotion) bool { return true }), nil
+}
+
+// CountUserRedemptions counts the orders of a user that used a promotion
+func (s *Store) CountUserRedemptions(_ context.Context, promotionID, userID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.countUserRedemptions(promotionID, userID), nil
+}
+
+// countUserRedemptions is CountUserRedemptions for callers holding the
+// lock.
+func (s *Store) countUserRedemptions(promotionID, userID int) int {
+	n := 0
+	for _, d := range s.discounts {
+		if o := s.orders[d.OrderID]; d.PromotionID == promotionID && o.UserID == userID && o.Status != types.OrderFailed {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Store) listPromotions(match func(types.Promotion) bool) []types.Promotion {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	promotions := []types.Promotion{}
+	for _, id := range sortedKeys(s.promotions) {
+		if p := s.promotions[id]; match(p) {
+			p.ProductIDs = append([]int{}, p.ProductIDs...)
+			p.CategoryIDs = append([]int{}, p.CategoryIDs...)
+			promotions = append(promotions, p)
+		}
+	}
+	return promotions
+}
+
+// withAvailable fills in the stock of p left after the active reservations.
+// Callers must hold the lock.
+func (s *Store) withAvailable(p types.Product) types.Product {
+	p.Available = p.Quantity
+	for _, r := range s.holds {
+		if r.ProductID == p.ID && r.VariantID == 0 && r.Status == types.ReservationActive {
+			p.Available -= r.Quantity
+		}
+	}
+	return p
+}
+
+// withVariantAvailable fills in the stock of v left after the active
+// reservations. Callers must hold the lock.
+func (s *Store) withVariantAvailable(v types.Variant) types.Variant {
+	v.Available = v.Quantity
+	for _, r := range s.holds {
+		if r.VariantID == v.ID && r.Status == types.ReservationActive {
+			v.Available -= r.Quantity
+		}
+	}
+	v.Options = slices.Clone(v.Options)
+	return v
+}
+
+// stock identifies the stock an item takes: its product's, or its
+// variant's when it has one.
+type stock struct {
+	productID, variantID int
+}
+
+func (k stock) String() string {
+	if k.variantID != 0 {
+		return fmt.Sprintf("variant %d of product %d", k.variantID, k.productID)
+	}
+	return fmt.Sprintf("product %d", k.productID)
+}
+
+// available returns the stock of k left for new orders, or false if it
+// doesn't exist. Callers must hold the lock.
+func (s *Store) available(k stock) (int, bool) {
+	if k.variantID != 0 {
+		v, ok := s.variants[k.variantID]
+		if !ok || v.ProductID != k.productID {
+			return 0, false
+		}
+		return s.withVariantAvailable(v).Available, true
+	}
+	p, ok := s.products[k.productID]
+	if !ok {
+		return 0, false
+	}
+	return s.withAvailable(p).Available, true
+}
+
+// ReserveStock holds the items of an order until expiresAt, or fails with
+// ErrOutOfStock without reserving anything
+func (s *Store) ReserveStock(_ context.Context, orderID int, items []types.CartItem, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[orderID]; !ok {
+		return types.Errorf(types.ErrNotFound, "order %d not found", orderID)
+	}
+	quantities := map[stock]int{}
+	for _, item := range items {
+		quantities[stock{item.ProductID, item.VariantID}] += item.Quantity
+	}
+	stocks := make([]stock, 0, len(quantities))
+	for k := range quantities {
+		stocks = append(stocks, k)
+	}
+	sort.Slice(stocks, func(i, j int) bool {
+		if stocks[i].productID != stocks[j].productID {
+			return stocks[i].productID < stocks[j].productID
+		}
+		return stocks[i].variantID < stocks[j].variantID
+	})
+
+	for _, k := range stocks {
+		available, ok := s.available(k)
+		if !ok {
+			return types.Errorf(types.ErrNotFound, "%s not found", k)
+		}
+		if available < quantities[k] {
+			return types.Errorf(types.ErrOutOfStock, "%s is out of stock", k)
+		}
+	}
+
+	for _, k := range stocks {
+		r := types.Reservation{
+			ID:        s.nextID("inventory_reservations"),
+			OrderID:   orderID,
+			ProductID: k.productID,
+			VariantID: k.variantID,
+			Quantity:  quantities[k],
+			Status:    types.ReservationActive,
+			ExpiresAt: expiresAt.UTC(),
+			CreatedAt: now(),
+		}
+		s.holds[r.ID] = r
+	}
+	return nil
+}
+
+// CommitReservations takes the active reservations of an order off the
+// stock of its products and variants. Committing twice does nothing.
+func (s *Store) CommitReservations(_ context.Context, orderID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.commit(orderID)
+}
+
+// CommitPaidOrder marks a payment captured, takes the order's reservations
+// off the stock and marks the order paid, all or nothing.
+func (s *Store) CommitPaidOrder(_ context.Context, orderID, paymentID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.commit(orderID); err != nil {
+		return err
+	}
+	if p, ok := s.payments[paymentID]; ok {
+		p.Status = types.PaymentCaptured
+		s.payments[paymentID] = p
+	}
+	if o, ok := s.orders[orderID]; ok {
+		o.Status = types.OrderPaid
+		s.orders[orderID] = o
+	}
+	return nil
+}
+
+// commit takes the active reservations of an order off the stock. It
+// changes nothing when it fails. s.mu must be held.
+func (s *Store) commit(orderID int) error {
+	committed, released := 0, 0
+	var active []int
+	for _, id := range sortedKeys(s.holds) {
+		r := s.holds[id]
+		if r.OrderID != orderID {
+			continue
+		}
+		switch r.Status {
+		case types.ReservationReleased:
+			released++
+		case types.ReservationActive:
+			active = append(active, id)
+			committed++
+		}
+	}
+
+	if committed == 0 && released > 0 {
+		return types.Errorf(types.ErrConflict, "the reservations of order %d have been released", orderID)
+	}
+
+	for _, id := range active {
+		r := s.holds[id]
+		r.Status = types.ReservationCommitted
+		s.holds[id] = r
+
+		if r.VariantID != 0 {
+			v := s.variants[r.VariantID]
+			v.Quantity -= r.Quantity
+			s.variants[v.ID] = v
+		} else {
+			p := s.products[r.ProductID]
+			p.Quantity -= r.Quantity
+			s.products[p.ID] = p
+		}
+	}
+	return nil
+}
+
+// ReleaseReservations releases the active reservations of an order
+func (s *Store) ReleaseReservations(_ context.Context, orderID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.release(func(r types.Reservation) bool { return r.OrderID == orderID })
+	return nil
+}
+
+// ReleaseExpiredReservations releases the active reservations that expired
+// by now and returns the IDs of their orders
+func (s *Store) ReleaseExpiredReservations(_ context.Context, now time.Time) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders := s.release(func(r types.Reservation) bool { return !r.ExpiresAt.After(now) })
+	var orderIDs []int
+	for _, id := range sortedKeys(orders) {
+		orderIDs = append(orderIDs, id)
+	}
+	return orderIDs, nil
+}
+
+// release releases the active reservations matching match and returns
+// their orders. Callers must hold the write lock.
+func (s *Store) release(match func(types.Reservation) bool) map[int]bool {
+	orders := map[int]bool{}
+	for id, r := range s.holds {
+		if r.Status == types.ReservationActive && match(r) {
+			r.Status = types.ReservationReleased
+			s.holds[id] = r
+			orders[r.OrderID] = true
+		}
+	}
+	return orders
+}
+
+// GetReservations gets the reservations of an order in ID order
+func (s *Store) GetReservations(_ context.Context, orderID int) ([]types.Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reservations := []types.Reservation{}
+	for _, id := range sortedKeys(s.holds) {
+		if r := s.holds[id]; r.OrderID == orderID {
+			reservations = append(reservations, r)
+		}
+	}
+	return reservations, nil
+}
+
+// CreateVariant adds a variant to a product, creating the options and
+// values it names
+func (s *Store) CreateVariant(_ context.Context, v types.Variant) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[v.ProductID]; !ok {
+		return 0, types.Errorf(types.ErrNotFound, "product %d not found", v.ProductID)
+	}
+	values := map[string]string{}
+	for _, o := range v.Options {
+		values[o.Name] = o.Value
+	}
+	if len(values) != len(v.Options) {
+		return 0, types.Errorf(types.ErrValidation, "a variant has one value of each option")
+	}
+
+	options := s.options[v.ProductID]
+	if len(options) == 0 {
+		for _, o := range v.Options {
+			options = append(options, types.ProductOption{Name: o.Name})
+		}
+	}
+	names := make([]string, len(options))
+	for i, o := range options {
+		names[i] = o.Name
+		if _, ok := values[o.Name]; !ok || len(options) != len(values) {
+			return 0, types.Errorf(types.ErrValidation, "the variants of product %d have the options %s", v.ProductID, strings.Join(names, ", "))
+		}
+	}
+
+	for _, existing := range s.variants {
+		if existing.SKU == v.SKU {
+			return 0, types.Errorf(types.ErrConflict, "SKU %s already exists", v.SKU)
+		}
+		if existing.ProductID == v.ProductID && slices.IndexFunc(existing.Options, func(o types.VariantOption) bool { return values[o.Name] != o.Value }) < 0 {
+			return 0, types.Errorf(types.ErrConflict, "variant %d of product %d already has these options", existing.ID, v.ProductID)
+		}
+	}
+
+	// Store the options in the product's order, adding new values.
+	v.Options = make([]types.VariantOption, len(options))
+	for i, o := range options {
+		v.Options[i] = types.VariantOption{Name: o.Name, Value: values[o.Name]}
+		if !slices.Contains(o.Values, values[o.Name]) {
+			options[i].Values = append(slices.Clone(o.Values), values[o.Name])
+		}
+	}
+	s.options[v.ProductID] = options
+
+	v.ID = s.nextID("product_variants")
+	v.Available = 0
+	v.CreatedAt = now()
+	if v.Price != nil {
+		price := *v.Price
+		v.Price = &price
+	}
+	s.variants[v.ID] = v
+	return v.ID, nil
+}
+
+// UpdateVariant updates the SKU, price, stock and image of a variant. Like
+// an SQL UPDATE, unknown IDs are a no-op.
+func (s *Store) UpdateVariant(_ context.Context, v types.Variant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.variants[v.ID]
+	if !ok {
+		return nil
+	}
+	for _, other := range s.variants {
+		if other.ID != v.ID && other.SKU == v.SKU {
+			return types.Errorf(types.ErrConflict, "SKU %s already exists", v.SKU)
+		}
+	}
+	existing.SKU = v.SKU
+	existing.Price = nil
+	if v.Price != nil {
+		price := *v.Price
+		existing.Price = &price
+	}
+	existing.Quantity = v.Quantity
+	existing.Image = v.Image
+	s.variants[v.ID] = existing
+	return nil
+}
+
+// GetVariantsByProduct gets the variants of products, sorted by ID
+func (s *Store) GetVariantsByProduct(_ context.Context, productIDs []int) ([]types.Variant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	variants := []types.Variant{}
+	for _, id := range sortedKeys(s.variants) {
+		if v := s.variants[id]; slices.Contains(productIDs, v.ProductID) {
+			variants = append(variants, s.withVariantAvailable(v))
+		}
+	}
+	return variants, nil
+}
+
+// GetProductOptions gets the options of a product and their values
+func (s *Store) GetProductOptions(_ context.Context, productID int) ([]types.ProductOption, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	options := []types.ProductOption{}
+	for _, o := range s.options[productID] {
+		options = append(options, types.ProductOption{Name: o.Name, Values: slices.Clone(o.Values)})
+	}
+	return options, nil
+}
+
+// CreateShippingMethod creates a shipping method
+func (s *Store) CreateShippingMethod(_ context.Context, m types.ShippingMethod) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m.ID = s.nextID("shipping_methods")
+	m.CreatedAt = now()
+	m.Countries = upper(m.Countries)
+	s.shipping[m.ID] = m
+	return m.ID, nil
+}
+
+// GetShippingMethodByID gets a shipping method by ID
+func (s *Store) GetShippingMethodByID(_ context.Context, id int) (*types.ShippingMethod, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.shipping[id]
+	if !ok {
+		return nil, types.Errorf(types.ErrNotFound, "shipping method %d not found", id)
+	}
+	m.Countries = append([]string{}, m.Countries...)
+	return &m, nil
+}
+
+// ListShippingMethods lists every shipping method in ID order
+func (s *Store) ListShippingMethods(_ context.Context) ([]types.ShippingMethod, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	methods := []types.ShippingMethod{}
+	for _, id := range sortedKeys(s.shipping) {
+		m := s.shipping[id]
+		m.Countries = append([]string{}, m.Countries...)
+		methods = append(methods, m)
+	}
+	return methods, nil
+}
+
+// upper copies country codes in upper case, as the SQL stores keep them.
+func upper(codes []string) []string {
+	u := make([]string, len(codes))
+	for i, c := range codes {
+		u[i] = strings.ToUpper(c)
+	}
+	return u
+}
+
+// utc copies t in UTC, as the SQL stores read times back.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// matches reports whether filter matches a product. Callers must hold the
+// lock.
+func (s *Store) matches(productID int, filter types.ProductFilter) bool {
+	if len(filter.CategoryIDs) > 0 && !slices.ContainsFunc(s.productCategories[productID], func(id int) bool {
+		return slices.Contains(filter.CategoryIDs, id)
+	}) {
+		return false
+	}
+	for _, name := range filter.Tags {
+		if !slices.ContainsFunc(s.productTags[productID], func(id int) bool { return s.tags[id].Name == name }) {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateCategory creates a category under its parent, if it has one
+func (s *Store) CreateCategory(_ context.Context, c types.Category) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.ParentID != nil {
+		if _, ok := s.categories[*c.ParentID]; !ok {
+			return 0, types.Errorf(types.ErrValidation, "category %d not found", *c.ParentID)
+		}
+	}
+	if err := s.checkSlug(c); err != nil {
+		return 0, err
+	}
+
+	c.ID = s.nextID("categories")
+	c.ParentID = cloneID(c.ParentID)
+	c.CreatedAt = now()
+	s.categories[c.ID] = c
+	return c.ID, nil
+}
+
+// UpdateCategory updates a category, refusing to move it under itself or
+// one of its descendants
+func (s *Store) UpdateCategory(_ context.Context, c types.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.categories[c.ID]
+	if !ok {
+		return types.Errorf(types.ErrNotFound, "category %d not found", c.ID)
+	}
+	for id := c.ParentID; id != nil; {
+		if *id == c.ID {
+			return types.Errorf(types.ErrValidation, "category %d can't be moved under itself or its subcategories", c.ID)
+		}
+		parent, ok := s.categories[*id]
+		if !ok {
+			return types.Errorf(types.ErrValidation, "category %d not found", *id)
+		}
+		id = parent.ParentID
+	}
+	if err := s.checkSlug(c); err != nil {
+		return err
+	}
+
+	existing.ParentID = cloneID(c.ParentID)
+	existing.Name = c.Name
+	existing.Slug = c.Slug
+	existing.SortOrder = c.SortOrder
+	s.categories[c.ID] = existing
+	return nil
+}
+
+// checkSlug refuses the slug of another category. Callers must hold the
+// lock.
+func (s *Store) checkSlug(c types.Category) error {
+	for _, other := range s.categories {
+		if other.ID != c.ID && other.Slug == c.Slug {
+			return types.Errorf(types.ErrConflict, "category %s already exists", c.Slug)
+		}
+	}
+	return nil
+}
+
+// DeleteCategory deletes a category without children and unlinks its
+// products and promotions
+func (s *Store) DeleteCategory(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.categories[id]; !ok {
+		return types.Errorf(types.ErrNotFound, "category %d not found", id)
+	}
+	for _, c := range s.categories {
+		if c.ParentID != nil && *c.ParentID == id {
+			return types.Errorf(types.ErrConflict, "category %d has subcategories", id)
+		}
+	}
+
+	for productID, ids := range s.productCategories {
+		s.productCategories[productID] = slices.DeleteFunc(slices.Clone(ids), func(c int) bool { return c == id })
+	}
+	for promotionID, p := range s.promotions {
+		p.CategoryIDs = slices.DeleteFunc(slices.Clone(p.CategoryIDs), func(c int) bool { return c == id })
+		s.promotions[promotionID] = p
+	}
+	delete(s.categories, id)
+	return nil
+}
+
+// ListCategories lists every category by sort order, then name
+func (s *Store) ListCategories(_ context.Context) ([]types.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedCategories(func(types.Category) bool { return true }), nil
+}
+
+// sortedCategories returns the categories keep matches by sort order, then
+// name. Callers must hold the lock.
+func (s *Store) sortedCategories(keep func(types.Category) bool) []types.Category {
+	categories := []types.Category{}
+	for _, id := range sortedKeys(s.categories) {
+		if c := s.categories[id]; keep(c) {
+			c.ParentID = cloneID(c.ParentID)
+			categories = append(categories, c)
+		}
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		a, b := categories[i], categories[j]
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder < b.SortOrder
+		}
+		return a.Name < b.Name
+	})
+	return categories
+}
+
+// CreateTag creates a tag
+func (s *Store) CreateTag(_ context.Context, name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tagID(name); ok {
+		return 0, types.Errorf(types.ErrConflict, "tag %s already exists", name)
+	}
+	return s.createTag(name), nil
+}
+
+// createTag adds a tag. Callers must hold the write lock.
+func (s *Store) createTag(name string) int {
+	t := types.Tag{ID: s.nextID("tags"), Name: name, CreatedAt: now()}
+	s.tags[t.ID] = t
+	return t.ID
+}
+
+// tagID returns the ID of the tag with a name. Callers must hold the lock.
+func (s *Store) tagID(name string) (int, bool) {
+	for _, t := range s.tags {
+		if t.Name == name {
+			return t.ID, true
+		}
+	}
+	return 0, false
+}
+
+// RenameTag renames a tag
+func (s *Store) RenameTag(_ context.Context, id int, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tags[id]
+	if !ok {
+		return types.Errorf(types.ErrNotFound, "tag %d not found", id)
+	}
+	if other, ok := s.tagID(name); ok && other != id {
+		return types.Errorf(types.ErrConflict, "tag %s already exists", name)
+	}
+	t.Name = name
+	s.tags[id] = t
+	return nil
+}
+
+// DeleteTag deletes a tag and unlinks its products
+func (s *Store) DeleteTag(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tags[id]; !ok {
+		return types.Errorf(types.ErrNotFound, "tag %d not found", id)
+	}
+	for productID, ids := range s.productTags {
+		s.productTags[productID] = slices.DeleteFunc(slices.Clone(ids), func(t int) bool { return t == id })
+	}
+	delete(s.tags, id)
+	return nil
+}
+
+// ListTags lists every tag by name
+func (s *Store) ListTags(_ context.Context) ([]types.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags := make([]types.Tag, 0, len(s.tags))
+	for _, t := range s.tags {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// SetProductCategories replaces the categories of a product
+func (s *Store) SetProductCategories(_ context.Context, productID int, categoryIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[productID]; !ok {
+		return types.Errorf(types.ErrNotFound, "product %d not found", productID)
+	}
+	ids := []int{}
+	for _, id := range categoryIDs {
+		if _, ok := s.categories[id]; !ok {
+			return types.Errorf(types.ErrValidation, "category %d not found", id)
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	s.productCategories[productID] = ids
+	return nil
+}
+
+// SetProductTags replaces the tags of a product, creating the tags it names
+// that don't exist yet
+func (s *Store) SetProductTags(_ context.Context, productID int, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[productID]; !ok {
+		return types.Errorf(types.ErrNotFound, "product %d not found", productID)
+	}
+	ids := []int{}
+	for _, name := range tags {
+		id, ok := s.tagID(name)
+		if !ok {
+			id = s.createTag(name)
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	s.productTags[productID] = ids
+	return nil
+}
+
+// GetProductCategories gets the categories of a product, sorted like
+// ListCategories
+func (s *Store) GetProductCategories(_ context.Context, productID int) ([]types.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedCategories(func(c types.Category) bool {
+		return slices.Contains(s.productCategories[productID], c.ID)
+	}), nil
+}
+
+// GetCategoryIDsByProduct maps products to the IDs of their categories,
+// leaving out products without any
+func (s *Store) GetCategoryIDsByProduct(_ context.Context, productIDs []int) (map[int][]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categoryIDs := map[int][]int{}
+	for _, productID := range productIDs {
+		if ids := slices.Clone(s.productCategories[productID]); len(ids) > 0 {
+			slices.Sort(ids)
+			categoryIDs[productID] = ids
+		}
+	}
+	return categoryIDs, nil
+}
+
+// GetProductTags gets the names of the tags of a product, sorted
+func (s *Store) GetProductTags(_ context.Context, productID int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags := []string{}
+	for _, id := range s.productTags[productID] {
+		tags = append(tags, s.tags[id].Name)
+	}
+	slices.Sort(tags)
+	return tags, nil
+}
+
+// cloneID copies an optional ID, so stored values don't share it with
+// callers.
+func cloneID(id *int) *int {
+	if id == nil {
+		return nil
+	}
+	v := *id
+	return &v
+}
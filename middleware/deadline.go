@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// requestContextKey holds the request's context as it was before Deadline.
+const requestContextKey contextKey = "requestContext"
+
+// Deadline bounds every request's context by d. Stores run their queries
+// with the request context, so a slow database or a client that goes away
+// cancels the queries instead of leaving them running. A d of zero or less
+// disables the deadline. Streamed responses opt out with NoDeadline.
+func Deadline(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			ctx = context.WithValue(ctx, requestContextKey, r.Context())
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// NoDeadline lifts Deadline off a handler whose response is streamed for as
+// long as the client reads it, like a list export. Its context is still
+// canceled when the client goes away.
+func NoDeadline(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parent, ok := r.Context().Value(requestContextKey).(context.Context)
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		// Keep the values added since Deadline, like the user ID, but
+		// only the cancellation of the request itself.
+		ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+		defer cancel()
+		stop := context.AfterFunc(parent, cancel)
+		defer stop()
+
+		next(w, r.WithContext(ctx))
+	}
+}
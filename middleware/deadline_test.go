@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeadline(t *testing.T) {
+	type key struct{}
+
+	// serve runs h behind Deadline, adding a value to the context like
+	// the auth middleware does.
+	serve := func(ctx context.Context, h http.HandlerFunc) {
+		inner := func(w http.ResponseWriter, r *http.Request) {
+			h(w, r.WithContext(context.WithValue(r.Context(), key{}, "user")))
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		Deadline(time.Millisecond)(http.HandlerFunc(inner)).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	t.Run("should cancel requests after the deadline", func(t *testing.T) {
+		serve(context.Background(), func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			if err := r.Context().Err(); err != context.DeadlineExceeded {
+				t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+			}
+		})
+	})
+
+	t.Run("should let streamed responses outlast the deadline", func(t *testing.T) {
+		serve(context.Background(), NoDeadline(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(10 * time.Millisecond)
+			if err := r.Context().Err(); err != nil {
+				t.Errorf("expected the context to be alive, got %v", err)
+			}
+			if got := r.Context().Value(key{}); got != "user" {
+				t.Errorf("expected the context's values to be kept, got %v", got)
+			}
+		}))
+	})
+
+	t.Run("should cancel streamed responses when the client goes away", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		serve(ctx, NoDeadline(func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+				t.Error("expected the context to be canceled with the request")
+			}
+		}))
+	})
+}
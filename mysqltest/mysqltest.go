@@ -0,0 +1,119 @@
+// Package mysqltest : In-process MySQL-compatible server for tests
+//
+// New gives every test its own freshly migrated database on a server that
+// runs inside the test binary, so tests of the MySQL stores and their
+// migrations run offline with go test:
+//
+//	func TestCheckout(t *testing.T) {
+//		conn := mysqltest.New(t)
+//		store := product.NewStore(conn)
+//		...
+//	}
+package mysqltest
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/davidado/go-api-reference/cmd/migrate/migrations"
+	"github.com/davidado/go-api-reference/db"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/go-sql-driver/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	startOnce sync.Once
+	addr      string
+	startErr  error
+
+	// databases numbers the databases created by New.
+	databases atomic.Int64
+)
+
+// start starts the shared server on a free local port.
+func start() {
+	// The server logs every connection at info level.
+	logrus.SetLevel(logrus.WarnLevel)
+
+	// Foreign keys need indexes on the referenced primary keys.
+	provider := memory.NewDBProvider()
+	provider.WithOption(memory.NativeIndexProvider(true))
+	engine := sqle.NewDefault(provider)
+
+	srv, err := server.NewServer(server.Config{
+		Protocol: "tcp",
+		Address:  "127.0.0.1:0",
+	}, engine, memory.NewSessionBuilder(provider), nil)
+	if err != nil {
+		startErr = err
+		return
+	}
+
+	addr = srv.Listener.Addr().String()
+	go srv.Start()
+}
+
+// config returns the driver config for dbName on the shared server.
+func config(dbName string) mysql.Config {
+	cfg := mysql.NewConfig()
+	cfg.User = "root"
+	cfg.Addr = addr
+	cfg.DBName = dbName
+	cfg.Net = "tcp"
+	cfg.ParseTime = true
+	cfg.MultiStatements = true // for the migrations
+	return *cfg
+}
+
+// New creates an empty database, applies the real MySQL migrations to it
+// and returns a connection. The database is dropped when the test ends.
+func New(t testing.TB) *db.DB {
+	t.Helper()
+
+	startOnce.Do(start)
+	if startErr != nil {
+		t.Fatalf("mysqltest: start server: %v", startErr)
+	}
+
+	name := fmt.Sprintf("test_%d", databases.Add(1))
+
+	admin, err := db.NewMySQLStorage(config(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("mysqltest: create database: %v", err)
+	}
+	t.Cleanup(func() {
+		admin, err := db.NewMySQLStorage(config(""))
+		if err != nil {
+			return
+		}
+		defer admin.Close()
+		admin.Exec("DROP DATABASE IF EXISTS " + name)
+	})
+
+	sqlDB, err := db.NewMySQLStorage(config(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	dialect, err := db.DialectFor(db.MySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := db.New(sqlDB, dialect)
+	if err := migrations.Up(conn); err != nil {
+		t.Fatalf("mysqltest: migrate: %v", err)
+	}
+
+	return conn
+}
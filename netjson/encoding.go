@@ -0,0 +1,502 @@
+package netjson
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidado/go-api-reference/middleware"
+	"github.com/davidado/go-api-reference/types"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Format : A response media type selected through the Accept header
+type Format struct {
+	MediaType string
+
+	// Encode writes a single value.
+	Encode func(w io.Writer, v any) error
+
+	// NewListEncoder starts a list response whose elements have type elem.
+	NewListEncoder func(w io.Writer, elem reflect.Type) ListEncoder
+
+	// FlushEach flushes the response after every list element so clients
+	// can process rows as they arrive.
+	FlushEach bool
+}
+
+// ListEncoder : Writes a list response one element at a time
+type ListEncoder interface {
+	Encode(v any) error
+	Close() error
+}
+
+var (
+	formatsMu sync.RWMutex
+	// formats are in order of preference; the first one is the default.
+	formats = []Format{
+		{MediaType: "application/json", Encode: encodeJSON, NewListEncoder: newJSONList},
+		{MediaType: "application/x-ndjson", Encode: encodeNDJSON, NewListEncoder: newNDJSONList, FlushEach: true},
+		{MediaType: "text/csv", Encode: encodeCSV, NewListEncoder: newCSVList},
+		{MediaType: "application/msgpack", Encode: encodeMsgpack, NewListEncoder: newMsgpackList},
+	}
+)
+
+// RegisterFormat adds a response format, replacing any existing format with
+// the same media type.
+func RegisterFormat(f Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	for i := range formats {
+		if formats[i].MediaType == f.MediaType {
+			formats[i] = f
+			return
+		}
+	}
+	formats = append(formats, f)
+}
+
+// MediaTypes returns the media types of the registered formats.
+func MediaTypes() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	mediaTypes := make([]string, len(formats))
+	for i, f := range formats {
+		mediaTypes[i] = f.MediaType
+	}
+	return mediaTypes
+}
+
+// Respond writes v in the format the client asked for in its Accept header.
+func Respond(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	f, err := negotiate(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := f.Encode(&buf, v); err != nil {
+		WriteError(w, r, err)
+		return err
+	}
+
+	setContentType(w, f)
+	w.WriteHeader(status)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// WriteList streams a list response in the format the client asked for.
+// each is called with a yield function that writes one element; rows are
+// written as they are yielded rather than collected first. If each fails
+// before yielding anything the error is written as a problem response,
+// otherwise the response is cut short and the error logged.
+func WriteList[T any](w http.ResponseWriter, r *http.Request, status int, each func(yield func(T) error) error) error {
+	f, err := negotiate(r)
+	if err != nil {
+		WriteError(w, r, err)
+		return err
+	}
+
+	lw := &lazyWriter{w: w, status: status, format: f}
+	enc := f.NewListEncoder(lw, reflect.TypeFor[T]())
+
+	err = each(func(v T) error {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		if f.FlushEach {
+			lw.flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		if !lw.started {
+			WriteError(w, r, err)
+		} else {
+			log.Printf("request %s: %s %s: list response aborted: %v", middleware.GetRequestIDFromContext(r.Context()), r.Method, r.URL.Path, err)
+		}
+		return err
+	}
+
+	// An empty list may not have written anything yet.
+	lw.start()
+	return nil
+}
+
+// negotiate picks the format for the request's Accept header, defaulting
+// to JSON when the header is missing.
+func negotiate(r *http.Request) (Format, error) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return formats[0], nil
+	}
+
+	for _, mediaRange := range parseAccept(accept) {
+		for _, f := range formats {
+			if matchMediaRange(mediaRange, f.MediaType) {
+				return f, nil
+			}
+		}
+	}
+
+	supported := make([]string, len(formats))
+	for i, f := range formats {
+		supported[i] = f.MediaType
+	}
+	return Format{}, types.Errorf(types.ErrNotAcceptable, "none of the accepted media types are supported, use one of %s", strings.Join(supported, ", "))
+}
+
+// parseAccept returns the media ranges of an Accept header ordered by
+// quality, dropping ranges with q=0.
+func parseAccept(header string) []string {
+	type mediaRange struct {
+		value string
+		q     float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType, q})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	values := make([]string, len(ranges))
+	for i, mr := range ranges {
+		values[i] = mr.value
+	}
+	return values
+}
+
+func matchMediaRange(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+
+	prefix, ok := strings.CutSuffix(mediaRange, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+func setContentType(w http.ResponseWriter, f Format) {
+	ct := f.MediaType
+	if strings.HasPrefix(ct, "text/") {
+		ct += "; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", ct)
+}
+
+// lazyWriter delays writing the response header until the first byte of
+// the body so errors found before that can still become problem responses.
+type lazyWriter struct {
+	w       http.ResponseWriter
+	status  int
+	format  Format
+	started bool
+}
+
+func (lw *lazyWriter) start() {
+	if lw.started {
+		return
+	}
+	lw.started = true
+	setContentType(lw.w, lw.format)
+	lw.w.WriteHeader(lw.status)
+}
+
+func (lw *lazyWriter) Write(p []byte) (int, error) {
+	lw.start()
+	return lw.w.Write(p)
+}
+
+func (lw *lazyWriter) flush() {
+	if f, ok := lw.w.(http.Flusher); ok && lw.started {
+		f.Flush()
+	}
+}
+
+// JSON
+
+func encodeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type jsonList struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONList(w io.Writer, _ reflect.Type) ListEncoder {
+	return &jsonList{w: w}
+}
+
+func (l *jsonList) Encode(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sep := ","
+	if l.count == 0 {
+		sep = "["
+	}
+	l.count++
+
+	_, err = io.WriteString(l.w, sep+string(b))
+	return err
+}
+
+func (l *jsonList) Close() error {
+	if l.count == 0 {
+		_, err := io.WriteString(l.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(l.w, "]\n")
+	return err
+}
+
+// NDJSON
+
+func encodeNDJSON(w io.Writer, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return json.NewEncoder(w).Encode(v)
+	}
+
+	enc := json.NewEncoder(w)
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ndjsonList struct {
+	enc *json.Encoder
+}
+
+func newNDJSONList(w io.Writer, _ reflect.Type) ListEncoder {
+	return &ndjsonList{enc: json.NewEncoder(w)}
+}
+
+func (l *ndjsonList) Encode(v any) error {
+	return l.enc.Encode(v)
+}
+
+func (l *ndjsonList) Close() error {
+	return nil
+}
+
+// CSV
+
+func encodeCSV(w io.Writer, v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		l := newCSVList(w, rv.Type())
+		if err := l.Encode(v); err != nil {
+			return err
+		}
+		return l.Close()
+	}
+
+	l := newCSVList(w, rv.Type().Elem())
+	for i := 0; i < rv.Len(); i++ {
+		if err := l.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return l.Close()
+}
+
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+type csvList struct {
+	w       *csv.Writer
+	columns []csvColumn
+	started bool
+}
+
+func newCSVList(w io.Writer, elem reflect.Type) ListEncoder {
+	return &csvList{w: csv.NewWriter(w), columns: csvColumns(elem)}
+}
+
+// csvColumns derives the columns of a struct from its `csv` tags, falling
+// back to the `json` name. Fields tagged `csv:"-"` are left out.
+func csvColumns(t reflect.Type) []csvColumn {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return []csvColumn{{name: "value"}}
+	}
+
+	var columns []csvColumn
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name, ok := f.Tag.Lookup("csv")
+		if !ok {
+			name, _, _ = strings.Cut(f.Tag.Get("json"), ",")
+		}
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		columns = append(columns, csvColumn{name: name, index: f.Index})
+	}
+	return columns
+}
+
+func (l *csvList) Encode(v any) error {
+	if !l.started {
+		if err := l.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	record := make([]string, len(l.columns))
+	for i, c := range l.columns {
+		fv := rv
+		if c.index != nil {
+			fv = rv.FieldByIndex(c.index)
+		}
+		s, err := csvValue(fv)
+		if err != nil {
+			return err
+		}
+		record[i] = s
+	}
+
+	return l.w.Write(record)
+}
+
+func (l *csvList) writeHeader() error {
+	l.started = true
+
+	header := make([]string, len(l.columns))
+	for i, c := range l.columns {
+		header[i] = c.name
+	}
+	return l.w.Write(header)
+}
+
+func (l *csvList) Close() error {
+	if !l.started {
+		if err := l.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	l.w.Flush()
+	return l.w.Error()
+}
+
+func csvValue(v reflect.Value) (string, error) {
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return "", nil
+		}
+		return csvValue(v.Elem())
+	}
+
+	// Nested values are written as JSON.
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "", fmt.Errorf("csv: %w", err)
+	}
+	return string(b), nil
+}
+
+// MessagePack
+
+func encodeMsgpack(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// msgpackList buffers its elements: a MessagePack array needs its length
+// before the first element.
+type msgpackList struct {
+	w     io.Writer
+	elems []any
+}
+
+func newMsgpackList(w io.Writer, _ reflect.Type) ListEncoder {
+	return &msgpackList{w: w}
+}
+
+func (l *msgpackList) Encode(v any) error {
+	l.elems = append(l.elems, v)
+	return nil
+}
+
+func (l *msgpackList) Close() error {
+	if l.elems == nil {
+		l.elems = []any{}
+	}
+	return encodeMsgpack(l.w, l.elems)
+}
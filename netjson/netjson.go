@@ -1,26 +1,140 @@
+// Package netjson : JSON request and response helpers
 package netjson
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
+
+	"github.com/davidado/go-api-reference/config"
+	"github.com/davidado/go-api-reference/types"
 )
 
-// Parse parses the request body into a struct
-func Parse(r *http.Request, payload any) error {
-	if r.Body == nil {
-		return fmt.Errorf("missing request body")
+// ParseOption : Option for Parse
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	maxBytes              int64
+	disallowUnknownFields bool
+}
+
+// WithMaxBytes overrides the configured maximum request body size.
+func WithMaxBytes(n int64) ParseOption {
+	return func(o *parseOptions) {
+		o.maxBytes = n
 	}
+}
 
-	return json.NewDecoder(r.Body).Decode(payload)
+// DisallowUnknownFields rejects payloads with fields the target struct
+// doesn't declare.
+func DisallowUnknownFields() ParseOption {
+	return func(o *parseOptions) {
+		o.disallowUnknownFields = true
+	}
 }
 
+// Parse parses the request body into a struct. The body must be a single
+// JSON value sent with a JSON content type and no larger than
+// config.Envs.MaxBodyBytes.
+func Parse(r *http.Request, payload any, opts ...ParseOption) error {
+	o := parseOptions{
+		maxBytes:              config.Envs.MaxBodyBytes,
+		disallowUnknownFields: config.Envs.DisallowUnknownFields,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := checkContentType(r); err != nil {
+		return err
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return types.Errorf(types.ErrBadRequest, "missing request body")
+	}
+
+	// Read the whole (bounded) body so syntax errors can be reported with
+	// a line and column.
+	body, err := io.ReadAll(io.LimitReader(r.Body, o.maxBytes+1))
+	if err != nil {
+		return types.Errorf(types.ErrBadRequest, "read request body: %v", err)
+	}
+	if int64(len(body)) > o.maxBytes {
+		return types.Errorf(types.ErrPayloadTooLarge, "request body must not be larger than %d bytes", o.maxBytes)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if o.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(payload); err != nil {
+		return decodeError(body, err)
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return types.Errorf(types.ErrBadRequest, "request body must contain a single JSON value")
+	}
+
+	return nil
+}
+
+// Write writes v as a JSON response with the given status code
 func Write(w http.ResponseWriter, status int, v any) error {
-	w.Header().Add("Content-Type", "application/json")
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(v)
 }
 
-func WriteError(w http.ResponseWriter, status int, err error) {
-	Write(w, status, map[string]string{"error": err.Error()})
+// checkContentType accepts application/json and any +json media type.
+func checkContentType(r *http.Request) error {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return types.Errorf(types.ErrUnsupportedMediaType, "Content-Type must be application/json")
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return types.Errorf(types.ErrUnsupportedMediaType, "Content-Type %q is not supported, use application/json", ct)
+	}
+
+	return nil
+}
+
+func decodeError(body []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset points just past the offending byte.
+		line, col := position(body, syntaxErr.Offset-1)
+		return types.Errorf(types.ErrBadRequest, "malformed JSON at line %d, column %d: %v", line, col, syntaxErr)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		line, col := position(body, int64(len(body)))
+		return types.Errorf(types.ErrBadRequest, "malformed JSON at line %d, column %d: unexpected end of input", line, col)
+	case errors.As(err, &typeErr):
+		line, col := position(body, typeErr.Offset)
+		return types.Errorf(types.ErrBadRequest, "invalid value for field %q at line %d, column %d: expected %s", typeErr.Field, line, col, typeErr.Type)
+	case errors.Is(err, io.EOF):
+		return types.Errorf(types.ErrBadRequest, "request body must not be empty")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return types.Errorf(types.ErrBadRequest, "request body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return types.Errorf(types.ErrBadRequest, "invalid JSON payload: %v", err)
+	}
+}
+
+// position converts a byte offset into a 1-based line and column.
+func position(body []byte, offset int64) (line, col int) {
+	offset = max(0, min(offset, int64(len(body))))
+
+	before := body[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
 }
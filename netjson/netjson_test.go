@@ -0,0 +1,69 @@
+package netjson
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+func TestParse(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+
+	newRequest := func(contentType, body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        []ParseOption
+		wantKind    error
+		wantMessage string
+	}{
+		{name: "valid payload", contentType: "application/json; charset=utf-8", body: `{"name":"a"}`},
+		{name: "vendor json type", contentType: "application/vnd.api+json", body: `{"name":"a"}`},
+		{name: "missing content type", body: `{"name":"a"}`, wantKind: types.ErrUnsupportedMediaType},
+		{name: "form content type", contentType: "application/x-www-form-urlencoded", body: `name=a`, wantKind: types.ErrUnsupportedMediaType},
+		{name: "empty body", contentType: "application/json", wantKind: types.ErrBadRequest},
+		{name: "too large", contentType: "application/json", body: `{"name":"abcdef"}`, opts: []ParseOption{WithMaxBytes(8)}, wantKind: types.ErrPayloadTooLarge},
+		{name: "trailing data", contentType: "application/json", body: `{"name":"a"} {}`, wantKind: types.ErrBadRequest, wantMessage: "single JSON value"},
+		{name: "unknown field allowed", contentType: "application/json", body: `{"name":"a","extra":1}`},
+		{name: "unknown field rejected", contentType: "application/json", body: `{"name":"a","extra":1}`, opts: []ParseOption{DisallowUnknownFields()}, wantKind: types.ErrBadRequest, wantMessage: `unknown field "extra"`},
+		{name: "syntax error", contentType: "application/json", body: "{\n  \"name\": \"a\",,\n}", wantKind: types.ErrBadRequest, wantMessage: "line 2, column 15"},
+		{name: "truncated", contentType: "application/json", body: "{\"name\":", wantKind: types.ErrBadRequest, wantMessage: "unexpected end of input"},
+		{name: "wrong type", contentType: "application/json", body: `{"name":1}`, wantKind: types.ErrBadRequest, wantMessage: `field "name"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p payload
+			err := Parse(newRequest(tt.contentType, tt.body), &p, tt.opts...)
+
+			if tt.wantKind == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("expected %v, got %v", tt.wantKind, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("expected message to contain %q, got %q", tt.wantMessage, err.Error())
+			}
+		})
+	}
+}
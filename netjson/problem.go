@@ -0,0 +1,131 @@
+package netjson
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/davidado/go-api-reference/middleware"
+	"github.com/davidado/go-api-reference/types"
+	"github.com/davidado/go-api-reference/validator"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem documents.
+const ProblemContentType = "application/problem+json"
+
+// Problem : RFC 7807 problem details with a stable error code
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+
+	// Errors lists the invalid fields of a validation_failed problem.
+	Errors []validator.FieldError `json:"errors,omitempty"`
+	// Items lists every cart item checkout refused, each with its own
+	// code.
+	Items []ItemProblem `json:"items,omitempty"`
+}
+
+// ItemProblem : Why checkout refused an item of a cart
+type ItemProblem struct {
+	ProductID int    `json:"productId"`
+	VariantID int    `json:"variantId,omitempty"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail"`
+}
+
+type problemKind struct {
+	kind   error
+	status int
+	code   string
+	title  string
+}
+
+// problemKinds maps domain error kinds to their response. The codes are
+// part of the API contract; don't rename them.
+var problemKinds = []problemKind{
+	{types.ErrNotFound, http.StatusNotFound, "not_found", "Resource not found"},
+	{types.ErrConflict, http.StatusConflict, "conflict", "Resource already exists"},
+	{types.ErrOutOfStock, http.StatusConflict, "out_of_stock", "Product out of stock"},
+	{types.ErrValidation, http.StatusBadRequest, "validation_failed", "Validation failed"},
+	{types.ErrUnauthorized, http.StatusUnauthorized, "unauthorized", "Unauthorized"},
+	{types.ErrForbidden, http.StatusForbidden, "forbidden", "Forbidden"},
+	{types.ErrBadRequest, http.StatusBadRequest, "bad_request", "Bad request"},
+	{types.ErrPayment, http.StatusPaymentRequired, "payment_declined", "Payment declined"},
+	{types.ErrCoupon, http.StatusUnprocessableEntity, "coupon_not_applicable", "Coupon not applicable"},
+	{types.ErrPayloadTooLarge, http.StatusRequestEntityTooLarge, "payload_too_large", "Payload too large"},
+	{types.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type", "Unsupported media type"},
+	{types.ErrNotAcceptable, http.StatusNotAcceptable, "not_acceptable", "Not acceptable"},
+}
+
+var internalProblem = problemKind{nil, http.StatusInternalServerError, "internal", "Internal server error"}
+
+// WriteError writes err as an application/problem+json response. Domain
+// errors are reported with their message; anything else is logged with the
+// request ID and hidden from the client.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	p := NewProblem(r, err)
+	if p.Status == http.StatusInternalServerError {
+		log.Printf("request %s: %s %s: %v", p.RequestID, r.Method, r.URL.Path, err)
+	}
+
+	WriteProblem(w, p)
+}
+
+// NewProblem builds the problem document for err.
+func NewProblem(r *http.Request, err error) Problem {
+	pk := problemKindOf(err)
+
+	p := Problem{
+		Type:      "about:blank",
+		Title:     pk.title,
+		Status:    pk.status,
+		Instance:  r.URL.Path,
+		Code:      pk.code,
+		RequestID: middleware.GetRequestIDFromContext(r.Context()),
+	}
+	if pk.kind != nil {
+		p.Detail = err.Error()
+	}
+
+	var verr *validator.ValidationError
+	if errors.As(err, &verr) {
+		p.Errors = verr.Fields
+	}
+
+	var cerr *types.CartError
+	if errors.As(err, &cerr) {
+		for _, item := range cerr.Items {
+			p.Items = append(p.Items, ItemProblem{ProductID: item.ProductID, VariantID: item.VariantID, Code: Code(item.Err), Detail: item.Err.Error()})
+		}
+	}
+
+	return p
+}
+
+// Code returns the problem code err is reported with, e.g. out_of_stock.
+func Code(err error) string {
+	return problemKindOf(err).code
+}
+
+// problemKindOf finds the response of err's domain error kind.
+func problemKindOf(err error) problemKind {
+	for _, k := range problemKinds {
+		if errors.Is(err, k.kind) {
+			return k
+		}
+	}
+	return internalProblem
+}
+
+// WriteProblem writes a problem document
+func WriteProblem(w http.ResponseWriter, p Problem) error {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	return json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,35 @@
+package openapi
+
+import (
+	_ "embed" // docs UI
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+//go:embed docs.html
+var docsHTML string
+
+// SpecHandler serves the document as JSON. The document is encoded once.
+func SpecHandler(doc *Document) http.Handler {
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic("openapi: encode document: " + err.Error())
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
+
+// DocsHandler serves the bundled docs UI, which renders the document at
+// specURL.
+func DocsHandler(specURL string) http.Handler {
+	page := strings.ReplaceAll(docsHTML, "{{SPEC_URL}}", specURL)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+}
@@ -0,0 +1,261 @@
+// Package openapi : OpenAPI 3.1 document generation
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// Operation : Describes one registered route
+type Operation struct {
+	Method  string
+	Path    string // mux path template relative to the API prefix
+	Summary string
+	Tags    []string
+
+	// Auth marks routes wrapped in auth.WithJWTAuth.
+	Auth bool
+
+	// Params describes the path variables and the query parameters.
+	// Path variables not listed here are documented as strings.
+	Params []Param
+
+	// Request is a value of the payload type, nil when there's no body.
+	Request any
+
+	// Response is a value of the success response type; Status defaults
+	// to 200.
+	Response any
+	Status   int
+
+	// MediaTypes lists the response formats. Defaults to application/json.
+	MediaTypes []string
+}
+
+// Param : A path or query parameter
+type Param struct {
+	Name        string
+	Type        string // JSON schema type, e.g. "integer"
+	Description string
+
+	// Query marks a query parameter; Required only applies to those, as
+	// path variables always are.
+	Query    bool
+	Required bool
+}
+
+// Info : Document metadata
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document : OpenAPI document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers,omitempty"`
+	Paths      map[string]map[string]*PathItem `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Server : API base URL
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem : Operation object of a path and method
+type PathItem struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter : Operation parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody : Operation request body
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response : Operation response
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType : Schema of a body in one media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components : Reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme : Authentication scheme
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+const jwtScheme = "jwt"
+
+var pathVar = regexp.MustCompile(`\{([^}:]+)(?::[^}]+)?\}`)
+
+// Build creates the document for ops served under prefix. errorBody is a
+// value of the problem type used for error responses.
+func Build(info Info, prefix string, errorBody any, ops []Operation) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]*PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				jwtScheme: {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "Authorization",
+					Description: "JWT returned by POST /login",
+				},
+			},
+		},
+	}
+	if prefix != "" {
+		doc.Servers = []Server{{URL: prefix}}
+	}
+
+	g := &generator{schemas: doc.Components.Schemas}
+	errorSchema := g.schemaOf(errorBody)
+
+	for _, op := range ops {
+		path := pathVar.ReplaceAllString(op.Path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(op.Method)] = g.pathItem(op, path, errorSchema)
+	}
+
+	return doc
+}
+
+// Has reports whether the document describes method on path, where path is
+// a mux path template relative to the document's server.
+func (d *Document) Has(method, path string) bool {
+	item, ok := d.Paths[pathVar.ReplaceAllString(path, "{$1}")]
+	if !ok {
+		return false
+	}
+	_, ok = item[strings.ToLower(method)]
+	return ok
+}
+
+func (g *generator) pathItem(op Operation, path string, errorSchema *Schema) *PathItem {
+	item := &PathItem{
+		OperationID: operationID(op.Method, path),
+		Summary:     op.Summary,
+		Tags:        op.Tags,
+		Responses:   map[string]Response{},
+	}
+
+	for _, m := range pathVar.FindAllStringSubmatch(path, -1) {
+		p := Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		for _, param := range op.Params {
+			if !param.Query && param.Name == m[1] {
+				p.Description = param.Description
+				if param.Type != "" {
+					p.Schema = &Schema{Type: param.Type}
+				}
+			}
+		}
+		item.Parameters = append(item.Parameters, p)
+	}
+	for _, param := range op.Params {
+		if !param.Query {
+			continue
+		}
+		p := Parameter{Name: param.Name, In: "query", Required: param.Required, Description: param.Description, Schema: &Schema{Type: "string"}}
+		if param.Type != "" {
+			p.Schema = &Schema{Type: param.Type}
+		}
+		item.Parameters = append(item.Parameters, p)
+	}
+
+	if op.Request != nil {
+		item.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: g.schemaOf(op.Request)}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := Response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		mediaTypes := op.MediaTypes
+		if len(mediaTypes) == 0 {
+			mediaTypes = []string{"application/json"}
+		}
+
+		schema := g.schemaOf(op.Response)
+		resp.Content = map[string]MediaType{}
+		for _, mt := range mediaTypes {
+			resp.Content[mt] = MediaType{Schema: schema}
+		}
+	}
+	item.Responses[fmt.Sprint(status)] = resp
+	item.Responses["default"] = Response{
+		Description: "Error",
+		Content:     map[string]MediaType{"application/problem+json": {Schema: errorSchema}},
+	}
+
+	if op.Auth {
+		item.Security = []map[string][]string{{jwtScheme: {}}}
+	}
+
+	return item
+}
+
+// operationID derives a stable ID such as "getUsersByUserID".
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" {
+			continue
+		}
+		if name, ok := strings.CutPrefix(seg, "{"); ok {
+			b.WriteString("By")
+			seg = strings.TrimSuffix(name, "}")
+		}
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,237 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema : JSON Schema (draft 2020-12, as used by OpenAPI 3.1)
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"` // string or []string
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+type generator struct {
+	schemas map[string]*Schema
+}
+
+func (g *generator) schemaOf(v any) *Schema {
+	return g.schema(reflect.TypeOf(v))
+}
+
+// schema returns the schema of t. Named structs are added to the
+// components and referenced.
+func (g *generator) schema(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		if s.Ref == "" {
+			s.Type = []any{s.Type, "null"}
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate.
+			g.schemas[t.Name()] = &Schema{}
+			*g.schemas[t.Name()] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+
+	return &Schema{}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schema(f.Type)
+		if required := applyValidateTag(prop, f.Tag.Get("validate")); required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+
+	return s
+}
+
+// applyValidateTag maps go-playground validator rules onto schema
+// constraints and reports whether the field is required. Rules after
+// "dive" apply to the items of a slice.
+func applyValidateTag(s *Schema, tag string) (required bool) {
+	if tag == "" || tag == "-" {
+		return false
+	}
+
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "required":
+			required = true
+		case "omitempty":
+		case "dive":
+			// Referenced component schemas are shared, so only inline item
+			// schemas take the element rules.
+			if s.Items != nil && s.Items.Ref == "" {
+				applyValidateTag(s.Items, strings.Join(rules[i+1:], ","))
+			}
+			return required
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(s, v))
+			}
+		case "unique":
+			s.UniqueItems = true
+		case "len":
+			applyBound(s, param, "min")
+			applyBound(s, param, "max")
+		case "min", "gte":
+			applyBound(s, param, "min")
+		case "max", "lte":
+			applyBound(s, param, "max")
+		case "gt":
+			applyBound(s, param, "gt")
+		case "lt":
+			applyBound(s, param, "lt")
+		}
+	}
+
+	return required
+}
+
+func applyBound(s *Schema, param, bound string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	i := int(n)
+
+	switch schemaType(s) {
+	case "string":
+		switch bound {
+		case "min":
+			s.MinLength = &i
+		case "max":
+			s.MaxLength = &i
+		case "gt":
+			i++
+			s.MinLength = &i
+		case "lt":
+			i--
+			s.MaxLength = &i
+		}
+	case "array":
+		switch bound {
+		case "min":
+			s.MinItems = &i
+		case "max":
+			s.MaxItems = &i
+		case "gt":
+			i++
+			s.MinItems = &i
+		case "lt":
+			i--
+			s.MaxItems = &i
+		}
+	case "integer", "number":
+		switch bound {
+		case "min":
+			s.Minimum = &n
+		case "max":
+			s.Maximum = &n
+		case "gt":
+			s.ExclusiveMinimum = &n
+		case "lt":
+			s.ExclusiveMaximum = &n
+		}
+	}
+}
+
+func schemaType(s *Schema) string {
+	switch t := s.Type.(type) {
+	case string:
+		return t
+	case []any:
+		if len(t) > 0 {
+			if name, ok := t[0].(string); ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func enumValue(s *Schema, v string) any {
+	switch schemaType(s) {
+	case "integer", "number":
+		n := json.Number(v)
+		if _, err := n.Float64(); err == nil {
+			return n
+		}
+	}
+	return v
+}
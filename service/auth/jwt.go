@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/davidado/go-api-reference/config"
@@ -39,48 +41,33 @@ func CreateJWT(secret []byte, userID int) (string, error) {
 // WithJWTAuth adds JWT authentication to a handler
 func WithJWTAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Get the token from the user request.
-		tokenString := getTokenFromRequest(r)
-		if tokenString == "" {
-			netjson.WriteError(w, http.StatusUnauthorized, fmt.Errorf("no token provided"))
+		u, ok := authenticate(w, r, store)
+		if !ok {
 			return
 		}
 
-		// Validate the JWT.
-		token, err := validateToken(tokenString)
-		if err != nil {
-			log.Printf("failed to validate token: %v", err)
-			permissionDenied(w)
-			return
-		}
-
-		if !token.Valid {
-			log.Println("invalid token")
-			permissionDenied(w)
-			return
-		}
+		// Set context "userID" to the user ID.
+		ctx := context.WithValue(r.Context(), UserKey, u.ID)
+		r = r.WithContext(ctx)
 
-		// Fetch the userID from the db using the ID from the token.
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || !token.Valid {
-			permissionDenied(w)
-			return
-		}
+		handlerFunc(w, r)
+	}
+}
 
-		userID, err := strconv.Atoi(claims["userID"].(string))
-		if err != nil {
-			permissionDenied(w)
+// WithAdminAuth is WithJWTAuth for handlers only admins may use: users
+// whose email is listed in config.Envs.AdminEmails.
+func WithAdminAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := authenticate(w, r, store)
+		if !ok {
 			return
 		}
 
-		u, err := store.GetUserByID(userID)
-		if err != nil {
-			log.Printf("failed to get user by ID: %v", err)
-			permissionDenied(w)
+		if !IsAdmin(u) {
+			netjson.WriteError(w, r, types.Errorf(types.ErrForbidden, "admins only"))
 			return
 		}
 
-		// Set context "userID" to the user ID.
 		ctx := context.WithValue(r.Context(), UserKey, u.ID)
 		r = r.WithContext(ctx)
 
@@ -88,6 +75,60 @@ func WithJWTAuth(handlerFunc http.HandlerFunc, store types.UserStore) http.Handl
 	}
 }
 
+// IsAdmin reports whether u is an admin
+func IsAdmin(u *types.User) bool {
+	return slices.ContainsFunc(config.Envs.AdminEmails, func(email string) bool {
+		return strings.EqualFold(email, u.Email)
+	})
+}
+
+// authenticate returns the user of the request's token. When it fails, it
+// writes the error response and returns false.
+func authenticate(w http.ResponseWriter, r *http.Request, store types.UserStore) (*types.User, bool) {
+	// Get the token from the user request.
+	tokenString := getTokenFromRequest(r)
+	if tokenString == "" {
+		netjson.WriteError(w, r, types.Errorf(types.ErrUnauthorized, "no token provided"))
+		return nil, false
+	}
+
+	// Validate the JWT.
+	token, err := validateToken(tokenString)
+	if err != nil {
+		log.Printf("failed to validate token: %v", err)
+		permissionDenied(w, r)
+		return nil, false
+	}
+
+	if !token.Valid {
+		log.Println("invalid token")
+		permissionDenied(w, r)
+		return nil, false
+	}
+
+	// Fetch the userID from the db using the ID from the token.
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		permissionDenied(w, r)
+		return nil, false
+	}
+
+	userID, err := strconv.Atoi(claims["userID"].(string))
+	if err != nil {
+		permissionDenied(w, r)
+		return nil, false
+	}
+
+	u, err := store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("failed to get user by ID: %v", err)
+		permissionDenied(w, r)
+		return nil, false
+	}
+
+	return u, true
+}
+
 func getTokenFromRequest(r *http.Request) string {
 	token := r.Header.Get("Authorization")
 	if token == "" {
@@ -107,8 +148,8 @@ func validateToken(tokenString string) (*jwt.Token, error) {
 	})
 }
 
-func permissionDenied(w http.ResponseWriter) {
-	netjson.WriteError(w, http.StatusUnauthorized, fmt.Errorf("permission denied"))
+func permissionDenied(w http.ResponseWriter, r *http.Request) {
+	netjson.WriteError(w, r, types.Errorf(types.ErrUnauthorized, "permission denied"))
 }
 
 // GetUserIDFromContext gets the user ID from the context
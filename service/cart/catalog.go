@@ -0,0 +1,100 @@
+package cart
+
+import (
+	"context"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// catalog : The products of a cart's items and the variants they choose
+type catalog struct {
+	products map[int]types.Product
+	// missing tells the products the store doesn't have.
+	missing  map[int]bool
+	variants map[int]types.Variant
+	// inVariants tells the products that are only sold in variants.
+	inVariants map[int]bool
+}
+
+// newCatalog indexes the products of a lookup and their variants.
+func newCatalog(lookup types.ProductLookup, variants []types.Variant) catalog {
+	c := catalog{
+		products:   make(map[int]types.Product, len(lookup.Products)),
+		missing:    make(map[int]bool, len(lookup.Missing)),
+		variants:   make(map[int]types.Variant, len(variants)),
+		inVariants: map[int]bool{},
+	}
+	for _, p := range lookup.Products {
+		c.products[p.ID] = p
+	}
+	for _, id := range lookup.Missing {
+		c.missing[id] = true
+	}
+	for _, v := range variants {
+		c.variants[v.ID] = v
+		c.inVariants[v.ProductID] = true
+	}
+	return c
+}
+
+// getCatalog gets the products of items and their variants.
+func (h *Handler) getCatalog(ctx context.Context, items []types.CartItem) (catalog, error) {
+	productIDs, err := getCartItemsIDs(items)
+	if err != nil {
+		return catalog{}, err
+	}
+
+	lookup, err := h.productStore.LookupProducts(ctx, productIDs)
+	if err != nil {
+		return catalog{}, err
+	}
+	variants, err := h.variantStore.GetVariantsByProduct(ctx, productIDs)
+	if err != nil {
+		return catalog{}, err
+	}
+	return newCatalog(lookup, variants), nil
+}
+
+// lookup returns the product an item buys as its variant sells it: with the
+// variant's price, stock and image. It fails with ErrNotFound for products
+// the store reported missing or didn't return, and for unknown variants,
+// and with ErrValidation when a product sold in variants is bought without
+// one.
+func (c catalog) lookup(item types.CartItem) (types.Product, error) {
+	p, ok := c.products[item.ProductID]
+	if !ok || c.missing[item.ProductID] {
+		return types.Product{}, errProductNotFound(item.ProductID)
+	}
+	if item.VariantID == 0 {
+		if c.inVariants[p.ID] {
+			return types.Product{}, types.Errorf(types.ErrValidation, "product %d comes in variants, please choose one", p.ID)
+		}
+		return p, nil
+	}
+
+	v, ok := c.variants[item.VariantID]
+	if !ok || v.ProductID != p.ID {
+		return types.Product{}, types.Errorf(types.ErrNotFound, "variant %d of product %d is not available in the store, please refresh your cart", item.VariantID, p.ID)
+	}
+	p.Price = v.PriceOf(p)
+	p.Quantity = v.Quantity
+	p.Available = v.Available
+	if v.Image != "" {
+		p.Image = v.Image
+	}
+	return p, nil
+}
+
+// price returns the unit price of an item, or 0 if it can't be bought.
+func (c catalog) price(item types.CartItem) float64 {
+	p, err := c.lookup(item)
+	if err != nil {
+		return 0
+	}
+	return p.Price
+}
+
+// sku returns the SKU of an item's variant, if it has one.
+func (c catalog) sku(item types.CartItem) string {
+	return c.variants[item.VariantID].SKU
+}
@@ -0,0 +1,33 @@
+package cart
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+func TestCatalogLookup(t *testing.T) {
+	mug := types.Product{ID: 1, Name: "mug", Price: 10}
+
+	tests := []struct {
+		name   string
+		lookup types.ProductLookup
+	}{
+		{"reported missing", types.NewProductLookup([]int{mug.ID, 2}, []types.Product{mug})},
+		{"neither found nor reported missing", types.ProductLookup{Products: []types.Product{mug}, Missing: []int{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCatalog(tt.lookup, nil)
+
+			if p, err := c.lookup(types.CartItem{ProductID: mug.ID, Quantity: 1}); err != nil || p.ID != mug.ID {
+				t.Errorf("expected the mug, got %+v, %v", p, err)
+			}
+			if p, err := c.lookup(types.CartItem{ProductID: 2, Quantity: 1}); !errors.Is(err, types.ErrNotFound) {
+				t.Errorf("expected ErrNotFound, got %+v, %v", p, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/davidado/go-api-reference/service/category"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// cents rounds an amount of money to cents.
+func cents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// getDiscounts works out the discounts of a cart from the coupon the
+// customer entered, if any, and the automatic promotions it qualifies for.
+//
+// Stackable promotions add up; any other promotion applies alone. The cart
+// gets whichever of the stack and the single promotions saves the most,
+// preferring the coupon on a tie. A coupon that doesn't apply to the cart
+// fails with ErrCoupon; automatic promotions that don't are left out.
+func (h *Handler) getDiscounts(ctx context.Context, userID int, items []types.CartItem, c catalog, code string, now time.Time) ([]types.OrderDiscount, error) {
+	subtotal := calculateTotalPrice(items, c)
+
+	var candidates []types.OrderDiscount
+	stackable := map[int]bool{}
+
+	promotions, err := h.promotionStore.GetAutomaticPromotions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if code != "" {
+		coupon, err := h.promotionStore.GetPromotionByCode(ctx, code)
+		if errors.Is(err, types.ErrNotFound) {
+			return nil, types.Errorf(types.ErrCoupon, "coupon %s doesn't exist", code)
+		}
+		if err != nil {
+			return nil, err
+		}
+		promotions = append([]types.Promotion{*coupon}, promotions...)
+	}
+
+	sc, err := h.getScope(ctx, promotions, items)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range promotions {
+		d, err := h.discount(ctx, p, userID, items, c, sc, subtotal, now)
+		if err != nil {
+			if p.Code != "" {
+				return nil, err
+			}
+			continue
+		}
+		candidates = append(candidates, d)
+		stackable[p.ID] = p.Stackable
+	}
+
+	var stack []types.OrderDiscount
+	for _, d := range candidates {
+		if stackable[d.PromotionID] {
+			stack = append(stack, d)
+		}
+	}
+	best := stack
+	for _, d := range candidates {
+		if stackable[d.PromotionID] {
+			continue
+		}
+		alone := []types.OrderDiscount{d}
+		if saved, bestSaved := totalDiscount(alone), totalDiscount(best); saved > bestSaved || (saved == bestSaved && holds(alone, code)) {
+			best = alone
+		}
+	}
+
+	return capDiscounts(best, subtotal), nil
+}
+
+// scope holds what promotions limited to categories need to know about a
+// cart: the category tree and the categories of the cart's products.
+type scope struct {
+	categories []types.Category
+	byProduct  map[int][]int
+}
+
+// getScope loads the scope of the cart's promotions. It only hits the
+// category store when one of them is limited to categories.
+func (h *Handler) getScope(ctx context.Context, promotions []types.Promotion, items []types.CartItem) (scope, error) {
+	if !slices.ContainsFunc(promotions, func(p types.Promotion) bool { return len(p.CategoryIDs) > 0 }) {
+		return scope{}, nil
+	}
+
+	categories, err := h.categoryStore.ListCategories(ctx)
+	if err != nil {
+		return scope{}, err
+	}
+	productIDs := make([]int, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	byProduct, err := h.categoryStore.GetCategoryIDsByProduct(ctx, productIDs)
+	if err != nil {
+		return scope{}, err
+	}
+	return scope{categories: categories, byProduct: byProduct}, nil
+}
+
+// covers reports whether a promotion applies to an item: promotions limited
+// to neither products nor categories apply to every item, the others to
+// their products and to the products in their categories or any of their
+// subcategories.
+func (sc scope) covers(p types.Promotion, item types.CartItem) bool {
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	if slices.Contains(p.ProductIDs, item.ProductID) {
+		return true
+	}
+	for _, id := range p.CategoryIDs {
+		for _, sub := range category.Descendants(sc.categories, id) {
+			if slices.Contains(sc.byProduct[item.ProductID], sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// discount works out what a promotion takes off a cart, or why it doesn't
+// apply.
+func (h *Handler) discount(ctx context.Context, p types.Promotion, userID int, items []types.CartItem, c catalog, sc scope, subtotal float64, now time.Time) (types.OrderDiscount, error) {
+	label := p.Name
+	if p.Code != "" {
+		label = "coupon " + p.Code
+	}
+
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return types.OrderDiscount{}, types.Errorf(types.ErrCoupon, "%s isn't valid yet", label)
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return types.OrderDiscount{}, types.Errorf(types.ErrCoupon, "%s has expired", label)
+	}
+	if subtotal < p.MinSpend {
+		return types.OrderDiscount{}, types.Errorf(types.ErrCoupon, "%s needs a minimum spend of %.2f", label, p.MinSpend)
+	}
+	if p.MaxUses > 0 && p.Uses >= p.MaxUses {
+		return types.OrderDiscount{}, types.Errorf(types.ErrCoupon, "%s has been used up", label)
+	}
+	if p.MaxUsesPerUser > 0 {
+		used, err := h.promotionStore.CountUserRedemptions(ctx, p.ID, userID)
+		if err != nil {
+			return types.OrderDiscount{}, err
+		}
+		if used >= p.MaxUsesPerUser {
+			return types.OrderDiscount{}, types.Errorf(types.ErrCoupon, "%s can only be used %d times", label, p.MaxUsesPerUser)
+		}
+	}
+
+	eligible := 0.0
+	for _, item := range items {
+		if sc.covers(p, item) {
+			eligible += c.price(item) * float64(item.Quantity)
+		}
+	}
+	if eligible == 0 {
+		return types.OrderDiscount{}, types.Errorf(types.ErrCoupon, "%s doesn't apply to any item in the cart", label)
+	}
+
+	amount := 0.0
+	switch p.Kind {
+	case types.PromotionPercent:
+		amount = eligible * p.Value / 100
+	case types.PromotionFixed:
+		amount = min(p.Value, eligible)
+	}
+
+	return types.OrderDiscount{
+		PromotionID: p.ID,
+		Name:        p.Name,
+		Code:        p.Code,
+		Amount:      cents(amount),
+	}, nil
+}
+
+// capDiscounts trims the last discounts so they don't take off more than
+// the subtotal.
+func capDiscounts(discounts []types.OrderDiscount, subtotal float64) []types.OrderDiscount {
+	var capped []types.OrderDiscount
+	left := cents(subtotal)
+	for _, d := range discounts {
+		d.Amount = min(d.Amount, left)
+		if d.Amount <= 0 {
+			break
+		}
+		left = cents(left - d.Amount)
+		capped = append(capped, d)
+	}
+	return capped
+}
+
+func totalDiscount(discounts []types.OrderDiscount) float64 {
+	total := 0.0
+	for _, d := range discounts {
+		total += d.Amount
+	}
+	return cents(total)
+}
+
+// holds reports whether discounts use the coupon code.
+func holds(discounts []types.OrderDiscount, code string) bool {
+	return code != "" && slices.ContainsFunc(discounts, func(d types.OrderDiscount) bool { return d.Code == code })
+}
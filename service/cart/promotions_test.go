@@ -0,0 +1,209 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/davidado/go-api-reference/memstore"
+	"github.com/davidado/go-api-reference/types"
+)
+
+func TestGetDiscounts(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// A cart of two mugs at 10 and a plate at 30; the store also sells
+	// bowls. Mugs are cups, which are tableware, and bowls are kitchenware.
+	mug := types.Product{ID: 1, Name: "mug", Price: 10}
+	plate := types.Product{ID: 2, Name: "plate", Price: 30}
+	bowl := types.Product{ID: 3, Name: "bowl", Price: 15}
+	tableware, cups, kitchenware := 1, 2, 3
+	ids := []int{mug.ID, plate.ID}
+	products := newCatalog(types.NewProductLookup(ids, []types.Product{mug, plate}), nil)
+	items := []types.CartItem{{ProductID: mug.ID, Quantity: 2}, {ProductID: plate.ID, Quantity: 1}}
+
+	// newHandler creates a handler with the given promotions.
+	newHandler := func(t *testing.T, promotions ...types.Promotion) *Handler {
+		t.Helper()
+
+		s := memstore.New()
+		for _, p := range []types.Product{mug, plate, bowl} {
+			if _, err := s.CreateProduct(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, c := range []types.Category{
+			{Name: "Tableware", Slug: "tableware"},
+			{ParentID: &tableware, Name: "Cups", Slug: "cups"},
+			{Name: "Kitchenware", Slug: "kitchenware"},
+		} {
+			if _, err := s.CreateCategory(ctx, c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.SetProductCategories(ctx, mug.ID, []int{cups}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetProductCategories(ctx, bowl.ID, []int{kitchenware}); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range promotions {
+			if _, err := s.CreatePromotion(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return NewHandler(Deps{
+			OrderStore:     s,
+			ProductStore:   s,
+			VariantStore:   s,
+			UserStore:      s,
+			PaymentStore:   s,
+			PromotionStore: s,
+			CategoryStore:  s,
+			ShippingStore:  s,
+			InventoryStore: s,
+		})
+	}
+
+	amounts := func(discounts []types.OrderDiscount) []float64 {
+		a := []float64{}
+		for _, d := range discounts {
+			a = append(a, d.Amount)
+		}
+		return a
+	}
+
+	tests := []struct {
+		name       string
+		promotions []types.Promotion
+		code       string
+		want       []float64
+		wantErr    bool
+	}{
+		{
+			name:       "percent off the cart",
+			promotions: []types.Promotion{{Name: "10%", Code: "TEN", Kind: types.PromotionPercent, Value: 10}},
+			code:       "TEN",
+			want:       []float64{5},
+		},
+		{
+			name:       "fixed amount limited to a product",
+			promotions: []types.Promotion{{Name: "Mugs", Code: "MUGS", Kind: types.PromotionFixed, Value: 50, ProductIDs: []int{mug.ID}}},
+			code:       "MUGS",
+			want:       []float64{20},
+		},
+		{
+			name:       "percent off a category and its subcategories",
+			promotions: []types.Promotion{{Name: "Tableware", Code: "TABLE", Kind: types.PromotionPercent, Value: 10, CategoryIDs: []int{tableware}}},
+			code:       "TABLE",
+			want:       []float64{2},
+		},
+		{
+			name:       "products and categories add up",
+			promotions: []types.Promotion{{Name: "Mugs and plates", Code: "BOTH", Kind: types.PromotionPercent, Value: 10, ProductIDs: []int{plate.ID}, CategoryIDs: []int{cups}}},
+			code:       "BOTH",
+			want:       []float64{5},
+		},
+		{
+			name: "stackable promotions add up",
+			promotions: []types.Promotion{
+				{Name: "10%", Code: "TEN", Kind: types.PromotionPercent, Value: 10, Stackable: true},
+				{Name: "Welcome", Kind: types.PromotionFixed, Value: 3, Stackable: true},
+			},
+			code: "TEN",
+			want: []float64{5, 3},
+		},
+		{
+			name: "an exclusive promotion beats a smaller stack",
+			promotions: []types.Promotion{
+				{Name: "Half off", Code: "HALF", Kind: types.PromotionPercent, Value: 50},
+				{Name: "Welcome", Kind: types.PromotionFixed, Value: 3, Stackable: true},
+			},
+			code: "HALF",
+			want: []float64{25},
+		},
+		{
+			name: "a bigger stack beats an exclusive coupon",
+			promotions: []types.Promotion{
+				{Name: "1 off", Code: "ONE", Kind: types.PromotionFixed, Value: 1},
+				{Name: "Welcome", Kind: types.PromotionFixed, Value: 3, Stackable: true},
+			},
+			code: "ONE",
+			want: []float64{3},
+		},
+		{
+			name: "the coupon wins ties",
+			promotions: []types.Promotion{
+				{Name: "3 off", Code: "THREE", Kind: types.PromotionFixed, Value: 3},
+				{Name: "Welcome", Kind: types.PromotionFixed, Value: 3},
+			},
+			code: "THREE",
+			want: []float64{3},
+		},
+		{
+			name: "discounts don't go over the subtotal",
+			promotions: []types.Promotion{
+				{Name: "40 off", Kind: types.PromotionFixed, Value: 40, Stackable: true},
+				{Name: "20 off", Kind: types.PromotionFixed, Value: 20, Stackable: true},
+			},
+			want: []float64{40, 10},
+		},
+		{
+			name:       "automatic promotions that don't apply are left out",
+			promotions: []types.Promotion{{Name: "Big spender", Kind: types.PromotionPercent, Value: 10, MinSpend: 100}},
+			want:       []float64{},
+		},
+		{
+			name:    "unknown coupon",
+			code:    "NOPE",
+			wantErr: true,
+		},
+		{
+			name:       "coupon under the minimum spend",
+			promotions: []types.Promotion{{Name: "Big spender", Code: "BIG", Kind: types.PromotionPercent, Value: 10, MinSpend: 100}},
+			code:       "BIG",
+			wantErr:    true,
+		},
+		{
+			name:       "expired coupon",
+			promotions: []types.Promotion{{Name: "May", Code: "MAY", Kind: types.PromotionPercent, Value: 10, EndsAt: &now}},
+			code:       "MAY",
+			wantErr:    true,
+		},
+		{
+			name:       "coupon for products not in the cart",
+			promotions: []types.Promotion{{Name: "Bowls", Code: "BOWLS", Kind: types.PromotionPercent, Value: 10, ProductIDs: []int{bowl.ID}}},
+			code:       "BOWLS",
+			wantErr:    true,
+		},
+		{
+			name:       "coupon for categories not in the cart",
+			promotions: []types.Promotion{{Name: "Kitchen", Code: "KITCHEN", Kind: types.PromotionPercent, Value: 10, CategoryIDs: []int{kitchenware}}},
+			code:       "KITCHEN",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHandler(t, tt.promotions...)
+
+			discounts, err := h.getDiscounts(ctx, 1, items, products, tt.code, now)
+			if tt.wantErr {
+				if !errors.Is(err, types.ErrCoupon) {
+					t.Fatalf("expected ErrCoupon, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := amounts(discounts); !slices.Equal(got, tt.want) {
+				t.Errorf("expected discounts %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
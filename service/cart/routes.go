@@ -2,75 +2,214 @@
 package cart
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 
+	"github.com/davidado/go-api-reference/db"
 	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
 	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/service/shipping"
 	"github.com/davidado/go-api-reference/types"
 	vd "github.com/davidado/go-api-reference/validator"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
 // Handler : Cart handler
 type Handler struct {
-	store        types.OrderStore
-	productStore types.ProductStore
-	userStore    types.UserStore
+	store          types.OrderStore
+	productStore   types.ProductStore
+	variantStore   types.VariantStore
+	userStore      types.UserStore
+	paymentStore   types.PaymentStore
+	promotionStore types.PromotionStore
+	categoryStore  types.CategoryStore
+	shippingStore  types.ShippingStore
+	inventoryStore types.InventoryStore
+	payments       types.PaymentProvider
+	taxes          types.TaxCalculator
+}
+
+// Deps : What the cart handler needs, named so call sites can't mix up
+// stores of the same shape
+type Deps struct {
+	OrderStore     types.OrderStore
+	ProductStore   types.ProductStore
+	VariantStore   types.VariantStore
+	UserStore      types.UserStore
+	PaymentStore   types.PaymentStore
+	PromotionStore types.PromotionStore
+	CategoryStore  types.CategoryStore
+	ShippingStore  types.ShippingStore
+	InventoryStore types.InventoryStore
+	// Payments charges the cart at checkout.
+	Payments types.PaymentProvider
+	// Taxes works out the cart's tax.
+	Taxes types.TaxCalculator
 }
 
 // NewHandler creates a new cart handler
-func NewHandler(store types.OrderStore, productStore types.ProductStore, userStore types.UserStore) *Handler {
+func NewHandler(d Deps) *Handler {
 	return &Handler{
-		store:        store,
-		productStore: productStore,
-		userStore:    userStore,
+		store:          d.OrderStore,
+		productStore:   d.ProductStore,
+		variantStore:   d.VariantStore,
+		userStore:      d.UserStore,
+		paymentStore:   d.PaymentStore,
+		promotionStore: d.PromotionStore,
+		categoryStore:  d.CategoryStore,
+		shippingStore:  d.ShippingStore,
+		inventoryStore: d.InventoryStore,
+		payments:       d.Payments,
+		taxes:          d.Taxes,
 	}
 }
 
 // RegisterRoutes registers cart routes
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/cart/checkout", auth.WithJWTAuth(h.handleCheckout, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/cart/quote", auth.WithJWTAuth(h.handleQuote, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/cart/shipping-options", h.handleGetShippingOptions).Methods(http.MethodGet)
+}
+
+// Operations describes the cart routes for the OpenAPI document
+func (h *Handler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{
+			Method:   http.MethodPost,
+			Path:     "/cart/checkout",
+			Summary:  "Check out the cart and create an order",
+			Tags:     []string{"cart"},
+			Auth:     true,
+			Request:  types.CartCheckoutPayload{},
+			Response: types.CheckoutResponse{},
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/cart/quote",
+			Summary:  "Price a checkout without placing the order",
+			Tags:     []string{"cart"},
+			Auth:     true,
+			Request:  types.CartCheckoutPayload{},
+			Response: types.CartQuote{},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/cart/shipping-options",
+			Summary: "Quote the shipping methods that can deliver a cart to an address",
+			Tags:    []string{"cart"},
+			Params: []openapi.Param{
+				{Name: "items", Query: true, Required: true, Description: "Comma-separated PRODUCT_ID:QUANTITY pairs, e.g. 1:2,7:1, with PRODUCT_ID/VARIANT_ID for variants, e.g. 3/12:1"},
+				{Name: "country", Query: true, Required: true, Description: "ISO 3166-1 alpha-2 country code"},
+				{Name: "region", Query: true, Description: "State or province"},
+				{Name: "postalCode", Query: true},
+			},
+			Response: []types.ShippingOption{},
+		},
+	}
 }
 
 // handleCheckout handles the checkout of a cart
 func (h *Handler) handleCheckout(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetUserIDFromContext(r.Context())
 
-	var cart types.CartCheckoutPayload
-	if err := netjson.Parse(r, &cart); err != nil {
-		netjson.WriteError(w, http.StatusBadRequest, err)
+	// Stock is checked and updated from the same reads, so they can't
+	// come from a lagging replica.
+	ctx := db.WithPrimary(r.Context())
+
+	cart, c, err := h.parseCart(ctx, r)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	res, err := h.createOrder(ctx, c, cart, userID)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.Write(w, http.StatusOK, res)
+}
+
+// handleQuote prices a cart like checkout without placing an order
+func (h *Handler) handleQuote(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	cart, c, err := h.parseCart(r.Context(), r)
+	if err != nil {
+		netjson.WriteError(w, r, err)
 		return
 	}
 
-	if err := vd.Validate.Struct(cart); err != nil {
-		errors := err.(validator.ValidationErrors)
-		netjson.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+	q, err := h.quote(r.Context(), c, cart, userID)
+	if err != nil {
+		netjson.WriteError(w, r, err)
 		return
 	}
 
-	// get products
-	productIDs, err := getCartItemsIDs(cart.Items)
+	netjson.Write(w, http.StatusOK, q)
+}
+
+// parseCart reads and validates the checkout payload of r and gets the
+// products and variants of its items.
+func (h *Handler) parseCart(ctx context.Context, r *http.Request) (types.CartCheckoutPayload, catalog, error) {
+	var cart types.CartCheckoutPayload
+	if err := netjson.Parse(r, &cart); err != nil {
+		return cart, catalog{}, err
+	}
+
+	if err := vd.Struct(cart, r.Header.Get("Accept-Language")); err != nil {
+		return cart, catalog{}, err
+	}
+
+	// A product listed twice is checked for stock once, for both lines.
+	cart.Items = mergeCartItems(cart.Items)
+
+	c, err := h.getCatalog(ctx, cart.Items)
 	if err != nil {
-		netjson.WriteError(w, http.StatusBadRequest, err)
+		return cart, catalog{}, err
+	}
+	return cart, c, nil
+}
+
+// handleGetShippingOptions quotes every shipping method that can deliver the
+// items of the query to its destination.
+func (h *Handler) handleGetShippingOptions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	dest := types.ShippingOptionsQuery{Country: q.Get("country"), Region: q.Get("region"), PostalCode: q.Get("postalCode")}
+	if err := vd.Struct(dest, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
 		return
 	}
 
-	ps, err := h.productStore.GetProductsByID(productIDs)
+	items, err := parseCartItems(q.Get("items"))
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	c, err := h.getCatalog(r.Context(), items)
 	if err != nil {
-		netjson.WriteError(w, http.StatusInternalServerError, err)
+		netjson.WriteError(w, r, err)
+		return
+	}
+	var cerr types.CartError
+	for _, item := range items {
+		if _, err := c.lookup(item); err != nil {
+			cerr.Items = append(cerr.Items, types.ItemError{ProductID: item.ProductID, VariantID: item.VariantID, Err: err})
+		}
+	}
+	if len(cerr.Items) > 0 {
+		netjson.WriteError(w, r, &cerr)
 		return
 	}
 
-	orderID, totalPrice, err := h.createOrder(ps, cart.Items, userID)
+	methods, err := h.shippingStore.ListShippingMethods(r.Context())
 	if err != nil {
-		netjson.WriteError(w, http.StatusBadRequest, err)
+		netjson.WriteError(w, r, err)
 		return
 	}
 
-	netjson.Write(w, http.StatusOK, map[string]any{
-		"total_price": totalPrice,
-		"order_id":    orderID,
-	})
+	address := types.Address{Country: dest.Country, Region: dest.Region, PostalCode: dest.PostalCode}
+	netjson.Write(w, http.StatusOK, shipping.Options(methods, items, c.products, calculateTotalPrice(items, c), address))
 }
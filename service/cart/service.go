@@ -1,8 +1,13 @@
 package cart
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/davidado/go-api-reference/config"
+	"github.com/davidado/go-api-reference/netjson"
 	"github.com/davidado/go-api-reference/types"
 )
 
@@ -10,7 +15,7 @@ func getCartItemsIDs(items []types.CartItem) ([]int, error) {
 	productIDs := make([]int, len(items))
 	for i, item := range items {
 		if item.Quantity <= 0 {
-			return nil, fmt.Errorf("invalid quantity for the product %d", item.ProductID)
+			return nil, types.Errorf(types.ErrValidation, "invalid quantity for the product %d", item.ProductID)
 		}
 		productIDs[i] = item.ProductID
 	}
@@ -18,81 +23,361 @@ func getCartItemsIDs(items []types.CartItem) ([]int, error) {
 	return productIDs, nil
 }
 
-func (h *Handler) createOrder(ps []types.Product, items []types.CartItem, userID int) (int, float64, error) {
-	productMap := make(map[int]types.Product)
-	for _, product := range ps {
-		productMap[product.ID] = product
+// mergeCartItems adds up the quantities of the lines of the same product
+// and variant, so the stock check sees everything the cart takes of each.
+// Lines keep the place of their first one.
+func mergeCartItems(items []types.CartItem) []types.CartItem {
+	type line struct{ productID, variantID int }
+
+	merged := make([]types.CartItem, 0, len(items))
+	lines := make(map[line]int, len(items))
+	for _, item := range items {
+		key := line{item.ProductID, item.VariantID}
+		if i, ok := lines[key]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		lines[key] = len(merged)
+		merged = append(merged, item)
 	}
+	return merged
+}
+
+// createOrder creates the order of a cart, reserves its stock and charges
+// it. An order whose payment requires action stays pending, holding the
+// stock, until the payment webhook settles it or the reservation expires.
+func (h *Handler) createOrder(ctx context.Context, c catalog, cart types.CartCheckoutPayload, userID int) (types.CheckoutResponse, error) {
+	items := cart.Items
 
 	// Check if all products are actually in stock.
-	if err := checkIfCartIsInStock(items, productMap); err != nil {
-		return 0, 0, err
+	if err := checkIfCartIsInStock(items, c); err != nil {
+		return types.CheckoutResponse{}, err
 	}
 
 	// Calculate the total price.
-	totalPrice := calculateTotalPrice(items, productMap)
+	price, err := h.priceCart(ctx, items, c, cart, userID)
+	if err != nil {
+		return types.CheckoutResponse{}, err
+	}
 
-	// Reduce the quantity of the products in the db.
-	// Warning: This is a naive implementation if there are multiple requests.
-	// Use a separate join table like Orders_Items to store order quantities.
-	for _, item := range items {
-		product := productMap[item.ProductID]
-		product.Quantity -= item.Quantity
+	// Authorize the payment before anything is written, so a declined
+	// card leaves no trace.
+	auth, err := h.payments.Authorize(ctx, types.PaymentRequest{Amount: price.total, Method: cart.PaymentMethod})
+	if err != nil {
+		return types.CheckoutResponse{}, err
+	}
+
+	order := types.Order{
+		UserID:       userID,
+		Total:        price.total,
+		Status:       types.OrderPending,
+		Address:      cart.ShippingAddress.String(),
+		TaxInclusive: price.tax.Inclusive,
+		Discounts:    price.discounts,
 
-		h.productStore.UpdateProduct(product)
+		ShippingMethodID: price.shipping.MethodID,
+		ShippingMethod:   price.shipping.Name,
+		ShippingCost:     price.shipping.Cost,
+	}
+	orderItems := make([]types.OrderItem, len(items))
+	for i, item := range items {
+		orderItems[i] = types.OrderItem{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+			Price:     c.price(item),
+			TaxRate:   price.tax.Lines[i].Rate,
+			Tax:       price.tax.Lines[i].Tax,
+		}
 	}
 
-	// Create the order.
-	orderID, err := h.store.CreateOrder(types.Order{
-		UserID:  userID,
-		Total:   totalPrice,
-		Status:  "pending",
-		Address: "123 Main St", // Create an address table
-	})
+	orderID, paymentID, err := h.saveOrder(ctx, order, orderItems, auth)
+	if err != nil {
+		h.voidPayment(ctx, auth.Reference)
+		return types.CheckoutResponse{}, err
+	}
+
+	res := types.CheckoutResponse{
+		TotalPrice: price.total,
+		OrderID:    orderID,
+		Status:     types.OrderPending,
+		Discounts:  price.discounts,
+		Tax:        price.tax,
+		Shipping:   price.shipping,
+	}
+	if auth.Status == types.PaymentRequiresAction {
+		res.NextActionURL = auth.NextActionURL
+		return res, nil
+	}
+
+	paid, err := h.capture(ctx, orderID, paymentID, auth.Reference)
+	if err != nil {
+		return types.CheckoutResponse{}, err
+	}
+	if paid {
+		res.Status = types.OrderPaid
+	}
+	return res, nil
+}
+
+// cartPrice : What checkout charges for a cart
+type cartPrice struct {
+	subtotal  float64
+	discounts []types.OrderDiscount
+	discount  float64
+	shipping  types.ShippingOption
+	tax       types.Tax
+	total     float64
+}
+
+// priceCart works out the discounts, shipping and tax of the items of a cart
+// and what they come to. Both checkout and quotes price carts with it, so
+// they always agree.
+func (h *Handler) priceCart(ctx context.Context, items []types.CartItem, c catalog, cart types.CartCheckoutPayload, userID int) (cartPrice, error) {
+	subtotal := calculateTotalPrice(items, c)
+	discounts, err := h.getDiscounts(ctx, userID, items, c, cart.CouponCode, time.Now())
+	if err != nil {
+		return cartPrice{}, err
+	}
+	discount := totalDiscount(discounts)
+
+	delivery, err := h.getShipping(ctx, cart.ShippingMethodID, items, c, subtotal, cart.ShippingAddress)
+	if err != nil {
+		return cartPrice{}, err
+	}
+
+	tax, err := h.taxes.Calculate(ctx, taxLines(items, c, subtotal, discount), cart.ShippingAddress)
+	if err != nil {
+		return cartPrice{}, fmt.Errorf("calculate tax: %w", err)
+	}
+	total := cents(subtotal - discount + delivery.Cost)
+	if !tax.Inclusive {
+		total = cents(total + tax.Total)
+	}
+
+	return cartPrice{subtotal: subtotal, discounts: discounts, discount: discount, shipping: delivery, tax: tax, total: total}, nil
+}
+
+// quote prices a cart like checkout without placing an order. Instead of
+// failing on the first item checkout would refuse, it reports the problem
+// of every such item and prices the items of known products and variants.
+func (h *Handler) quote(ctx context.Context, c catalog, cart types.CartCheckoutPayload, userID int) (types.CartQuote, error) {
+	q := types.CartQuote{CanCheckout: true, Lines: make([]types.QuoteLine, len(cart.Items))}
+	var priced []types.CartItem
+	var pricedLines []int
+	for i, item := range cart.Items {
+		line := types.QuoteLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+		if err := checkIfItemIsInStock(item, c); err != nil {
+			line.Problem = &types.QuoteProblem{Code: netjson.Code(err), Detail: err.Error()}
+			q.CanCheckout = false
+		}
+		if product, err := c.lookup(item); err == nil {
+			line.Name = product.Name
+			line.SKU = c.sku(item)
+			line.Available = product.Available
+			line.Price = product.Price
+			line.Total = cents(product.Price * float64(item.Quantity))
+			priced = append(priced, item)
+			pricedLines = append(pricedLines, i)
+		}
+		q.Lines[i] = line
+	}
+
+	// With no item to price, the quote fails like checkout would.
+	if len(priced) == 0 {
+		return types.CartQuote{}, checkIfCartIsInStock(cart.Items, c)
+	}
+
+	price, err := h.priceCart(ctx, priced, c, cart, userID)
 	if err != nil {
+		return types.CartQuote{}, err
+	}
+	for i, line := range price.tax.Lines {
+		q.Lines[pricedLines[i]].TaxRate = line.Rate
+		q.Lines[pricedLines[i]].Tax = line.Tax
+	}
+
+	q.Subtotal = cents(price.subtotal)
+	q.Discounts = price.discounts
+	if q.Discounts == nil {
+		q.Discounts = []types.OrderDiscount{}
+	}
+	q.Discount = price.discount
+	q.Shipping = price.shipping
+	q.Tax = price.tax
+	q.Total = price.total
+	return q, nil
+}
+
+// saveOrder records the order with its discounts, reserves its items and
+// records the order's items and its authorized payment. It returns the IDs
+// of the order and the payment. An order that can't be saved whole is
+// marked failed.
+func (h *Handler) saveOrder(ctx context.Context, order types.Order, orderItems []types.OrderItem, auth types.PaymentResult) (int, int, error) {
+	// Create the order first: it fails when a promotion has just been
+	// used up, before any stock is reserved.
+	orderID, err := h.store.CreateOrder(ctx, order)
+	if err != nil {
+		return 0, 0, fmt.Errorf("create order: %w", err)
+	}
+
+	paymentID, err := h.saveOrderLines(ctx, orderID, order.Total, orderItems, auth)
+	if err != nil {
+		h.abandonOrder(ctx, orderID)
 		return 0, 0, err
 	}
+	return orderID, paymentID, nil
+}
+
+// saveOrderLines holds the stock of an order until it's paid for and
+// records its items and payment.
+func (h *Handler) saveOrderLines(ctx context.Context, orderID int, total float64, orderItems []types.OrderItem, auth types.PaymentResult) (int, error) {
+	// Hold the stock while the customer pays; another checkout reading
+	// the same products only sees what's left.
+	items := make([]types.CartItem, len(orderItems))
+	for i, oi := range orderItems {
+		items[i] = types.CartItem{ProductID: oi.ProductID, VariantID: oi.VariantID, Quantity: oi.Quantity}
+	}
+	ttl := time.Second * time.Duration(config.Envs.ReservationTTLInSeconds)
+	if err := h.inventoryStore.ReserveStock(ctx, orderID, items, time.Now().Add(ttl)); err != nil {
+		return 0, fmt.Errorf("reserve stock: %w", err)
+	}
 
 	// Create the order items.
-	for _, item := range items {
-		h.store.CreateOrderItem(types.OrderItem{
-			OrderID:   orderID,
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     productMap[item.ProductID].Price,
-		})
+	for _, oi := range orderItems {
+		oi.OrderID = orderID
+		if err := h.store.CreateOrderItem(ctx, oi); err != nil {
+			return 0, fmt.Errorf("create order item: %w", err)
+		}
 	}
 
-	// TODO: Wrap all the above statements in a transaction.
+	paymentID, err := h.paymentStore.CreatePayment(ctx, types.Payment{
+		OrderID:   orderID,
+		Provider:  h.payments.Name(),
+		Reference: auth.Reference,
+		Status:    auth.Status,
+		Amount:    total,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("create payment: %w", err)
+	}
+	return paymentID, nil
+}
 
-	return orderID, totalPrice, nil
+// abandonOrder releases the stock of an order that couldn't be saved and
+// marks it failed. Failures are only logged; the sweeper releases the
+// stock once the reservations expire.
+func (h *Handler) abandonOrder(ctx context.Context, orderID int) {
+	if err := h.inventoryStore.ReleaseReservations(ctx, orderID); err != nil {
+		log.Printf("release reservations of order %d: %v", orderID, err)
+	}
+	if err := h.store.UpdateOrderStatus(ctx, orderID, types.OrderFailed); err != nil {
+		log.Printf("update order %d: %v", orderID, err)
+	}
 }
 
-func checkIfCartIsInStock(cartItems []types.CartItem, products map[int]types.Product) error {
+// capture collects an authorized payment, then records it, takes the
+// order's reserved stock and marks the order paid in one transaction. A
+// payment that can't be captured is voided and its order abandoned. It
+// reports whether the order is paid: when recording a capture fails, the
+// customer has been charged anyway, so the order is left pending for
+// review rather than failing checkout.
+func (h *Handler) capture(ctx context.Context, orderID, paymentID int, reference string) (bool, error) {
+	if _, err := h.payments.Capture(ctx, reference); err != nil {
+		h.voidPayment(ctx, reference)
+		h.abandonOrder(ctx, orderID)
+		if err := h.paymentStore.UpdatePaymentStatus(ctx, paymentID, types.PaymentVoided); err != nil {
+			log.Printf("update payment %d: %v", paymentID, err)
+		}
+		return false, fmt.Errorf("capture payment %s: %w", reference, err)
+	}
+
+	if err := h.inventoryStore.CommitPaidOrder(ctx, orderID, paymentID); err != nil {
+		log.Printf("ALERT: payment %s of order %d was captured but not recorded, review the order: %v", reference, orderID, err)
+		// Keep the sweeper from voiding a payment that took the money.
+		if err := h.paymentStore.UpdatePaymentStatus(ctx, paymentID, types.PaymentCaptured); err != nil {
+			log.Printf("ALERT: update payment %d: %v", paymentID, err)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// voidPayment releases the authorization of an order that couldn't be
+// saved. A failure is only logged; the authorization expires on its own.
+func (h *Handler) voidPayment(ctx context.Context, reference string) {
+	if _, err := h.payments.Void(ctx, reference); err != nil {
+		log.Printf("void payment %s: %v", reference, err)
+	}
+}
+
+func checkIfCartIsInStock(cartItems []types.CartItem, c catalog) error {
 	if len(cartItems) == 0 {
-		return fmt.Errorf("cart is empty")
+		return types.Errorf(types.ErrValidation, "cart is empty")
 	}
 
+	// Report every item at once, so the client can fix the whole cart.
+	var cerr types.CartError
 	for _, item := range cartItems {
-		product, ok := products[item.ProductID]
-		if !ok {
-			return fmt.Errorf("product %d is not available in the store, please refresh your cart", item.ProductID)
+		if err := checkIfItemIsInStock(item, c); err != nil {
+			cerr.Items = append(cerr.Items, types.ItemError{ProductID: item.ProductID, VariantID: item.VariantID, Err: err})
 		}
+	}
+	if len(cerr.Items) > 0 {
+		return &cerr
+	}
 
-		if product.Quantity < item.Quantity {
-			return fmt.Errorf("product %d is out of stock", item.ProductID)
-		}
+	return nil
+}
+
+// checkIfItemIsInStock checks that the product and variant of a cart item
+// exist and have enough stock available for it.
+func checkIfItemIsInStock(item types.CartItem, c catalog) error {
+	product, err := c.lookup(item)
+	if err != nil {
+		return err
 	}
 
+	if product.Available < item.Quantity {
+		if item.VariantID != 0 {
+			return types.Errorf(types.ErrOutOfStock, "variant %d of product %d is out of stock", item.VariantID, item.ProductID)
+		}
+		return types.Errorf(types.ErrOutOfStock, "product %d is out of stock", item.ProductID)
+	}
 	return nil
 }
 
-func calculateTotalPrice(cartItems []types.CartItem, products map[int]types.Product) float64 {
+// errProductNotFound reports a cart item whose product isn't in the store.
+func errProductNotFound(productID int) error {
+	return types.Errorf(types.ErrNotFound, "product %d is not available in the store, please refresh your cart", productID)
+}
+
+func calculateTotalPrice(cartItems []types.CartItem, c catalog) float64 {
 	totalPrice := 0.0
 	for _, item := range cartItems {
-		product := products[item.ProductID]
-		totalPrice += product.Price * float64(item.Quantity)
+		totalPrice += c.price(item) * float64(item.Quantity)
 	}
 
 	return totalPrice
 }
+
+// taxLines spreads the discount over the cart's items in proportion to
+// their price and returns what's left of each item to tax. The last line
+// takes the rounding, so the lines add up to the discounted subtotal.
+func taxLines(cartItems []types.CartItem, c catalog, subtotal, discount float64) []types.TaxLine {
+	lines := make([]types.TaxLine, len(cartItems))
+	left := cents(subtotal - discount)
+	for i, item := range cartItems {
+		price := c.price(item) * float64(item.Quantity)
+		taxable := left
+		if i < len(cartItems)-1 {
+			taxable = price
+			if discount > 0 {
+				taxable = cents(price - price*discount/subtotal)
+			}
+		}
+		left = cents(left - taxable)
+		lines[i] = types.TaxLine{ProductID: item.ProductID, Taxable: taxable}
+	}
+	return lines
+}
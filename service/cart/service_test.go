@@ -0,0 +1,107 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/davidado/go-api-reference/memstore"
+	"github.com/davidado/go-api-reference/service/payment"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// brokenInventory fails to record paid orders, like a database that went
+// away after the payment was captured.
+type brokenInventory struct {
+	types.InventoryStore
+}
+
+func (brokenInventory) CommitPaidOrder(context.Context, int, int) error {
+	return errors.New("connection lost")
+}
+
+func TestCapture(t *testing.T) {
+	ctx := context.Background()
+
+	// setup creates an order of two mugs holding their stock and an
+	// authorized payment, and returns their IDs and the payment's
+	// reference.
+	setup := func(t *testing.T, s *memstore.Store, payments types.PaymentProvider) (int, int, string) {
+		t.Helper()
+
+		if err := s.CreateUser(ctx, types.User{FirstName: "A", LastName: "B", Email: "a@example.com", Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.GetUserByEmail(ctx, "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mugID, err := s.CreateProduct(ctx, types.Product{Name: "mug", Price: 5, Quantity: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		orderID, err := s.CreateOrder(ctx, types.Order{UserID: u.ID, Total: 10, Status: types.OrderPending})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: mugID, Quantity: 2}}, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		auth, err := payments.Authorize(ctx, types.PaymentRequest{Amount: 10, Method: payment.CardSuccess})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paymentID, err := s.CreatePayment(ctx, types.Payment{OrderID: orderID, Provider: payments.Name(), Reference: auth.Reference, Status: auth.Status, Amount: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return orderID, paymentID, auth.Reference
+	}
+
+	// status returns the status of an order and of its payment.
+	status := func(t *testing.T, s *memstore.Store, orderID int) (string, string) {
+		t.Helper()
+
+		o, err := s.GetOrderByID(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payments, err := s.GetPaymentsByOrder(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o.Status, payments[0].Status
+	}
+
+	t.Run("should mark a captured order paid", func(t *testing.T) {
+		s := memstore.New()
+		payments := payment.NewFakeProvider()
+		h := NewHandler(Deps{OrderStore: s, PaymentStore: s, InventoryStore: s, Payments: payments})
+		orderID, paymentID, reference := setup(t, s, payments)
+
+		paid, err := h.capture(ctx, orderID, paymentID, reference)
+		if err != nil || !paid {
+			t.Fatalf("expected a paid order, got %v and %v", paid, err)
+		}
+		if o, p := status(t, s, orderID); o != types.OrderPaid || p != types.PaymentCaptured {
+			t.Errorf("expected a paid order and a captured payment, got %s and %s", o, p)
+		}
+	})
+
+	t.Run("should leave a captured order pending when it can't be recorded", func(t *testing.T) {
+		s := memstore.New()
+		payments := payment.NewFakeProvider()
+		h := NewHandler(Deps{OrderStore: s, PaymentStore: s, InventoryStore: brokenInventory{s}, Payments: payments})
+		orderID, paymentID, reference := setup(t, s, payments)
+
+		paid, err := h.capture(ctx, orderID, paymentID, reference)
+		if err != nil || paid {
+			t.Fatalf("expected a pending order and no error, got %v and %v", paid, err)
+		}
+		// The payment is recorded as captured, so the sweeper won't void it.
+		if o, p := status(t, s, orderID); o != types.OrderPending || p != types.PaymentCaptured {
+			t.Errorf("expected a pending order and a captured payment, got %s and %s", o, p)
+		}
+	})
+}
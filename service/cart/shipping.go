@@ -0,0 +1,69 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/davidado/go-api-reference/service/shipping"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// getShipping quotes the shipping method the customer chose for a cart. A
+// method that doesn't exist or can't deliver the cart to address fails
+// with ErrValidation.
+func (h *Handler) getShipping(ctx context.Context, methodID int, items []types.CartItem, c catalog, subtotal float64, address types.Address) (types.ShippingOption, error) {
+	m, err := h.shippingStore.GetShippingMethodByID(ctx, methodID)
+	if errors.Is(err, types.ErrNotFound) {
+		return types.ShippingOption{}, types.Errorf(types.ErrValidation, "shipping method %d doesn't exist", methodID)
+	}
+	if err != nil {
+		return types.ShippingOption{}, err
+	}
+
+	option, ok := shipping.Quote(*m, shipping.Weight(items, c.products), subtotal, address)
+	if !ok {
+		return types.ShippingOption{}, types.Errorf(types.ErrValidation, "%s can't deliver this cart to %s", m.Name, strings.ToUpper(address.Country))
+	}
+	return option, nil
+}
+
+// parseCartItems parses the items of a query, comma-separated
+// PRODUCT_ID:QUANTITY pairs such as "1:2,7:1". Variants are bought as
+// PRODUCT_ID/VARIANT_ID, such as "3/12:1".
+func parseCartItems(s string) ([]types.CartItem, error) {
+	var items []types.CartItem
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+
+		id, quantity, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, types.Errorf(types.ErrValidation, "invalid item %q, want PRODUCT_ID:QUANTITY", pair)
+		}
+		id, variant, hasVariant := strings.Cut(id, "/")
+		productID, err := strconv.Atoi(id)
+		if err != nil || productID <= 0 {
+			return nil, types.Errorf(types.ErrValidation, "invalid product ID in %q", pair)
+		}
+		var variantID int
+		if hasVariant {
+			variantID, err = strconv.Atoi(variant)
+			if err != nil || variantID <= 0 {
+				return nil, types.Errorf(types.ErrValidation, "invalid variant ID in %q", pair)
+			}
+		}
+		n, err := strconv.Atoi(quantity)
+		if err != nil {
+			return nil, types.Errorf(types.ErrValidation, "invalid quantity in %q", pair)
+		}
+
+		items = append(items, types.CartItem{ProductID: productID, VariantID: variantID, Quantity: n})
+	}
+	if len(items) == 0 {
+		return nil, types.Errorf(types.ErrValidation, "items are required")
+	}
+	return mergeCartItems(items), nil
+}
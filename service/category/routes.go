@@ -0,0 +1,422 @@
+package category
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/types"
+	vd "github.com/davidado/go-api-reference/validator"
+	"github.com/gorilla/mux"
+)
+
+// Handler : Category and tag handler
+type Handler struct {
+	store     types.CategoryStore
+	userStore types.UserStore
+}
+
+// NewHandler creates a new category and tag handler
+func NewHandler(store types.CategoryStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+// RegisterRoutes registers category and tag routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/categories", h.handleGetCategories).Methods(http.MethodGet)
+	router.HandleFunc("/tags", h.handleGetTags).Methods(http.MethodGet)
+
+	// admin routes
+	router.HandleFunc("/categories", auth.WithAdminAuth(h.handleCreateCategory, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/categories/{categoryID}", auth.WithAdminAuth(h.handleUpdateCategory, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/categories/{categoryID}", auth.WithAdminAuth(h.handleDeleteCategory, h.userStore)).Methods(http.MethodDelete)
+	router.HandleFunc("/tags", auth.WithAdminAuth(h.handleCreateTag, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/tags/{tagID}", auth.WithAdminAuth(h.handleRenameTag, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/tags/{tagID}", auth.WithAdminAuth(h.handleDeleteTag, h.userStore)).Methods(http.MethodDelete)
+	router.HandleFunc("/products/{productID}/categories", auth.WithAdminAuth(h.handleSetProductCategories, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/products/{productID}/tags", auth.WithAdminAuth(h.handleSetProductTags, h.userStore)).Methods(http.MethodPut)
+}
+
+// Operations describes the category and tag routes for the OpenAPI document
+func (h *Handler) Operations() []openapi.Operation {
+	categoryID := []openapi.Param{{Name: "categoryID", Type: "integer", Description: "Category ID"}}
+	tagID := []openapi.Param{{Name: "tagID", Type: "integer", Description: "Tag ID"}}
+	productID := []openapi.Param{{Name: "productID", Type: "integer", Description: "Product ID"}}
+
+	return []openapi.Operation{
+		{
+			Method:     http.MethodGet,
+			Path:       "/categories",
+			Summary:    "List the categories as a tree",
+			Tags:       []string{"categories"},
+			Response:   []types.CategoryNode{},
+			MediaTypes: netjson.MediaTypes(),
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/categories",
+			Summary:  "Create a category (admins only)",
+			Tags:     []string{"categories"},
+			Auth:     true,
+			Request:  types.CategoryPayload{},
+			Response: types.Category{},
+			Status:   http.StatusCreated,
+		},
+		{
+			Method:   http.MethodPut,
+			Path:     "/categories/{categoryID}",
+			Summary:  "Update or move a category (admins only)",
+			Tags:     []string{"categories"},
+			Auth:     true,
+			Params:   categoryID,
+			Request:  types.CategoryPayload{},
+			Response: types.Category{},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/categories/{categoryID}",
+			Summary: "Delete a category without subcategories (admins only)",
+			Tags:    []string{"categories"},
+			Auth:    true,
+			Params:  categoryID,
+			Status:  http.StatusNoContent,
+		},
+		{
+			Method:     http.MethodGet,
+			Path:       "/tags",
+			Summary:    "List tags",
+			Tags:       []string{"categories"},
+			Response:   []types.Tag{},
+			MediaTypes: netjson.MediaTypes(),
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/tags",
+			Summary:  "Create a tag (admins only)",
+			Tags:     []string{"categories"},
+			Auth:     true,
+			Request:  types.TagPayload{},
+			Response: types.Tag{},
+			Status:   http.StatusCreated,
+		},
+		{
+			Method:   http.MethodPut,
+			Path:     "/tags/{tagID}",
+			Summary:  "Rename a tag (admins only)",
+			Tags:     []string{"categories"},
+			Auth:     true,
+			Params:   tagID,
+			Request:  types.TagPayload{},
+			Response: types.Tag{},
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/tags/{tagID}",
+			Summary: "Delete a tag (admins only)",
+			Tags:    []string{"categories"},
+			Auth:    true,
+			Params:  tagID,
+			Status:  http.StatusNoContent,
+		},
+		{
+			Method:   http.MethodPut,
+			Path:     "/products/{productID}/categories",
+			Summary:  "Replace the categories of a product (admins only)",
+			Tags:     []string{"categories"},
+			Auth:     true,
+			Params:   productID,
+			Request:  types.ProductCategoriesPayload{},
+			Response: []types.Category{},
+		},
+		{
+			Method:   http.MethodPut,
+			Path:     "/products/{productID}/tags",
+			Summary:  "Replace the tags of a product, creating new ones (admins only)",
+			Tags:     []string{"categories"},
+			Auth:     true,
+			Params:   productID,
+			Request:  types.ProductTagsPayload{},
+			Response: []string{},
+		},
+	}
+}
+
+func (h *Handler) handleGetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.store.ListCategories(r.Context())
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.Respond(w, r, http.StatusOK, Tree(categories))
+}
+
+func (h *Handler) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+	payload, ok := h.parseCategory(w, r)
+	if !ok {
+		return
+	}
+
+	c := types.Category{ParentID: payload.ParentID, Name: payload.Name, Slug: payload.Slug, SortOrder: payload.SortOrder}
+	id, err := h.store.CreateCategory(r.Context(), c)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	h.writeCategory(w, r, http.StatusCreated, id)
+}
+
+func (h *Handler) handleUpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "categoryID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	payload, ok := h.parseCategory(w, r)
+	if !ok {
+		return
+	}
+
+	c := types.Category{ID: id, ParentID: payload.ParentID, Name: payload.Name, Slug: payload.Slug, SortOrder: payload.SortOrder}
+	if err := h.store.UpdateCategory(r.Context(), c); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	h.writeCategory(w, r, http.StatusOK, id)
+}
+
+func (h *Handler) handleDeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "categoryID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.store.DeleteCategory(r.Context(), id); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleGetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.store.ListTags(r.Context())
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.Respond(w, r, http.StatusOK, tags)
+}
+
+func (h *Handler) handleCreateTag(w http.ResponseWriter, r *http.Request) {
+	name, ok := h.parseTag(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := h.store.CreateTag(r.Context(), name)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	h.writeTag(w, r, http.StatusCreated, id)
+}
+
+func (h *Handler) handleRenameTag(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "tagID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	name, ok := h.parseTag(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.RenameTag(r.Context(), id, name); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	h.writeTag(w, r, http.StatusOK, id)
+}
+
+func (h *Handler) handleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "tagID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.store.DeleteTag(r.Context(), id); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleSetProductCategories(w http.ResponseWriter, r *http.Request) {
+	productID, err := pathID(r, "productID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	var payload types.ProductCategoriesPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.store.SetProductCategories(r.Context(), productID, payload.CategoryIDs); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	categories, err := h.store.GetProductCategories(r.Context(), productID)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	netjson.Write(w, http.StatusOK, categories)
+}
+
+func (h *Handler) handleSetProductTags(w http.ResponseWriter, r *http.Request) {
+	productID, err := pathID(r, "productID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	var payload types.ProductTagsPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	tags := make([]string, 0, len(payload.Tags))
+	for _, t := range payload.Tags {
+		name := NormalizeTag(t)
+		if name == "" {
+			netjson.WriteError(w, r, types.Errorf(types.ErrValidation, "tag names can't be blank"))
+			return
+		}
+		tags = append(tags, name)
+	}
+
+	if err := h.store.SetProductTags(r.Context(), productID, tags); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	tags, err = h.store.GetProductTags(r.Context(), productID)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	netjson.Write(w, http.StatusOK, tags)
+}
+
+// parseCategory reads and validates a category payload, writing the error
+// and reporting false if it's invalid.
+func (h *Handler) parseCategory(w http.ResponseWriter, r *http.Request) (types.CategoryPayload, bool) {
+	var payload types.CategoryPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
+		return payload, false
+	}
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return payload, false
+	}
+
+	if !slugPattern.MatchString(payload.Slug) {
+		netjson.WriteError(w, r, types.Errorf(types.ErrValidation, "slug %q must be lowercase letters and digits joined by hyphens", payload.Slug))
+		return payload, false
+	}
+	return payload, true
+}
+
+// parseTag reads and validates a tag payload and returns its normalized
+// name, writing the error and reporting false if it's invalid.
+func (h *Handler) parseTag(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var payload types.TagPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
+		return "", false
+	}
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return "", false
+	}
+
+	name := NormalizeTag(payload.Name)
+	if name == "" {
+		netjson.WriteError(w, r, types.Errorf(types.ErrValidation, "tag names can't be blank"))
+		return "", false
+	}
+	return name, true
+}
+
+// writeTag writes the tag with an ID.
+func (h *Handler) writeTag(w http.ResponseWriter, r *http.Request, status, id int) {
+	tags, err := h.store.ListTags(r.Context())
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	for _, t := range tags {
+		if t.ID == id {
+			netjson.Write(w, status, t)
+			return
+		}
+	}
+	netjson.WriteError(w, r, types.Errorf(types.ErrNotFound, "tag %d not found", id))
+}
+
+// writeCategory writes the category with an ID.
+func (h *Handler) writeCategory(w http.ResponseWriter, r *http.Request, status, id int) {
+	categories, err := h.store.ListCategories(r.Context())
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	for _, c := range categories {
+		if c.ID == id {
+			netjson.Write(w, status, c)
+			return
+		}
+	}
+	netjson.WriteError(w, r, types.Errorf(types.ErrNotFound, "category %d not found", id))
+}
+
+// pathID parses an integer path variable.
+func pathID(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		return 0, types.Errorf(types.ErrBadRequest, "invalid %s", name)
+	}
+	return id, nil
+}
@@ -0,0 +1,213 @@
+package category
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/davidado/go-api-reference/config"
+	"github.com/davidado/go-api-reference/memstore"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/types"
+	"github.com/gorilla/mux"
+)
+
+func TestCategories(t *testing.T) {
+	ctx := context.Background()
+
+	admins := config.Envs.AdminEmails
+	config.Envs.AdminEmails = []string{"admin@example.com"}
+	t.Cleanup(func() { config.Envs.AdminEmails = admins })
+
+	newUser := func(t *testing.T, s *memstore.Store, email string) string {
+		t.Helper()
+
+		if err := s.CreateUser(ctx, types.User{FirstName: "A", LastName: "B", Email: email, Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.GetUserByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	s := memstore.New()
+	admin := newUser(t, s, "admin@example.com")
+	buyer := newUser(t, s, "buyer@example.com")
+	router := mux.NewRouter()
+	NewHandler(s, s).RegisterRoutes(router)
+
+	do := func(t *testing.T, method, path, token string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+
+		var buf bytes.Buffer
+		if body != nil {
+			if err := json.NewEncoder(&buf).Encode(body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// create creates a category and fails the test unless it's created.
+	create := func(t *testing.T, payload types.CategoryPayload) types.Category {
+		t.Helper()
+
+		rr := do(t, http.MethodPost, "/categories", admin, payload)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		var c types.Category
+		if err := json.NewDecoder(rr.Body).Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	kitchen := create(t, types.CategoryPayload{Name: "Kitchen", Slug: "kitchen", SortOrder: 1})
+	apparel := create(t, types.CategoryPayload{Name: "Apparel", Slug: "apparel", SortOrder: 2})
+	mugs := create(t, types.CategoryPayload{ParentID: &kitchen.ID, Name: "Mugs", Slug: "mugs"})
+	espresso := create(t, types.CategoryPayload{ParentID: &mugs.ID, Name: "Espresso cups", Slug: "espresso-cups"})
+
+	t.Run("should list the categories as a tree", func(t *testing.T) {
+		rr := do(t, http.MethodGet, "/categories", "", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var tree []types.CategoryNode
+		if err := json.NewDecoder(rr.Body).Decode(&tree); err != nil {
+			t.Fatal(err)
+		}
+		if len(tree) != 2 || tree[0].ID != kitchen.ID || tree[1].ID != apparel.ID || len(tree[1].Children) != 0 {
+			t.Fatalf("expected kitchen and apparel at the top, got %+v", tree)
+		}
+		if c := tree[0].Children; len(c) != 1 || c[0].ID != mugs.ID || len(c[0].Children) != 1 || c[0].Children[0].ID != espresso.ID {
+			t.Errorf("expected mugs under kitchen and espresso cups under mugs, got %+v", c)
+		}
+	})
+
+	t.Run("should require an admin", func(t *testing.T) {
+		rr := do(t, http.MethodPost, "/categories", buyer, types.CategoryPayload{Name: "Garden", Slug: "garden"})
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should refuse invalid categories", func(t *testing.T) {
+		unknown := 42
+		tests := []struct {
+			name    string
+			method  string
+			path    string
+			payload types.CategoryPayload
+			want    int
+		}{
+			{"bad slug", http.MethodPost, "/categories", types.CategoryPayload{Name: "Garden", Slug: "Garden Tools"}, http.StatusBadRequest},
+			{"taken slug", http.MethodPost, "/categories", types.CategoryPayload{Name: "Mugs", Slug: "mugs"}, http.StatusConflict},
+			{"unknown parent", http.MethodPost, "/categories", types.CategoryPayload{ParentID: &unknown, Name: "Garden", Slug: "garden"}, http.StatusBadRequest},
+			{"under its subcategory", http.MethodPut, fmt.Sprintf("/categories/%d", kitchen.ID), types.CategoryPayload{ParentID: &espresso.ID, Name: "Kitchen", Slug: "kitchen"}, http.StatusBadRequest},
+			{"unknown category", http.MethodPut, "/categories/42", types.CategoryPayload{Name: "Garden", Slug: "garden"}, http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			if rr := do(t, tt.method, tt.path, admin, tt.payload); rr.Code != tt.want {
+				t.Errorf("%s: expected status code %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body)
+			}
+		}
+	})
+
+	t.Run("should move a category", func(t *testing.T) {
+		rr := do(t, http.MethodPut, fmt.Sprintf("/categories/%d", espresso.ID), admin, types.CategoryPayload{ParentID: &kitchen.ID, Name: "Espresso cups", Slug: "espresso-cups", SortOrder: 1})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var c types.Category
+		if err := json.NewDecoder(rr.Body).Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+		if c.ParentID == nil || *c.ParentID != kitchen.ID || c.SortOrder != 1 {
+			t.Errorf("expected espresso cups under kitchen, got %+v", c)
+		}
+	})
+
+	t.Run("should delete categories without subcategories", func(t *testing.T) {
+		if rr := do(t, http.MethodDelete, fmt.Sprintf("/categories/%d", kitchen.ID), admin, nil); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if rr := do(t, http.MethodDelete, fmt.Sprintf("/categories/%d", apparel.ID), admin, nil); rr.Code != http.StatusNoContent {
+			t.Errorf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+	})
+
+	t.Run("should tag products with normalized tags", func(t *testing.T) {
+		mugID, err := s.CreateProduct(ctx, types.Product{Name: "Mug", Price: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := do(t, http.MethodPut, fmt.Sprintf("/products/%d/tags", mugID), admin, types.ProductTagsPayload{Tags: []string{"Sale ", "new", "sale"}})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		var tags []string
+		if err := json.NewDecoder(rr.Body).Decode(&tags); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(tags, []string{"new", "sale"}) {
+			t.Errorf("expected the tags new and sale, got %v", tags)
+		}
+
+		if rr := do(t, http.MethodPost, "/tags", admin, types.TagPayload{Name: "SALE"}); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if rr := do(t, http.MethodPut, fmt.Sprintf("/products/%d/tags", mugID), admin, types.ProductTagsPayload{Tags: []string{"  "}}); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d for a blank tag, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		rr = do(t, http.MethodPut, fmt.Sprintf("/products/%d/categories", mugID), admin, types.ProductCategoriesPayload{CategoryIDs: []int{mugs.ID}})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if rr := do(t, http.MethodPut, "/products/42/categories", admin, types.ProductCategoriesPayload{CategoryIDs: []int{mugs.ID}}); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should list tags as CSV", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/tags", nil)
+		req.Header.Set("Accept", "text/csv")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if header, _, _ := strings.Cut(rr.Body.String(), "\n"); header != "id,name,createdAt" {
+			t.Errorf("expected the header id,name,createdAt, got %q", header)
+		}
+		if n := strings.Count(rr.Body.String(), "\n"); n != 3 {
+			t.Errorf("expected a header and 2 tags, got %d lines", n)
+		}
+	})
+}
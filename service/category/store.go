@@ -0,0 +1,431 @@
+// Package category : Category and tag service
+package category
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/types"
+)
+
+var (
+	// categoriesTable lists the columns scanRowIntoCategory reads, in
+	// order.
+	categoriesTable = db.Table{
+		Name:    "categories",
+		Columns: []string{"id", "parent_id", "name", "slug", "sort_order", "created_at"},
+	}
+
+	// tagsTable lists the columns ListTags reads, in order.
+	tagsTable = db.Table{
+		Name:    "tags",
+		Columns: []string{"id", "name", "created_at"},
+	}
+
+	// productCategoriesTable and productTagsTable link products to their
+	// categories and tags.
+	productCategoriesTable = db.Table{
+		Name:    "product_categories",
+		Columns: []string{"product_id", "category_id"},
+	}
+	productTagsTable = db.Table{
+		Name:    "product_tags",
+		Columns: []string{"product_id", "tag_id"},
+	}
+)
+
+// Tables : Tables and columns the store expects the migrations to create
+func Tables() []db.Table {
+	return []db.Table{categoriesTable, tagsTable, productCategoriesTable, productTagsTable}
+}
+
+// Store : Category and tag store
+type Store struct {
+	db *db.DB
+}
+
+// NewStore creates a new category and tag store
+func NewStore(db *db.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateCategory creates a category under its parent, if it has one
+func (s *Store) CreateCategory(ctx context.Context, c types.Category) (int, error) {
+	var id int
+	err := s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		if c.ParentID != nil {
+			if _, err := getCategory(ctx, tx, *c.ParentID); err != nil {
+				return parentError(err, *c.ParentID)
+			}
+		}
+
+		var err error
+		id, err = tx.InsertID(ctx, "INSERT INTO categories (parent_id, name, slug, sort_order) VALUES (?, ?, ?, ?)",
+			parentID(c), c.Name, c.Slug, c.SortOrder)
+		if err != nil && s.db.Dialect.IsUniqueViolation(err) {
+			return types.Errorf(types.ErrConflict, "category %s already exists", c.Slug)
+		}
+		return err
+	})
+	return id, err
+}
+
+// UpdateCategory updates a category, refusing to move it under itself or
+// one of its descendants
+func (s *Store) UpdateCategory(ctx context.Context, c types.Category) error {
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		if _, err := getCategory(ctx, tx, c.ID); err != nil {
+			return err
+		}
+
+		// Walk up from the new parent: meeting the category means it
+		// would become its own ancestor.
+		for id := c.ParentID; id != nil; {
+			if *id == c.ID {
+				return types.Errorf(types.ErrValidation, "category %d can't be moved under itself or its subcategories", c.ID)
+			}
+			parent, err := getCategory(ctx, tx, *id)
+			if err != nil {
+				return parentError(err, *id)
+			}
+			id = parent.ParentID
+		}
+
+		_, err := tx.ExecContext(ctx, "UPDATE categories SET parent_id = ?, name = ?, slug = ?, sort_order = ? WHERE id = ?",
+			parentID(c), c.Name, c.Slug, c.SortOrder, c.ID)
+		if err != nil && s.db.Dialect.IsUniqueViolation(err) {
+			return types.Errorf(types.ErrConflict, "category %s already exists", c.Slug)
+		}
+		return err
+	})
+}
+
+// DeleteCategory deletes a category without children and unlinks its
+// products and promotions
+func (s *Store) DeleteCategory(ctx context.Context, id int) error {
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		if _, err := getCategory(ctx, tx, id); err != nil {
+			return err
+		}
+
+		var children int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM categories WHERE parent_id = ?", id).Scan(&children); err != nil {
+			return err
+		}
+		if children > 0 {
+			return types.Errorf(types.ErrConflict, "category %d has subcategories", id)
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM product_categories WHERE category_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM promotion_categories WHERE category_id = ?", id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM categories WHERE id = ?", id)
+		return err
+	})
+}
+
+// ListCategories lists every category by sort order, then name
+func (s *Store) ListCategories(ctx context.Context) ([]types.Category, error) {
+	rows, err := s.db.Read(ctx).QueryContext(ctx, categoriesTable.Select("ORDER BY sort_order, name, id"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCategories(rows)
+}
+
+// CreateTag creates a tag
+func (s *Store) CreateTag(ctx context.Context, name string) (int, error) {
+	id, err := s.db.InsertID(ctx, "INSERT INTO tags (name) VALUES (?)", name)
+	if err != nil && s.db.Dialect.IsUniqueViolation(err) {
+		return 0, types.Errorf(types.ErrConflict, "tag %s already exists", name)
+	}
+	return id, err
+}
+
+// RenameTag renames a tag
+func (s *Store) RenameTag(ctx context.Context, id int, name string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE tags SET name = ? WHERE id = ?", name, id)
+	if err != nil {
+		if s.db.Dialect.IsUniqueViolation(err) {
+			return types.Errorf(types.ErrConflict, "tag %s already exists", name)
+		}
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return types.Errorf(types.ErrNotFound, "tag %d not found", id)
+	}
+	return nil
+}
+
+// DeleteTag deletes a tag and unlinks its products
+func (s *Store) DeleteTag(ctx context.Context, id int) error {
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM product_tags WHERE tag_id = ?", id); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return types.Errorf(types.ErrNotFound, "tag %d not found", id)
+		}
+		return nil
+	})
+}
+
+// ListTags lists every tag by name
+func (s *Store) ListTags(ctx context.Context) ([]types.Tag, error) {
+	rows, err := s.db.Read(ctx).QueryContext(ctx, tagsTable.Select("ORDER BY name"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []types.Tag{}
+	for rows.Next() {
+		t := types.Tag{}
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+// SetProductCategories replaces the categories of a product
+func (s *Store) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error {
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		if err := lockProduct(ctx, tx, productID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM product_categories WHERE product_id = ?", productID); err != nil {
+			return err
+		}
+
+		linked := map[int]bool{}
+		for _, id := range categoryIDs {
+			if linked[id] {
+				continue
+			}
+			linked[id] = true
+
+			if _, err := getCategory(ctx, tx, id); err != nil {
+				return parentError(err, id)
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO product_categories (product_id, category_id) VALUES (?, ?)", productID, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetProductTags replaces the tags of a product, creating the tags it names
+// that don't exist yet
+func (s *Store) SetProductTags(ctx context.Context, productID int, tags []string) error {
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		if err := lockProduct(ctx, tx, productID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM product_tags WHERE product_id = ?", productID); err != nil {
+			return err
+		}
+
+		linked := map[string]bool{}
+		for _, name := range tags {
+			if linked[name] {
+				continue
+			}
+			linked[name] = true
+
+			var id int
+			err := tx.QueryRowContext(ctx, "SELECT id FROM tags WHERE name = ?", name).Scan(&id)
+			if errors.Is(err, sql.ErrNoRows) {
+				id, err = tx.InsertID(ctx, "INSERT INTO tags (name) VALUES (?)", name)
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO product_tags (product_id, tag_id) VALUES (?, ?)", productID, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetProductCategories gets the categories of a product, sorted like
+// ListCategories
+func (s *Store) GetProductCategories(ctx context.Context, productID int) ([]types.Category, error) {
+	rows, err := s.db.Read(ctx).QueryContext(ctx,
+		categoriesTable.Select("WHERE id IN (SELECT category_id FROM product_categories WHERE product_id = ?) ORDER BY sort_order, name, id"), productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCategories(rows)
+}
+
+// GetProductTags gets the names of the tags of a product, sorted
+func (s *Store) GetProductTags(ctx context.Context, productID int) ([]string, error) {
+	rows, err := s.db.Read(ctx).QueryContext(ctx,
+		"SELECT t.name FROM tags t JOIN product_tags pt ON pt.tag_id = t.id WHERE pt.product_id = ? ORDER BY t.name", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+
+	return tags, rows.Err()
+}
+
+// lookupChunkSize caps the IDs GetCategoryIDsByProduct sends in one query,
+// well under the 999 parameters older SQLite builds allow.
+const lookupChunkSize = 500
+
+// GetCategoryIDsByProduct maps products to the IDs of their categories,
+// leaving out products without any. Long lists are looked up in chunks.
+func (s *Store) GetCategoryIDsByProduct(ctx context.Context, productIDs []int) (map[int][]int, error) {
+	ids := slices.Clone(productIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	categoryIDs := map[int][]int{}
+	for len(ids) > 0 {
+		chunk := ids[:min(len(ids), lookupChunkSize)]
+		ids = ids[len(chunk):]
+
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		query := productCategoriesTable.Select(fmt.Sprintf("WHERE product_id IN (?%s) ORDER BY product_id, category_id", strings.Repeat(",?", len(chunk)-1)))
+		if err := s.scanProductCategories(ctx, query, args, categoryIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	return categoryIDs, nil
+}
+
+// scanProductCategories adds the product and category pairs query reads
+// to categoryIDs.
+func (s *Store) scanProductCategories(ctx context.Context, query string, args []any, categoryIDs map[int][]int) error {
+	rows, err := s.db.Read(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID, categoryID int
+		if err := rows.Scan(&productID, &categoryID); err != nil {
+			return err
+		}
+		categoryIDs[productID] = append(categoryIDs[productID], categoryID)
+	}
+
+	return rows.Err()
+}
+
+// lockProduct locks a product, so concurrent updates of its links don't
+// interleave, failing with ErrNotFound if it doesn't exist.
+func lockProduct(ctx context.Context, tx *db.Tx, productID int) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET quantity = quantity WHERE id = ?", productID); err != nil {
+		return err
+	}
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM products WHERE id = ?", productID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.Errorf(types.ErrNotFound, "product %d not found", productID)
+	}
+	return err
+}
+
+func getCategory(ctx context.Context, tx *db.Tx, id int) (*types.Category, error) {
+	rows, err := tx.QueryContext(ctx, categoriesTable.Select("WHERE id = ?"), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, types.Errorf(types.ErrNotFound, "category %d not found", id)
+	}
+	return scanRowIntoCategory(rows)
+}
+
+// parentError reports a category a payload refers to that doesn't exist as
+// a validation error rather than a missing resource.
+func parentError(err error, id int) error {
+	if errors.Is(err, types.ErrNotFound) {
+		return types.Errorf(types.ErrValidation, "category %d not found", id)
+	}
+	return err
+}
+
+// parentID stores top-level categories with a NULL parent.
+func parentID(c types.Category) any {
+	if c.ParentID == nil {
+		return nil
+	}
+	return *c.ParentID
+}
+
+func scanCategories(rows *sql.Rows) ([]types.Category, error) {
+	categories := []types.Category{}
+	for rows.Next() {
+		c, err := scanRowIntoCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *c)
+	}
+
+	return categories, rows.Err()
+}
+
+func scanRowIntoCategory(rows *sql.Rows) (*types.Category, error) {
+	c := &types.Category{}
+	var parentID sql.NullInt64
+	if err := rows.Scan(&c.ID, &parentID, &c.Name, &c.Slug, &c.SortOrder, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	return c, nil
+}
@@ -0,0 +1,68 @@
+package category
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// slugPattern matches lowercase words joined by hyphens, e.g. "t-shirts".
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Tree arranges categories, sorted like ListCategories, under their parents.
+// Children keep the order of categories.
+func Tree(categories []types.Category) []types.CategoryNode {
+	children := map[int][]types.Category{}
+	var roots []types.Category
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+			continue
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], c)
+	}
+
+	var build func([]types.Category) []types.CategoryNode
+	build = func(cs []types.Category) []types.CategoryNode {
+		nodes := make([]types.CategoryNode, len(cs))
+		for i, c := range cs {
+			nodes[i] = types.CategoryNode{Category: c, Children: build(children[c.ID])}
+		}
+		return nodes
+	}
+	return build(roots)
+}
+
+// Descendants returns the ID of a category followed by the IDs of all the
+// categories under it.
+func Descendants(categories []types.Category, id int) []int {
+	children := map[int][]int{}
+	for _, c := range categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+// FindBySlug returns the category with a slug from categories.
+func FindBySlug(categories []types.Category, slug string) (types.Category, bool) {
+	for _, c := range categories {
+		if c.Slug == slug {
+			return c, true
+		}
+	}
+	return types.Category{}, false
+}
+
+// NormalizeTag returns the name tags are stored and looked up by: trimmed
+// and lowercase, so "Summer " and "summer" are the same tag.
+func NormalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
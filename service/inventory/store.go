@@ -0,0 +1,277 @@
+// Package inventory : Stock reservation service
+package inventory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// AvailableStock is an SQL expression of the stock of a row of products
+// left for new orders: what's on hand minus the active reservations.
+// Reservations of the product's variants hold the variants' stock instead.
+const AvailableStock = "quantity - COALESCE((SELECT SUM(r.quantity) FROM inventory_reservations r WHERE r.product_id = products.id AND r.variant_id IS NULL AND r.status = 'active'), 0)"
+
+// VariantAvailableStock is AvailableStock for a row of product_variants.
+const VariantAvailableStock = "quantity - COALESCE((SELECT SUM(r.quantity) FROM inventory_reservations r WHERE r.variant_id = product_variants.id AND r.status = 'active'), 0)"
+
+// reservationsTable lists the columns scanRowIntoReservation reads, in
+// order.
+var reservationsTable = db.Table{
+	Name:    "inventory_reservations",
+	Columns: []string{"id", "order_id", "product_id", "quantity", "status", "expires_at", "created_at", "variant_id"},
+}
+
+// Tables : Tables and columns the store expects the migrations to create
+func Tables() []db.Table {
+	return []db.Table{reservationsTable}
+}
+
+// Store : Stock reservation store
+type Store struct {
+	db *db.DB
+}
+
+// NewStore creates a new stock reservation store
+func NewStore(db *db.DB) *Store {
+	return &Store{db: db}
+}
+
+// stock identifies the stock an item takes: its product's, or its
+// variant's when it has one.
+type stock struct {
+	productID, variantID int
+}
+
+// ReserveStock holds the items of an order until expiresAt, or fails with
+// ErrOutOfStock without reserving anything
+func (s *Store) ReserveStock(ctx context.Context, orderID int, items []types.CartItem, expiresAt time.Time) error {
+	quantities := map[stock]int{}
+	for _, item := range items {
+		quantities[stock{item.ProductID, item.VariantID}] += item.Quantity
+	}
+	stocks := make([]stock, 0, len(quantities))
+	for k := range quantities {
+		stocks = append(stocks, k)
+	}
+	slices.SortFunc(stocks, func(a, b stock) int {
+		return cmp.Or(cmp.Compare(a.productID, b.productID), cmp.Compare(a.variantID, b.variantID))
+	})
+
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		// Lock the stock first, in ID order so concurrent checkouts don't
+		// deadlock, then read it: reads after the locks see the
+		// reservations of the checkouts that held them.
+		for _, k := range stocks {
+			lock := "UPDATE products SET quantity = quantity WHERE id = ?"
+			id := k.productID
+			if k.variantID != 0 {
+				lock = "UPDATE product_variants SET quantity = quantity WHERE id = ?"
+				id = k.variantID
+			}
+			if _, err := tx.ExecContext(ctx, lock, id); err != nil {
+				return err
+			}
+		}
+
+		for _, k := range stocks {
+			var available int
+			var err error
+			if k.variantID != 0 {
+				err = tx.QueryRowContext(ctx, "SELECT "+VariantAvailableStock+" FROM product_variants WHERE id = ? AND product_id = ?", k.variantID, k.productID).Scan(&available)
+			} else {
+				err = tx.QueryRowContext(ctx, "SELECT "+AvailableStock+" FROM products WHERE id = ?", k.productID).Scan(&available)
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				return types.Errorf(types.ErrNotFound, "%s not found", k)
+			}
+			if err != nil {
+				return err
+			}
+			if available < quantities[k] {
+				return types.Errorf(types.ErrOutOfStock, "%s is out of stock", k)
+			}
+
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO inventory_reservations (order_id, product_id, variant_id, quantity, status, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+				orderID, k.productID, variantID(k.variantID), quantities[k], types.ReservationActive, expiresAt.UTC())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (k stock) String() string {
+	if k.variantID != 0 {
+		return fmt.Sprintf("variant %d of product %d", k.variantID, k.productID)
+	}
+	return fmt.Sprintf("product %d", k.productID)
+}
+
+// variantID stores the reservations of products without variants with a
+// NULL variant, which the foreign key lets through.
+func variantID(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// CommitReservations takes the active reservations of an order off the
+// stock of its products and variants. Committing twice does nothing.
+func (s *Store) CommitReservations(ctx context.Context, orderID int) error {
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		return commitReservations(ctx, tx, orderID)
+	})
+}
+
+// CommitPaidOrder records the capture of a payment: it marks the payment
+// captured, takes the order's reservations off the stock and marks the
+// order paid, all or nothing.
+func (s *Store) CommitPaidOrder(ctx context.Context, orderID, paymentID int) error {
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE payments SET status = ? WHERE id = ?", types.PaymentCaptured, paymentID); err != nil {
+			return err
+		}
+		if err := commitReservations(ctx, tx, orderID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE orders SET status = ? WHERE id = ?", types.OrderPaid, orderID)
+		return err
+	})
+}
+
+// commitReservations takes the active reservations of an order off the
+// stock in tx.
+func commitReservations(ctx context.Context, tx *db.Tx, orderID int) error {
+	reservations, err := getReservations(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	committed, released := 0, 0
+	for _, r := range reservations {
+		if r.Status == types.ReservationReleased {
+			released++
+		}
+		if r.Status != types.ReservationActive {
+			continue
+		}
+
+		// The status check makes a concurrent release and commit of
+		// the same reservation exclusive.
+		res, err := tx.ExecContext(ctx, "UPDATE inventory_reservations SET status = ? WHERE id = ? AND status = ?", types.ReservationCommitted, r.ID, types.ReservationActive)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			released++
+			continue
+		}
+		take := "UPDATE products SET quantity = quantity - ? WHERE id = ?"
+		id := r.ProductID
+		if r.VariantID != 0 {
+			take = "UPDATE product_variants SET quantity = quantity - ? WHERE id = ?"
+			id = r.VariantID
+		}
+		if _, err := tx.ExecContext(ctx, take, r.Quantity, id); err != nil {
+			return err
+		}
+		committed++
+	}
+
+	if committed == 0 && released > 0 {
+		return types.Errorf(types.ErrConflict, "the reservations of order %d have been released", orderID)
+	}
+	return nil
+}
+
+// ReleaseReservations releases the active reservations of an order
+func (s *Store) ReleaseReservations(ctx context.Context, orderID int) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE inventory_reservations SET status = ? WHERE order_id = ? AND status = ?", types.ReservationReleased, orderID, types.ReservationActive)
+	return err
+}
+
+// ReleaseExpiredReservations releases the active reservations that expired
+// by now and returns the IDs of their orders
+func (s *Store) ReleaseExpiredReservations(ctx context.Context, now time.Time) ([]int, error) {
+	var orderIDs []int
+	err := s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		orderIDs = nil
+
+		rows, err := tx.QueryContext(ctx, "SELECT DISTINCT order_id FROM inventory_reservations WHERE status = ? AND expires_at <= ? ORDER BY order_id", types.ReservationActive, now.UTC())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			orderIDs = append(orderIDs, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		_, err = tx.ExecContext(ctx, "UPDATE inventory_reservations SET status = ? WHERE status = ? AND expires_at <= ?", types.ReservationReleased, types.ReservationActive, now.UTC())
+		return err
+	})
+	return orderIDs, err
+}
+
+// GetReservations gets the reservations of an order in ID order
+func (s *Store) GetReservations(ctx context.Context, orderID int) ([]types.Reservation, error) {
+	return getReservations(ctx, s.db, orderID)
+}
+
+// querier is implemented by *db.DB and *db.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func getReservations(ctx context.Context, q querier, orderID int) ([]types.Reservation, error) {
+	rows, err := q.QueryContext(ctx, reservationsTable.Select("WHERE order_id = ? ORDER BY id"), orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := []types.Reservation{}
+	for rows.Next() {
+		r, err := scanRowIntoReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, *r)
+	}
+
+	return reservations, rows.Err()
+}
+
+func scanRowIntoReservation(rows *sql.Rows) (*types.Reservation, error) {
+	r := &types.Reservation{}
+	var variantID sql.NullInt64
+	err := rows.Scan(&r.ID, &r.OrderID, &r.ProductID, &r.Quantity, &r.Status, &r.ExpiresAt, &r.CreatedAt, &variantID)
+	if err != nil {
+		return nil, err
+	}
+	r.VariantID = int(variantID.Int64)
+	return r, nil
+}
@@ -0,0 +1,100 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// Sweeper : Releases expired reservations and fails their orders
+//
+// A reservation expires when its order isn't paid in time, e.g. when the
+// customer never confirms a 3-D Secure payment. The order's authorization
+// is voided, so a late webhook can't capture it.
+type Sweeper struct {
+	store        types.InventoryStore
+	orderStore   types.OrderStore
+	paymentStore types.PaymentStore
+	payments     types.PaymentProvider
+}
+
+// NewSweeper creates a sweeper voiding authorizations through payments
+func NewSweeper(store types.InventoryStore, orderStore types.OrderStore, paymentStore types.PaymentStore, payments types.PaymentProvider) *Sweeper {
+	return &Sweeper{store: store, orderStore: orderStore, paymentStore: paymentStore, payments: payments}
+}
+
+// Run sweeps every interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sweep(ctx, time.Now()); err != nil {
+				log.Printf("inventory: sweep: %v", err)
+			}
+		}
+	}
+}
+
+// Sweep releases the reservations that expired by now and fails their
+// pending orders. An order that can't be failed is logged and skipped;
+// its stock is released regardless.
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) error {
+	orderIDs, err := s.store.ReleaseExpiredReservations(ctx, now)
+	if err != nil {
+		return fmt.Errorf("release expired reservations: %w", err)
+	}
+
+	for _, id := range orderIDs {
+		if err := s.expire(ctx, id); err != nil {
+			log.Printf("inventory: expire order %d: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// expire voids the pending payments of an order and marks it failed. An
+// order with a captured payment, or a payment that can't be voided and may
+// have been captured, is left pending for review: the customer paid for it.
+func (s *Sweeper) expire(ctx context.Context, orderID int) error {
+	order, err := s.orderStore.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != types.OrderPending {
+		return nil
+	}
+
+	payments, err := s.paymentStore.GetPaymentsByOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	for _, p := range payments {
+		if p.Status == types.PaymentCaptured {
+			return fmt.Errorf("payment %s was captured, leaving the order for review", p.Reference)
+		}
+	}
+	for _, p := range payments {
+		if p.Status != types.PaymentAuthorized && p.Status != types.PaymentRequiresAction {
+			continue
+		}
+		if _, err := s.payments.Void(ctx, p.Reference); err != nil {
+			return fmt.Errorf("void payment %s, leaving the order for review: %w", p.Reference, err)
+		}
+		if err := s.paymentStore.UpdatePaymentStatus(ctx, p.ID, types.PaymentVoided); err != nil {
+			return fmt.Errorf("update payment %d: %w", p.ID, err)
+		}
+	}
+
+	return s.orderStore.UpdateOrderStatus(ctx, orderID, types.OrderFailed)
+}
@@ -0,0 +1,53 @@
+package order
+
+import (
+	"net/http"
+
+	"github.com/davidado/go-api-reference/middleware"
+	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/types"
+	"github.com/gorilla/mux"
+)
+
+// Handler : Order handler
+type Handler struct {
+	store     types.OrderStore
+	userStore types.UserStore
+}
+
+// NewHandler creates a new order handler
+func NewHandler(store types.OrderStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+// RegisterRoutes registers order routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/orders", auth.WithJWTAuth(middleware.NoDeadline(h.handleGetOrders), h.userStore)).Methods(http.MethodGet)
+}
+
+// Operations describes the order routes for the OpenAPI document
+func (h *Handler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{
+			Method:     http.MethodGet,
+			Path:       "/orders",
+			Summary:    "List the orders of the logged in user",
+			Tags:       []string{"orders"},
+			Auth:       true,
+			Response:   []types.Order{},
+			MediaTypes: netjson.MediaTypes(),
+		},
+	}
+}
+
+// handleGetOrders streams the orders of the logged in user in the format
+// negotiated from the Accept header
+func (h *Handler) handleGetOrders(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	netjson.WriteList(w, r, http.StatusOK, func(yield func(types.Order) error) error {
+		return h.store.StreamOrdersByUser(r.Context(), userID, yield)
+	})
+}
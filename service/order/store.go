@@ -2,38 +2,272 @@
 package order
 
 import (
+	"context"
 	"database/sql"
 
+	"github.com/davidado/go-api-reference/db"
 	"github.com/davidado/go-api-reference/types"
 )
 
+// ordersTable lists the columns scanRowIntoOrder reads, in order.
+var ordersTable = db.Table{
+	Name: "orders",
+	Columns: []string{
+		"id", "user_id", "total", "status", "address", "tax_inclusive",
+		"shipping_method_id", "shipping_method", "shipping_cost", "created_at",
+	},
+}
+
+// orderItemsTable lists the columns scanRowIntoOrderItem reads, in order.
+var orderItemsTable = db.Table{
+	Name:    "order_items",
+	Columns: []string{"id", "order_id", "product_id", "quantity", "price", "tax_rate", "tax", "variant_id"},
+}
+
+// orderDiscountsTable lists the columns scanRowIntoOrderDiscount reads, in
+// order.
+var orderDiscountsTable = db.Table{
+	Name:    "order_discounts",
+	Columns: []string{"id", "order_id", "promotion_id", "name", "code", "amount"},
+}
+
+// Tables : Tables and columns the store expects the migrations to create
+func Tables() []db.Table {
+	return []db.Table{ordersTable, orderItemsTable, orderDiscountsTable}
+}
+
 // Store : Order store
 type Store struct {
-	db *sql.DB
+	db *db.DB
 }
 
 // NewStore creates a new order store
-func NewStore(db *sql.DB) *Store {
+func NewStore(db *db.DB) *Store {
 	return &Store{db: db}
 }
 
-// CreateOrder creates a new order
-func (s *Store) CreateOrder(o types.Order) (int, error) {
-	res, err := s.db.Exec("INSERT INTO orders (user_id, total, status, address) VALUES (?, ?, ?, ?)", o.UserID, o.Total, o.Status, o.Address)
-	if err != nil {
-		return 0, err
+// insertOrder inserts an order without its discounts.
+const insertOrder = "INSERT INTO orders (user_id, total, status, address, tax_inclusive, shipping_method_id, shipping_method, shipping_cost) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+// CreateOrder creates a new order with its discounts. Each discount counts
+// a use of its promotion in the same transaction, which fails with
+// ErrCoupon when the promotion has been used up, overall or by the user.
+func (s *Store) CreateOrder(ctx context.Context, o types.Order) (int, error) {
+	if len(o.Discounts) == 0 {
+		return s.db.InsertID(ctx, insertOrder, o.UserID, o.Total, o.Status, o.Address, o.TaxInclusive, shippingMethodID(o), o.ShippingMethod, o.ShippingCost)
+	}
+
+	var id int
+	err := s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		var err error
+		id, err = tx.InsertID(ctx, insertOrder, o.UserID, o.Total, o.Status, o.Address, o.TaxInclusive, shippingMethodID(o), o.ShippingMethod, o.ShippingCost)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range o.Discounts {
+			if err := redeem(ctx, tx, o.UserID, d); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO order_discounts (order_id, promotion_id, name, code, amount) VALUES (?, ?, ?, ?, ?)", id, d.PromotionID, d.Name, d.Code, d.Amount)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+// shippingMethodID stores orders without a shipping method with a NULL
+// method, which the foreign key lets through.
+func shippingMethodID(o types.Order) any {
+	if o.ShippingMethodID == 0 {
+		return nil
+	}
+	return o.ShippingMethodID
+}
+
+// variantID stores items of products without variants with a NULL
+// variant.
+func variantID(oi types.OrderItem) any {
+	if oi.VariantID == 0 {
+		return nil
 	}
+	return oi.VariantID
+}
 
-	id, err := res.LastInsertId()
+// redeem counts a use of the promotion of d by a user. The UPDATE locks
+// the promotion's row, so concurrent orders can't both take its last use.
+// The user's failed orders don't count.
+func redeem(ctx context.Context, tx *db.Tx, userID int, d types.OrderDiscount) error {
+	res, err := tx.ExecContext(ctx, "UPDATE promotions SET uses = uses + 1 WHERE id = ? AND (max_uses = 0 OR uses < max_uses)", d.PromotionID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
 	if err != nil {
-		return 0, err
+		return err
+	}
+	if n == 0 {
+		return types.Errorf(types.ErrCoupon, "promotion %s has been used up", d.Name)
 	}
 
-	return int(id), nil
+	var limit, used int
+	if err := tx.QueryRowContext(ctx, "SELECT max_uses_per_user FROM promotions WHERE id = ?", d.PromotionID).Scan(&limit); err != nil {
+		return err
+	}
+	if limit == 0 {
+		return nil
+	}
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM order_discounts d JOIN orders o ON o.id = d.order_id WHERE d.promotion_id = ? AND o.user_id = ? AND o.status <> ?",
+		d.PromotionID, userID, types.OrderFailed).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used >= limit {
+		return types.Errorf(types.ErrCoupon, "promotion %s can only be used %d times", d.Name, limit)
+	}
+	return nil
 }
 
 // CreateOrderItem creates a new order item
-func (s *Store) CreateOrderItem(oi types.OrderItem) error {
-	_, err := s.db.Exec("INSERT INTO order_items (order_id, product_id, quantity, price) VALUES (?, ?, ?, ?)", oi.OrderID, oi.ProductID, oi.Quantity, oi.Price)
+func (s *Store) CreateOrderItem(ctx context.Context, oi types.OrderItem) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO order_items (order_id, product_id, variant_id, quantity, price, tax_rate, tax) VALUES (?, ?, ?, ?, ?, ?, ?)", oi.OrderID, oi.ProductID, variantID(oi), oi.Quantity, oi.Price, oi.TaxRate, oi.Tax)
 	return err
 }
+
+// GetOrderByID gets an order by ID
+func (s *Store) GetOrderByID(ctx context.Context, id int) (*types.Order, error) {
+	rows, err := s.db.QueryContext(ctx, ordersTable.Select("WHERE id = ?"), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, types.Errorf(types.ErrNotFound, "order %d not found", id)
+	}
+
+	return scanRowIntoOrder(rows)
+}
+
+// GetOrderItems gets the items of an order in ID order
+func (s *Store) GetOrderItems(ctx context.Context, orderID int) ([]types.OrderItem, error) {
+	rows, err := s.db.QueryContext(ctx, orderItemsTable.Select("WHERE order_id = ? ORDER BY id"), orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []types.OrderItem
+	for rows.Next() {
+		oi, err := scanRowIntoOrderItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *oi)
+	}
+
+	return items, rows.Err()
+}
+
+// GetOrderDiscounts gets the discount lines of an order in ID order
+func (s *Store) GetOrderDiscounts(ctx context.Context, orderID int) ([]types.OrderDiscount, error) {
+	rows, err := s.db.QueryContext(ctx, orderDiscountsTable.Select("WHERE order_id = ? ORDER BY id"), orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discounts []types.OrderDiscount
+	for rows.Next() {
+		d, err := scanRowIntoOrderDiscount(rows)
+		if err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, *d)
+	}
+
+	return discounts, rows.Err()
+}
+
+// UpdateOrderStatus sets the status of an order. An order that fails gives
+// back the uses of its promotions in the same transaction.
+func (s *Store) UpdateOrderStatus(ctx context.Context, id int, status string) error {
+	if status != types.OrderFailed {
+		_, err := s.db.ExecContext(ctx, "UPDATE orders SET status = ? WHERE id = ?", status, id)
+		return err
+	}
+
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE orders SET status = ? WHERE id = ? AND status <> ?", status, id, status)
+		if err != nil {
+			return err
+		}
+		// Only the first failure gives the uses back.
+		n, err := res.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE promotions SET uses = uses - 1 WHERE uses > 0 AND id IN (SELECT promotion_id FROM order_discounts WHERE order_id = ?)", id)
+		return err
+	})
+}
+
+// StreamOrdersByUser calls fn for every order of a user, newest first
+func (s *Store) StreamOrdersByUser(ctx context.Context, userID int, fn func(types.Order) error) error {
+	rows, err := s.db.QueryContext(ctx, ordersTable.Select("WHERE user_id = ? ORDER BY id DESC"), userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		o, err := scanRowIntoOrder(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*o); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func scanRowIntoOrder(rows *sql.Rows) (*types.Order, error) {
+	o := &types.Order{}
+	var shippingMethodID sql.NullInt64
+	err := rows.Scan(&o.ID, &o.UserID, &o.Total, &o.Status, &o.Address, &o.TaxInclusive,
+		&shippingMethodID, &o.ShippingMethod, &o.ShippingCost, &o.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	o.ShippingMethodID = int(shippingMethodID.Int64)
+	return o, nil
+}
+
+func scanRowIntoOrderItem(rows *sql.Rows) (*types.OrderItem, error) {
+	oi := &types.OrderItem{}
+	var variantID sql.NullInt64
+	err := rows.Scan(&oi.ID, &oi.OrderID, &oi.ProductID, &oi.Quantity, &oi.Price, &oi.TaxRate, &oi.Tax, &variantID)
+	if err != nil {
+		return nil, err
+	}
+	oi.VariantID = int(variantID.Int64)
+	return oi, nil
+}
+
+func scanRowIntoOrderDiscount(rows *sql.Rows) (*types.OrderDiscount, error) {
+	d := &types.OrderDiscount{}
+	err := rows.Scan(&d.ID, &d.OrderID, &d.PromotionID, &d.Name, &d.Code, &d.Amount)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,166 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// Test cards of the fake provider. Any other payment method is declined.
+const (
+	CardSuccess = "4242424242424242" // authorized
+	CardDecline = "4000000000000002" // declined
+	Card3DS     = "4000000000003220" // requires 3-D Secure, see Confirm
+)
+
+// FakeName is the name of the fake provider.
+const FakeName = "fake"
+
+// FakeProvider : In-process payment provider for development and tests
+//
+// It keeps payments in memory and decides their outcome from the test card
+// numbers, so checkout can run end to end without a real gateway.
+type FakeProvider struct {
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+	lastID   int
+}
+
+type fakePayment struct {
+	status   string
+	amount   float64
+	refunded float64
+}
+
+var _ types.PaymentProvider = (*FakeProvider)(nil)
+
+// NewFakeProvider creates a fake provider without payments
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{payments: map[string]*fakePayment{}}
+}
+
+// Name returns "fake"
+func (f *FakeProvider) Name() string {
+	return FakeName
+}
+
+// Authorize authorizes req.Amount on a test card
+func (f *FakeProvider) Authorize(_ context.Context, req types.PaymentRequest) (types.PaymentResult, error) {
+	if req.Amount <= 0 {
+		return types.PaymentResult{}, types.Errorf(types.ErrValidation, "payment amount must be positive")
+	}
+
+	var status string
+	switch req.Method {
+	case CardSuccess:
+		status = types.PaymentAuthorized
+	case Card3DS:
+		status = types.PaymentRequiresAction
+	case CardDecline:
+		return types.PaymentResult{}, types.Errorf(types.ErrPayment, "card declined")
+	default:
+		return types.PaymentResult{}, types.Errorf(types.ErrPayment, "unknown test card")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lastID++
+	ref := fmt.Sprintf("fake_%d", f.lastID)
+	f.payments[ref] = &fakePayment{status: status, amount: req.Amount}
+
+	res := types.PaymentResult{Reference: ref, Status: status}
+	if status == types.PaymentRequiresAction {
+		res.NextActionURL = "https://payments.invalid/3ds/" + ref
+	}
+	return res, nil
+}
+
+// Capture collects an authorized payment
+func (f *FakeProvider) Capture(_ context.Context, reference string) (types.PaymentResult, error) {
+	return f.transition(reference, types.PaymentCaptured, types.PaymentAuthorized)
+}
+
+// Void releases a payment that wasn't captured
+func (f *FakeProvider) Void(_ context.Context, reference string) (types.PaymentResult, error) {
+	return f.transition(reference, types.PaymentVoided, types.PaymentAuthorized, types.PaymentRequiresAction)
+}
+
+// Refund returns amount of a captured payment. The payment is refunded
+// once its whole amount is.
+func (f *FakeProvider) Refund(_ context.Context, reference string, amount float64) (types.PaymentResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, err := f.get(reference, types.PaymentCaptured)
+	if err != nil {
+		return types.PaymentResult{}, err
+	}
+	// Compare cents so float rounding doesn't refuse a full refund.
+	if amount <= 0 || math.Round((p.refunded+amount)*100) > math.Round(p.amount*100) {
+		return types.PaymentResult{}, types.Errorf(types.ErrValidation, "can't refund %.2f of the %.2f left on payment %s", amount, p.amount-p.refunded, reference)
+	}
+
+	p.refunded += amount
+	if math.Round(p.refunded*100) == math.Round(p.amount*100) {
+		p.status = types.PaymentRefunded
+	}
+	return types.PaymentResult{Reference: reference, Status: p.status}, nil
+}
+
+// Confirm completes the 3-D Secure step of a payment, as the customer
+// would on its NextActionURL, and returns the webhook the provider sends:
+// a confirmed payment is captured, a rejected one fails.
+func (f *FakeProvider) Confirm(reference string, ok bool) (types.PaymentEvent, error) {
+	to, eventType := types.PaymentCaptured, types.PaymentEventCaptured
+	if !ok {
+		to, eventType = types.PaymentFailed, types.PaymentEventFailed
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, err := f.get(reference, types.PaymentRequiresAction)
+	if err != nil {
+		return types.PaymentEvent{}, err
+	}
+	p.status = to
+
+	f.lastID++
+	return types.PaymentEvent{
+		ID:        fmt.Sprintf("evt_%d", f.lastID),
+		Type:      eventType,
+		Provider:  FakeName,
+		Reference: reference,
+	}, nil
+}
+
+// transition moves a payment in one of the from statuses to status to.
+func (f *FakeProvider) transition(reference, to string, from ...string) (types.PaymentResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, err := f.get(reference, from...)
+	if err != nil {
+		return types.PaymentResult{}, err
+	}
+	p.status = to
+	return types.PaymentResult{Reference: reference, Status: to}, nil
+}
+
+// get returns a payment in one of the from statuses. Callers must hold mu.
+func (f *FakeProvider) get(reference string, from ...string) (*fakePayment, error) {
+	p, ok := f.payments[reference]
+	if !ok {
+		return nil, types.Errorf(types.ErrNotFound, "payment %s not found", reference)
+	}
+	for _, status := range from {
+		if p.status == status {
+			return p, nil
+		}
+	}
+	return nil, types.Errorf(types.ErrConflict, "payment %s is %s", reference, p.status)
+}
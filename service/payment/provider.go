@@ -0,0 +1,17 @@
+package payment
+
+import (
+	"fmt"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// NewProvider returns the payment provider called name, as set in
+// config.Envs.PaymentProvider.
+func NewProvider(name string) (types.PaymentProvider, error) {
+	switch name {
+	case FakeName:
+		return NewFakeProvider(), nil
+	}
+	return nil, fmt.Errorf("payment: unknown provider %q", name)
+}
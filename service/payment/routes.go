@@ -0,0 +1,130 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/davidado/go-api-reference/config"
+	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
+	"github.com/davidado/go-api-reference/types"
+	vd "github.com/davidado/go-api-reference/validator"
+	"github.com/gorilla/mux"
+)
+
+// Handler : Payment webhook handler
+type Handler struct {
+	store          types.PaymentStore
+	orderStore     types.OrderStore
+	inventoryStore types.InventoryStore
+	secret         []byte
+}
+
+// NewHandler creates a new payment handler. Webhooks must be signed with
+// secret, see Sign.
+func NewHandler(store types.PaymentStore, orderStore types.OrderStore, inventoryStore types.InventoryStore, secret string) *Handler {
+	return &Handler{store: store, orderStore: orderStore, inventoryStore: inventoryStore, secret: []byte(secret)}
+}
+
+// RegisterRoutes registers payment routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/webhooks/payments", h.handleWebhook).Methods(http.MethodPost)
+}
+
+// Operations describes the payment routes for the OpenAPI document
+func (h *Handler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{
+			Method:   http.MethodPost,
+			Path:     "/webhooks/payments",
+			Summary:  "Receive a payment provider webhook, signed in the " + SignatureHeader + " header",
+			Tags:     []string{"payments"},
+			Request:  types.PaymentEvent{},
+			Response: types.MessageResponse{},
+		},
+	}
+}
+
+// handleWebhook settles a payment and its order from a signed provider
+// event. Providers resend events, so settling twice is a no-op.
+func (h *Handler) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	// The signature covers the raw body, so read it before parsing.
+	body, err := io.ReadAll(io.LimitReader(r.Body, config.Envs.MaxBodyBytes+1))
+	if err != nil {
+		netjson.WriteError(w, r, types.Errorf(types.ErrBadRequest, "read request body: %v", err))
+		return
+	}
+	if int64(len(body)) > config.Envs.MaxBodyBytes {
+		netjson.WriteError(w, r, types.Errorf(types.ErrPayloadTooLarge, "request body must not be larger than %d bytes", config.Envs.MaxBodyBytes))
+		return
+	}
+	if err := verify(h.secret, r.Header.Get(SignatureHeader), body, time.Now()); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var event types.PaymentEvent
+	if err := netjson.Parse(r, &event); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := vd.Struct(event, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	p, err := h.store.GetPaymentByReference(r.Context(), event.Provider, event.Reference)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	paymentStatus, orderStatus := types.PaymentCaptured, types.OrderPaid
+	if event.Type == types.PaymentEventFailed {
+		paymentStatus, orderStatus = types.PaymentFailed, types.OrderFailed
+	}
+
+	if err := h.settle(r.Context(), p, paymentStatus, orderStatus); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.Write(w, http.StatusOK, types.MessageResponse{Message: fmt.Sprintf("payment %s %s", p.Reference, paymentStatus)})
+}
+
+// settle moves a pending payment and its order to their final status. A
+// paid order's reserved stock is taken in the same transaction that marks
+// it paid; a failed order's stock is released.
+func (h *Handler) settle(ctx context.Context, p *types.Payment, paymentStatus, orderStatus string) error {
+	switch p.Status {
+	case paymentStatus:
+		return nil
+	case types.PaymentAuthorized, types.PaymentRequiresAction:
+	default:
+		return types.Errorf(types.ErrConflict, "payment %s is already %s", p.Reference, p.Status)
+	}
+
+	if orderStatus == types.OrderPaid {
+		if err := h.inventoryStore.CommitPaidOrder(ctx, p.OrderID, p.ID); err != nil {
+			return fmt.Errorf("commit paid order %d: %w", p.OrderID, err)
+		}
+		return nil
+	}
+
+	if err := h.inventoryStore.ReleaseReservations(ctx, p.OrderID); err != nil {
+		return fmt.Errorf("release reservations of order %d: %w", p.OrderID, err)
+	}
+	if err := h.store.UpdatePaymentStatus(ctx, p.ID, paymentStatus); err != nil {
+		return fmt.Errorf("update payment %d: %w", p.ID, err)
+	}
+	if err := h.orderStore.UpdateOrderStatus(ctx, p.OrderID, orderStatus); err != nil {
+		return fmt.Errorf("update order %d: %w", p.OrderID, err)
+	}
+	return nil
+}
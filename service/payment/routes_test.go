@@ -0,0 +1,250 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidado/go-api-reference/memstore"
+	"github.com/davidado/go-api-reference/types"
+	"github.com/gorilla/mux"
+)
+
+func TestPaymentWebhook(t *testing.T) {
+	ctx := context.Background()
+	secret := []byte("webhook-secret")
+
+	// newPayment creates a user with an order paid with a payment in the
+	// given status, holding 2 of the 5 mugs in stock, and returns the
+	// user's ID.
+	newPayment := func(t *testing.T, s *memstore.Store, reference, status string) int {
+		t.Helper()
+
+		if err := s.CreateUser(ctx, types.User{Email: reference + "@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.GetUserByEmail(ctx, reference+"@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		orderID, err := s.CreateOrder(ctx, types.Order{UserID: u.ID, Total: 10, Status: types.OrderPending})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CreatePayment(ctx, types.Payment{OrderID: orderID, Provider: FakeName, Reference: reference, Status: status, Amount: 10}); err != nil {
+			t.Fatal(err)
+		}
+		mugID, err := s.CreateProduct(ctx, types.Product{Name: "mug", Price: 5, Quantity: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: mugID, Quantity: 2}}, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		return u.ID
+	}
+
+	// mug returns the mug newPayment stocked.
+	mug := func(t *testing.T, s *memstore.Store) types.Product {
+		t.Helper()
+
+		ps, err := s.GetProducts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ps[0]
+	}
+
+	post := func(s *memstore.Store, event types.PaymentEvent, sign func(body []byte) string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/payments", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(SignatureHeader, sign(body))
+
+		router := mux.NewRouter()
+		NewHandler(s, s, s, string(secret)).RegisterRoutes(router)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	signed := func(body []byte) string { return Sign(secret, body, time.Now()) }
+
+	// orderStatus returns the status of the user's order.
+	orderStatus := func(t *testing.T, s *memstore.Store, userID int) string {
+		t.Helper()
+
+		var status string
+		err := s.StreamOrdersByUser(ctx, userID, func(o types.Order) error {
+			status = o.Status
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	t.Run("should mark captured payments paid", func(t *testing.T) {
+		s := memstore.New()
+		userID := newPayment(t, s, "fake_1", types.PaymentRequiresAction)
+
+		event := types.PaymentEvent{ID: "evt_1", Type: types.PaymentEventCaptured, Provider: FakeName, Reference: "fake_1"}
+		for i := 0; i < 2; i++ {
+			if rr := post(s, event, signed); rr.Code != http.StatusOK {
+				t.Fatalf("delivery %d: expected status code %d, got %d: %s", i+1, http.StatusOK, rr.Code, rr.Body)
+			}
+		}
+
+		p, err := s.GetPaymentByReference(ctx, FakeName, "fake_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Status != types.PaymentCaptured {
+			t.Errorf("expected the payment to be captured, got %s", p.Status)
+		}
+		if got := orderStatus(t, s, userID); got != types.OrderPaid {
+			t.Errorf("expected the order to be paid, got %s", got)
+		}
+		if p := mug(t, s); p.Quantity != 3 || p.Available != 3 {
+			t.Errorf("expected the held mugs to be taken from stock, got %+v", p)
+		}
+	})
+
+	t.Run("should mark failed payments failed", func(t *testing.T) {
+		s := memstore.New()
+		userID := newPayment(t, s, "fake_1", types.PaymentRequiresAction)
+
+		event := types.PaymentEvent{ID: "evt_1", Type: types.PaymentEventFailed, Provider: FakeName, Reference: "fake_1"}
+		if rr := post(s, event, signed); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if got := orderStatus(t, s, userID); got != types.OrderFailed {
+			t.Errorf("expected the order to be failed, got %s", got)
+		}
+		if p := mug(t, s); p.Quantity != 5 || p.Available != 5 {
+			t.Errorf("expected the held mugs to be released, got %+v", p)
+		}
+	})
+
+	t.Run("should refuse to capture an order whose stock was released", func(t *testing.T) {
+		s := memstore.New()
+		userID := newPayment(t, s, "fake_1", types.PaymentRequiresAction)
+		if _, err := s.ReleaseExpiredReservations(ctx, time.Now().Add(2*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		event := types.PaymentEvent{ID: "evt_1", Type: types.PaymentEventCaptured, Provider: FakeName, Reference: "fake_1"}
+		if rr := post(s, event, signed); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if got := orderStatus(t, s, userID); got != types.OrderPending {
+			t.Errorf("expected the order to stay pending, got %s", got)
+		}
+	})
+
+	t.Run("should refuse to fail a captured payment", func(t *testing.T) {
+		s := memstore.New()
+		newPayment(t, s, "fake_1", types.PaymentCaptured)
+
+		event := types.PaymentEvent{ID: "evt_1", Type: types.PaymentEventFailed, Provider: FakeName, Reference: "fake_1"}
+		if rr := post(s, event, signed); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("should report unknown payments", func(t *testing.T) {
+		event := types.PaymentEvent{ID: "evt_1", Type: types.PaymentEventCaptured, Provider: FakeName, Reference: "fake_9"}
+		if rr := post(memstore.New(), event, signed); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should reject bad signatures", func(t *testing.T) {
+		s := memstore.New()
+		userID := newPayment(t, s, "fake_1", types.PaymentRequiresAction)
+		event := types.PaymentEvent{ID: "evt_1", Type: types.PaymentEventCaptured, Provider: FakeName, Reference: "fake_1"}
+
+		for name, sign := range map[string]func(body []byte) string{
+			"missing":    func([]byte) string { return "" },
+			"wrong key":  func(body []byte) string { return Sign([]byte("other"), body, time.Now()) },
+			"other body": func([]byte) string { return Sign(secret, []byte("{}"), time.Now()) },
+			"too old":    func(body []byte) string { return Sign(secret, body, time.Now().Add(-time.Hour)) },
+		} {
+			if rr := post(s, event, sign); rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s: expected status code %d, got %d", name, http.StatusUnauthorized, rr.Code)
+			}
+		}
+		if got := orderStatus(t, s, userID); got != types.OrderPending {
+			t.Errorf("expected the order to stay pending, got %s", got)
+		}
+	})
+}
+
+func TestFakeProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should authorize, capture and refund", func(t *testing.T) {
+		f := NewFakeProvider()
+
+		res, err := f.Authorize(ctx, types.PaymentRequest{Amount: 10, Method: CardSuccess})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != types.PaymentAuthorized {
+			t.Fatalf("expected an authorized payment, got %+v", res)
+		}
+		if _, err := f.Capture(ctx, res.Reference); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Void(ctx, res.Reference); err == nil {
+			t.Error("expected voiding a captured payment to fail")
+		}
+		if res, err := f.Refund(ctx, res.Reference, 4); err != nil || res.Status != types.PaymentCaptured {
+			t.Fatalf("expected a partial refund, got %+v, %v", res, err)
+		}
+		if _, err := f.Refund(ctx, res.Reference, 7); err == nil {
+			t.Error("expected refunding more than was captured to fail")
+		}
+		if res, err := f.Refund(ctx, res.Reference, 6); err != nil || res.Status != types.PaymentRefunded {
+			t.Fatalf("expected a full refund, got %+v, %v", res, err)
+		}
+	})
+
+	t.Run("should decline the decline card", func(t *testing.T) {
+		_, err := NewFakeProvider().Authorize(ctx, types.PaymentRequest{Amount: 10, Method: CardDecline})
+		if !errors.Is(err, types.ErrPayment) {
+			t.Errorf("expected ErrPayment, got %v", err)
+		}
+	})
+
+	t.Run("should require 3-D Secure", func(t *testing.T) {
+		f := NewFakeProvider()
+
+		res, err := f.Authorize(ctx, types.PaymentRequest{Amount: 10, Method: Card3DS})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != types.PaymentRequiresAction || res.NextActionURL == "" {
+			t.Fatalf("expected a payment requiring action, got %+v", res)
+		}
+		if _, err := f.Capture(ctx, res.Reference); err == nil {
+			t.Error("expected capturing an unconfirmed payment to fail")
+		}
+
+		event, err := f.Confirm(res.Reference, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != types.PaymentEventFailed || event.Reference != res.Reference {
+			t.Errorf("unexpected event %+v", event)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+// Package payment : Payment service
+package payment
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// paymentsTable lists the columns scanRowIntoPayment reads, in order.
+var paymentsTable = db.Table{
+	Name:    "payments",
+	Columns: []string{"id", "order_id", "provider", "reference", "status", "amount", "refunded", "created_at"},
+}
+
+// Tables : Tables and columns the store expects the migrations to create
+func Tables() []db.Table {
+	return []db.Table{paymentsTable}
+}
+
+// Store : Payment store
+type Store struct {
+	db *db.DB
+}
+
+// NewStore creates a new payment store
+func NewStore(db *db.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreatePayment records a payment of an order
+func (s *Store) CreatePayment(ctx context.Context, p types.Payment) (int, error) {
+	id, err := s.db.InsertID(ctx, "INSERT INTO payments (order_id, provider, reference, status, amount) VALUES (?, ?, ?, ?, ?)", p.OrderID, p.Provider, p.Reference, p.Status, p.Amount)
+	if err != nil {
+		if s.db.Dialect.IsUniqueViolation(err) {
+			return 0, types.Errorf(types.ErrConflict, "payment %s of %s already exists", p.Reference, p.Provider)
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetPaymentByReference gets a payment by the provider's reference
+func (s *Store) GetPaymentByReference(ctx context.Context, provider, reference string) (*types.Payment, error) {
+	rows, err := s.db.QueryContext(ctx, paymentsTable.Select("WHERE provider = ? AND reference = ?"), provider, reference)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, types.Errorf(types.ErrNotFound, "payment %s of %s not found", reference, provider)
+	}
+
+	return scanRowIntoPayment(rows)
+}
+
+// UpdatePaymentStatus sets the status of a payment
+func (s *Store) UpdatePaymentStatus(ctx context.Context, id int, status string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE payments SET status = ? WHERE id = ?", status, id)
+	return err
+}
+
+// GetPaymentsByOrder gets the payments of an order in ID order
+func (s *Store) GetPaymentsByOrder(ctx context.Context, orderID int) ([]types.Payment, error) {
+	rows, err := s.db.QueryContext(ctx, paymentsTable.Select("WHERE order_id = ? ORDER BY id"), orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []types.Payment
+	for rows.Next() {
+		p, err := scanRowIntoPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *p)
+	}
+
+	return payments, rows.Err()
+}
+
+func scanRowIntoPayment(rows *sql.Rows) (*types.Payment, error) {
+	p := &types.Payment{}
+	err := rows.Scan(&p.ID, &p.OrderID, &p.Provider, &p.Reference, &p.Status, &p.Amount, &p.Refunded, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,67 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// SignatureHeader carries the signature of a payment webhook.
+const SignatureHeader = "Payment-Signature"
+
+// signatureTolerance is how old a webhook may be, which bounds replays.
+const signatureTolerance = 5 * time.Minute
+
+// Sign returns the SignatureHeader value of a webhook body sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of t.body>".
+func Sign(secret, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+func mac(secret []byte, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// verify checks a SignatureHeader value against the body received at now.
+func verify(secret []byte, header string, body []byte, now time.Time) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			// Several signatures may be sent while the secret is rotated.
+			if sig, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return types.Errorf(types.ErrUnauthorized, "missing or malformed %s header", SignatureHeader)
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return types.Errorf(types.ErrUnauthorized, "webhook timestamp is outside the tolerance")
+	}
+
+	want := mac(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, want) {
+			return nil
+		}
+	}
+	return types.Errorf(types.ErrUnauthorized, "invalid webhook signature")
+}
@@ -3,33 +3,279 @@ package product
 
 import (
 	"net/http"
+	"strconv"
 
+	"github.com/davidado/go-api-reference/middleware"
 	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/service/category"
 	"github.com/davidado/go-api-reference/types"
+	vd "github.com/davidado/go-api-reference/validator"
 	"github.com/gorilla/mux"
 )
 
 // Handler : Product handler
 type Handler struct {
-	store types.ProductStore
+	store         types.ProductStore
+	variantStore  types.VariantStore
+	categoryStore types.CategoryStore
+	userStore     types.UserStore
 }
 
 // NewHandler creates a new product handler
-func NewHandler(store types.ProductStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store types.ProductStore, variantStore types.VariantStore, categoryStore types.CategoryStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, variantStore: variantStore, categoryStore: categoryStore, userStore: userStore}
 }
 
 // RegisterRoutes registers product routes
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/products", h.handleGetProducts).Methods(http.MethodGet)
+	router.HandleFunc("/products", middleware.NoDeadline(h.handleGetProducts)).Methods(http.MethodGet)
+	router.HandleFunc("/products/{productID}", h.handleGetProduct).Methods(http.MethodGet)
+
+	// admin routes
+	router.HandleFunc("/products/{productID}/variants", auth.WithAdminAuth(h.handleCreateVariant, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/products/{productID}/variants/{variantID}", auth.WithAdminAuth(h.handleUpdateVariant, h.userStore)).Methods(http.MethodPut)
+}
+
+// Operations describes the product routes for the OpenAPI document
+func (h *Handler) Operations() []openapi.Operation {
+	productID := openapi.Param{Name: "productID", Type: "integer", Description: "Product ID"}
+
+	return []openapi.Operation{
+		{
+			Method:  http.MethodGet,
+			Path:    "/products",
+			Summary: "List products",
+			Tags:    []string{"products"},
+			Params: []openapi.Param{
+				{Name: "category", Query: true, Description: "Slug of a category; lists the products in it and its subcategories"},
+				{Name: "tag", Query: true, Description: "Lists the products with the tag; repeat it to require several"},
+			},
+			Response:   []types.Product{},
+			MediaTypes: netjson.MediaTypes(),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/products/{productID}",
+			Summary:  "Get a product with its options, variants, categories and tags",
+			Tags:     []string{"products"},
+			Params:   []openapi.Param{productID},
+			Response: types.ProductDetail{},
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/products/{productID}/variants",
+			Summary:  "Add a variant to a product (admins only)",
+			Tags:     []string{"products"},
+			Auth:     true,
+			Params:   []openapi.Param{productID},
+			Request:  types.CreateVariantPayload{},
+			Response: types.Variant{},
+			Status:   http.StatusCreated,
+		},
+		{
+			Method:   http.MethodPut,
+			Path:     "/products/{productID}/variants/{variantID}",
+			Summary:  "Update the SKU, price, stock and image of a variant (admins only)",
+			Tags:     []string{"products"},
+			Auth:     true,
+			Params:   []openapi.Param{productID, {Name: "variantID", Type: "integer", Description: "Variant ID"}},
+			Request:  types.UpdateVariantPayload{},
+			Response: types.Variant{},
+		},
+	}
+}
+
+// handleGetProducts streams the products matching the query in the format
+// negotiated from the Accept header
+func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
+	filter, err := h.parseFilter(r)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.WriteList(w, r, http.StatusOK, func(yield func(types.Product) error) error {
+		return h.store.StreamProducts(r.Context(), filter, yield)
+	})
+}
+
+// parseFilter reads the products to list from the query of r: those in a
+// category, including its subcategories, and with every tag.
+func (h *Handler) parseFilter(r *http.Request) (types.ProductFilter, error) {
+	var filter types.ProductFilter
+	q := r.URL.Query()
+
+	if slug := q.Get("category"); slug != "" {
+		categories, err := h.categoryStore.ListCategories(r.Context())
+		if err != nil {
+			return filter, err
+		}
+		c, ok := category.FindBySlug(categories, slug)
+		if !ok {
+			return filter, types.Errorf(types.ErrNotFound, "category %s not found", slug)
+		}
+		filter.CategoryIDs = category.Descendants(categories, c.ID)
+	}
+
+	for _, tag := range q["tag"] {
+		if name := category.NormalizeTag(tag); name != "" {
+			filter.Tags = append(filter.Tags, name)
+		}
+	}
+	return filter, nil
+}
+
+// handleGetProduct returns a product with its option matrix, variants,
+// categories and tags
+func (h *Handler) handleGetProduct(w http.ResponseWriter, r *http.Request) {
+	productID, err := pathID(r, "productID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	ps, err := h.store.GetProductsByID(r.Context(), []int{productID})
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	if len(ps) == 0 {
+		netjson.WriteError(w, r, types.Errorf(types.ErrNotFound, "product %d not found", productID))
+		return
+	}
+
+	options, err := h.variantStore.GetProductOptions(r.Context(), productID)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	variants, err := h.variantStore.GetVariantsByProduct(r.Context(), []int{productID})
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	categories, err := h.categoryStore.GetProductCategories(r.Context(), productID)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	tags, err := h.categoryStore.GetProductTags(r.Context(), productID)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.Write(w, http.StatusOK, types.ProductDetail{Product: ps[0], Options: options, Variants: variants, Categories: categories, Tags: tags})
 }
 
-// handleGetProducts gets products
-func (h *Handler) handleGetProducts(w http.ResponseWriter, _ *http.Request) {
-	ps, err := h.store.GetProducts()
+func (h *Handler) handleCreateVariant(w http.ResponseWriter, r *http.Request) {
+	productID, err := pathID(r, "productID")
 	if err != nil {
-		netjson.WriteError(w, http.StatusInternalServerError, err)
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	var payload types.CreateVariantPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
 		return
 	}
-	netjson.Write(w, http.StatusOK, ps)
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	id, err := h.variantStore.CreateVariant(r.Context(), types.Variant{
+		ProductID: productID,
+		SKU:       payload.SKU,
+		Price:     payload.Price,
+		Quantity:  payload.Quantity,
+		Image:     payload.Image,
+		Options:   payload.Options,
+	})
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	v, err := h.getVariant(r, productID, id)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	netjson.Write(w, http.StatusCreated, v)
+}
+
+func (h *Handler) handleUpdateVariant(w http.ResponseWriter, r *http.Request) {
+	productID, err := pathID(r, "productID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	variantID, err := pathID(r, "variantID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	var payload types.UpdateVariantPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	v, err := h.getVariant(r, productID, variantID)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	v.SKU = payload.SKU
+	v.Price = payload.Price
+	v.Quantity = payload.Quantity
+	v.Image = payload.Image
+	if err := h.variantStore.UpdateVariant(r.Context(), v); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	v, err = h.getVariant(r, productID, variantID)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	netjson.Write(w, http.StatusOK, v)
+}
+
+// getVariant gets a variant of a product, failing with ErrNotFound if the
+// product has no such variant.
+func (h *Handler) getVariant(r *http.Request, productID, variantID int) (types.Variant, error) {
+	variants, err := h.variantStore.GetVariantsByProduct(r.Context(), []int{productID})
+	if err != nil {
+		return types.Variant{}, err
+	}
+	for _, v := range variants {
+		if v.ID == variantID {
+			return v, nil
+		}
+	}
+	return types.Variant{}, types.Errorf(types.ErrNotFound, "variant %d of product %d not found", variantID, productID)
+}
+
+// pathID parses an integer path variable.
+func pathID(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		return 0, types.Errorf(types.ErrBadRequest, "invalid %s", name)
+	}
+	return id, nil
 }
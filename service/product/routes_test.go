@@ -1,18 +1,38 @@
 package product
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/davidado/go-api-reference/memstore"
 	"github.com/davidado/go-api-reference/types"
 	"github.com/gorilla/mux"
 )
 
 func TestProductServiceHandlers(t *testing.T) {
-	productStore := &mockProductStore{}
-	// userStore := &mockUserStore{}
-	handler := NewHandler(productStore)
+	productStore := memstore.New()
+	handler := NewHandler(productStore, productStore, productStore, productStore)
+
+	ctx := context.Background()
+	for _, p := range []types.Product{
+		{Name: "Mug", Price: 9.5, Quantity: 3},
+		{Name: "Tee, large", Price: 20, Quantity: 1},
+	} {
+		if _, err := productStore.CreateProduct(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	products, err := productStore.GetProducts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	t.Run("should handle get products", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, "/products", nil)
@@ -31,18 +51,213 @@ func TestProductServiceHandlers(t *testing.T) {
 			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
 		}
 	})
-}
 
-type mockProductStore struct{}
+	t.Run("should stream products as CSV", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/products", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "text/csv")
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
 
-func (m *mockProductStore) GetProducts() ([]types.Product, error) {
-	return []types.Product{}, nil
-}
+		router.HandleFunc("/products", handler.handleGetProducts).Methods(http.MethodGet)
 
-func (m *mockProductStore) UpdateProduct(_ types.Product) error {
-	return nil
-}
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+
+		want := "id,name,description,image,price,quantity,available,weight,length,width,height,createdAt\n" +
+			"1,Mug,,,9.5,3,3,0,0,0,0," + products[0].CreatedAt.Format(time.RFC3339) + "\n" +
+			"2,\"Tee, large\",,,20,1,1,0,0,0,0," + products[1].CreatedAt.Format(time.RFC3339) + "\n"
+		if rr.Body.String() != want {
+			t.Errorf("expected body %q, got %q", want, rr.Body.String())
+		}
+	})
+
+	t.Run("should stream products as NDJSON", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/products", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "application/x-ndjson, application/json;q=0.5")
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/products", handler.handleGetProducts).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if lines := strings.Count(rr.Body.String(), "\n"); lines != 2 {
+			t.Errorf("expected 2 lines, got %d", lines)
+		}
+	})
+
+	t.Run("should fail if no accepted format is supported", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/products", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "application/xml")
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/products", handler.handleGetProducts).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotAcceptable {
+			t.Errorf("expected status code %d, got %d", http.StatusNotAcceptable, rr.Code)
+		}
+	})
+
+	t.Run("should get a product with its options and variants", func(t *testing.T) {
+		router := mux.NewRouter()
+		router.HandleFunc("/products/{productID}", handler.handleGetProduct).Methods(http.MethodGet)
+		router.HandleFunc("/products/{productID}/variants", handler.handleCreateVariant).Methods(http.MethodPost)
 
-func (m *mockProductStore) GetProductsByID(_ []int) ([]types.Product, error) {
-	return []types.Product{}, nil
+		teeID := products[1].ID
+		for _, body := range []string{
+			`{"sku":"TEE-L-RED","quantity":2,"options":[{"name":"size","value":"L"},{"name":"color","value":"red"}]}`,
+			`{"sku":"TEE-L-BLUE","price":22,"quantity":1,"image":"blue.jpg","options":[{"name":"size","value":"L"},{"name":"color","value":"blue"}]}`,
+		} {
+			req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/products/%d/variants", teeID), strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+			}
+		}
+
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/products/%d", teeID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var detail types.ProductDetail
+		if err := json.NewDecoder(rr.Body).Decode(&detail); err != nil {
+			t.Fatal(err)
+		}
+		if detail.ID != teeID || detail.Name != "Tee, large" {
+			t.Errorf("unexpected product %+v", detail.Product)
+		}
+		wantOptions := []types.ProductOption{{Name: "size", Values: []string{"L"}}, {Name: "color", Values: []string{"red", "blue"}}}
+		if !reflect.DeepEqual(detail.Options, wantOptions) {
+			t.Errorf("expected options %+v, got %+v", wantOptions, detail.Options)
+		}
+		if len(detail.Variants) != 2 || detail.Variants[1].SKU != "TEE-L-BLUE" || detail.Variants[1].PriceOf(detail.Product) != 22 || detail.Variants[0].PriceOf(detail.Product) != 20 {
+			t.Errorf("unexpected variants %+v", detail.Variants)
+		}
+	})
+
+	t.Run("should refuse a variant with the same options as another", func(t *testing.T) {
+		router := mux.NewRouter()
+		router.HandleFunc("/products/{productID}/variants", handler.handleCreateVariant).Methods(http.MethodPost)
+
+		body := `{"sku":"TEE-L-RED-2","options":[{"name":"color","value":"red"},{"name":"size","value":"L"}]}`
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/products/%d/variants", products[1].ID), strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("should return not found for an unknown product", func(t *testing.T) {
+		router := mux.NewRouter()
+		router.HandleFunc("/products/{productID}", handler.handleGetProduct).Methods(http.MethodGet)
+
+		req, err := http.NewRequest(http.MethodGet, "/products/42", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should filter products by category, including subcategories, and tags", func(t *testing.T) {
+		kitchenID, err := productStore.CreateCategory(ctx, types.Category{Name: "Kitchen", Slug: "kitchen"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mugsID, err := productStore.CreateCategory(ctx, types.Category{ParentID: &kitchenID, Name: "Mugs", Slug: "mugs"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := productStore.SetProductCategories(ctx, products[0].ID, []int{mugsID}); err != nil {
+			t.Fatal(err)
+		}
+		if err := productStore.SetProductTags(ctx, products[1].ID, []string{"sale"}); err != nil {
+			t.Fatal(err)
+		}
+
+		router := mux.NewRouter()
+		router.HandleFunc("/products", handler.handleGetProducts).Methods(http.MethodGet)
+
+		tests := []struct {
+			query string
+			code  int
+			want  []string
+		}{
+			{"?category=kitchen", http.StatusOK, []string{"Mug"}},
+			{"?category=mugs", http.StatusOK, []string{"Mug"}},
+			{"?tag=Sale", http.StatusOK, []string{"Tee, large"}},
+			{"?category=kitchen&tag=sale", http.StatusOK, []string{}},
+			{"?category=garden", http.StatusNotFound, nil},
+		}
+		for _, tt := range tests {
+			req, err := http.NewRequest(http.MethodGet, "/products"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != tt.code {
+				t.Errorf("%s: expected status code %d, got %d", tt.query, tt.code, rr.Code)
+				continue
+			}
+			if tt.code != http.StatusOK {
+				continue
+			}
+
+			var got []types.Product
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, p := range got {
+				names = append(names, p.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.query, tt.want, names)
+			}
+		}
+	})
 }
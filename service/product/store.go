@@ -1,29 +1,46 @@
 package product
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/service/inventory"
 	"github.com/davidado/go-api-reference/types"
 )
 
+// productsTable lists the columns scanRowsIntoProduct reads, in order,
+// before the available stock.
+var productsTable = db.Table{
+	Name:    "products",
+	Columns: []string{"id", "name", "description", "image", "price", "quantity", "weight", "length", "width", "height", "created_at"},
+}
+
+// Tables : Tables and columns the store expects the migrations to create
+func Tables() []db.Table {
+	return []db.Table{productsTable, optionsTable, optionValuesTable, variantsTable, variantValuesTable}
+}
+
 // Store : Product store
 type Store struct {
-	db *sql.DB
+	db *db.DB
 }
 
 // NewStore : Create a new product store
-func NewStore(db *sql.DB) *Store {
+func NewStore(db *db.DB) *Store {
 	return &Store{db: db}
 }
 
 // GetProducts : Get all products
-func (s *Store) GetProducts() ([]types.Product, error) {
-	rows, err := s.db.Query("SELECT * FROM products")
+func (s *Store) GetProducts(ctx context.Context) ([]types.Product, error) {
+	rows, err := s.db.Read(ctx).QueryContext(ctx, selectProducts("ORDER BY id"))
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	products := make([]types.Product, 0)
 	for rows.Next() {
@@ -34,13 +51,73 @@ func (s *Store) GetProducts() ([]types.Product, error) {
 		products = append(products, *p)
 	}
 
+	return products, rows.Err()
+}
+
+// StreamProducts : Call fn for every product the filter matches without
+// loading them all
+func (s *Store) StreamProducts(ctx context.Context, filter types.ProductFilter, fn func(types.Product) error) error {
+	where, args := filterClause(filter)
+	rows, err := s.db.Read(ctx).QueryContext(ctx, selectProducts(where+"ORDER BY id"), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanRowsIntoProduct(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*p); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// lookupChunkSize caps the IDs a lookup sends in one query, well under
+// the 999 parameters older SQLite builds allow.
+const lookupChunkSize = 500
+
+// GetProductsByID : Get products by ID, sorted by ID, skipping IDs that don't
+// exist. Duplicate IDs are looked up once and long lists in chunks. Reads
+// that a write depends on, like checkout's, must use a context marked with
+// db.WithPrimary.
+func (s *Store) GetProductsByID(ctx context.Context, productIDs []int) ([]types.Product, error) {
+	ids := uniqueIDs(productIDs)
+
+	products := []types.Product{}
+	for len(ids) > 0 {
+		chunk := ids[:min(len(ids), lookupChunkSize)]
+		ids = ids[len(chunk):]
+
+		ps, err := s.getProductsByID(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, ps...)
+	}
+
 	return products, nil
 }
 
-// GetProductsByID : Get products by ID
-func (s *Store) GetProductsByID(productIDs []int) ([]types.Product, error) {
+// LookupProducts : Get products by ID like GetProductsByID, and report the
+// IDs that don't exist
+func (s *Store) LookupProducts(ctx context.Context, productIDs []int) (types.ProductLookup, error) {
+	products, err := s.GetProductsByID(ctx, productIDs)
+	if err != nil {
+		return types.ProductLookup{}, err
+	}
+
+	return types.NewProductLookup(productIDs, products), nil
+}
+
+// getProductsByID gets the products of one chunk of unique IDs.
+func (s *Store) getProductsByID(ctx context.Context, productIDs []int) ([]types.Product, error) {
 	placeholders := strings.Repeat(",?", len(productIDs)-1)
-	query := fmt.Sprintf("SELECT * FROM products WHERE id IN (?%s)", placeholders)
+	query := selectProducts(fmt.Sprintf("WHERE id IN (?%s) ORDER BY id", placeholders))
 
 	// Convert productIDs to []interface{}
 	args := make([]interface{}, len(productIDs))
@@ -48,10 +125,11 @@ func (s *Store) GetProductsByID(productIDs []int) ([]types.Product, error) {
 		args[i] = v
 	}
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.Read(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	products := []types.Product{}
 	for rows.Next() {
@@ -62,19 +140,57 @@ func (s *Store) GetProductsByID(productIDs []int) ([]types.Product, error) {
 		products = append(products, *p)
 	}
 
-	return products, nil
+	return products, rows.Err()
+}
+
+// CreateProduct : Create a new product
+func (s *Store) CreateProduct(ctx context.Context, product types.Product) (int, error) {
+	return s.db.InsertID(ctx, "INSERT INTO products (name, description, image, price, quantity, weight, length, width, height) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", product.Name, product.Description, product.Image, product.Price, product.Quantity, product.Weight, product.Length, product.Width, product.Height)
 }
 
 // UpdateProduct : Update a product
-func (s *Store) UpdateProduct(product types.Product) error {
-	_, err := s.db.Exec("UPDATE products SET name = ?, description = ?, image = ?, price = ?, quantity = ? WHERE id = ?", product.Name, product.Description, product.Image, product.Price, product.Quantity, product.ID)
+func (s *Store) UpdateProduct(ctx context.Context, product types.Product) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE products SET name = ?, description = ?, image = ?, price = ?, quantity = ?, weight = ?, length = ?, width = ?, height = ? WHERE id = ?", product.Name, product.Description, product.Image, product.Price, product.Quantity, product.Weight, product.Length, product.Width, product.Height, product.ID)
 	return err
 
 }
 
+// uniqueIDs returns a sorted copy of ids without duplicates.
+func uniqueIDs(ids []int) []int {
+	unique := slices.Clone(ids)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
+
+// filterClause returns the WHERE clause, if any, of the products filter
+// matches and its arguments.
+func filterClause(filter types.ProductFilter) (string, []any) {
+	var conds []string
+	var args []any
+	if len(filter.CategoryIDs) > 0 {
+		conds = append(conds, fmt.Sprintf("id IN (SELECT product_id FROM product_categories WHERE category_id IN (?%s))", strings.Repeat(",?", len(filter.CategoryIDs)-1)))
+		for _, id := range filter.CategoryIDs {
+			args = append(args, id)
+		}
+	}
+	for _, tag := range filter.Tags {
+		conds = append(conds, "id IN (SELECT pt.product_id FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE t.name = ?)")
+		args = append(args, tag)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND ") + " ", args
+}
+
+// selectProducts selects the products' columns and available stock.
+func selectProducts(clause string) string {
+	return productsTable.SelectWith(clause, inventory.AvailableStock)
+}
+
 func scanRowsIntoProduct(rows *sql.Rows) (*types.Product, error) {
 	p := &types.Product{}
-	err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Image, &p.Price, &p.Quantity, &p.CreatedAt)
+	err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Image, &p.Price, &p.Quantity, &p.Weight, &p.Length, &p.Width, &p.Height, &p.CreatedAt, &p.Available)
 	if err != nil {
 		return &types.Product{}, err
 	}
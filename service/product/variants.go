@@ -0,0 +1,328 @@
+package product
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/service/inventory"
+	"github.com/davidado/go-api-reference/types"
+)
+
+var (
+	// optionsTable and optionValuesTable hold the option matrix of the
+	// products sold in variants.
+	optionsTable = db.Table{
+		Name:    "product_options",
+		Columns: []string{"id", "product_id", "name", "sort_order"},
+	}
+	optionValuesTable = db.Table{
+		Name:    "product_option_values",
+		Columns: []string{"id", "option_id", "value", "sort_order"},
+	}
+
+	// variantsTable lists the columns scanRowIntoVariant reads, in order,
+	// before the available stock.
+	variantsTable = db.Table{
+		Name:    "product_variants",
+		Columns: []string{"id", "product_id", "sku", "price", "quantity", "image", "created_at"},
+	}
+
+	// variantValuesTable links every variant to a value of each of its
+	// product's options.
+	variantValuesTable = db.Table{
+		Name:    "product_variant_values",
+		Columns: []string{"variant_id", "option_value_id"},
+	}
+)
+
+// option : Option of a product, as stored
+type option struct {
+	id   int
+	name string
+}
+
+// CreateVariant : Add a variant to a product, creating the options and
+// values it names
+func (s *Store) CreateVariant(ctx context.Context, v types.Variant) (int, error) {
+	names := make([]string, len(v.Options))
+	for i, o := range v.Options {
+		names[i] = o.Name
+	}
+	if len(uniqueNames(names)) != len(names) {
+		return 0, types.Errorf(types.ErrValidation, "a variant has one value of each option")
+	}
+
+	var id int
+	err := s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		// Lock the product, so concurrent variants agree on its options.
+		if _, err := tx.ExecContext(ctx, "UPDATE products SET quantity = quantity WHERE id = ?", v.ProductID); err != nil {
+			return err
+		}
+		var productID int
+		err := tx.QueryRowContext(ctx, "SELECT id FROM products WHERE id = ?", v.ProductID).Scan(&productID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Errorf(types.ErrNotFound, "product %d not found", v.ProductID)
+		}
+		if err != nil {
+			return err
+		}
+
+		options, err := createOptions(ctx, tx, v.ProductID, names)
+		if err != nil {
+			return err
+		}
+
+		valueIDs := make([]any, len(v.Options))
+		for i, o := range v.Options {
+			valueIDs[i], err = createOptionValue(ctx, tx, options[o.Name], o.Value)
+			if err != nil {
+				return err
+			}
+		}
+
+		// A variant with the same values has all of them.
+		var existing int
+		err = tx.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT variant_id FROM product_variant_values WHERE option_value_id IN (?%s) GROUP BY variant_id HAVING COUNT(*) = ?", strings.Repeat(",?", len(valueIDs)-1)),
+			append(valueIDs, len(valueIDs))...).Scan(&existing)
+		if err == nil {
+			return types.Errorf(types.ErrConflict, "variant %d of product %d already has these options", existing, v.ProductID)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		id, err = tx.InsertID(ctx, "INSERT INTO product_variants (product_id, sku, price, quantity, image) VALUES (?, ?, ?, ?, ?)",
+			v.ProductID, v.SKU, price(v), v.Quantity, v.Image)
+		if err != nil {
+			if s.db.Dialect.IsUniqueViolation(err) {
+				return types.Errorf(types.ErrConflict, "SKU %s already exists", v.SKU)
+			}
+			return err
+		}
+
+		for _, valueID := range valueIDs {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO product_variant_values (variant_id, option_value_id) VALUES (?, ?)", id, valueID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+// createOptions creates the options of a product's first variant, or checks
+// that a later variant names the same ones. It returns the options' IDs by
+// name.
+func createOptions(ctx context.Context, tx *db.Tx, productID int, names []string) (map[string]int, error) {
+	options, err := getOptions(ctx, tx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]int, len(names))
+	if len(options) == 0 {
+		for i, name := range names {
+			id, err := tx.InsertID(ctx, "INSERT INTO product_options (product_id, name, sort_order) VALUES (?, ?, ?)", productID, name, i)
+			if err != nil {
+				return nil, err
+			}
+			ids[name] = id
+		}
+		return ids, nil
+	}
+
+	existing := make([]string, len(options))
+	for i, o := range options {
+		existing[i] = o.name
+		ids[o.name] = o.id
+	}
+	// The names are unique, so they're the same as the options' when
+	// there are as many and each is one of them.
+	if len(options) != len(names) || slices.ContainsFunc(names, func(n string) bool { return ids[n] == 0 }) {
+		return nil, types.Errorf(types.ErrValidation, "the variants of product %d have the options %s", productID, strings.Join(existing, ", "))
+	}
+	return ids, nil
+}
+
+// createOptionValue returns the ID of a value of an option, creating it
+// after the option's other values if it's new.
+func createOptionValue(ctx context.Context, tx *db.Tx, optionID int, value string) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM product_option_values WHERE option_id = ? AND value = ?", optionID, value).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	var position int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM product_option_values WHERE option_id = ?", optionID).Scan(&position); err != nil {
+		return 0, err
+	}
+	return tx.InsertID(ctx, "INSERT INTO product_option_values (option_id, value, sort_order) VALUES (?, ?, ?)", optionID, value, position)
+}
+
+func getOptions(ctx context.Context, tx *db.Tx, productID int) ([]option, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, name FROM product_options WHERE product_id = ? ORDER BY sort_order", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var options []option
+	for rows.Next() {
+		var o option
+		if err := rows.Scan(&o.id, &o.name); err != nil {
+			return nil, err
+		}
+		options = append(options, o)
+	}
+	return options, rows.Err()
+}
+
+// UpdateVariant : Update the SKU, price, stock and image of a variant. Like
+// UpdateProduct, unknown IDs are a no-op.
+func (s *Store) UpdateVariant(ctx context.Context, v types.Variant) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE product_variants SET sku = ?, price = ?, quantity = ?, image = ? WHERE id = ?", v.SKU, price(v), v.Quantity, v.Image, v.ID)
+	if err != nil && s.db.Dialect.IsUniqueViolation(err) {
+		return types.Errorf(types.ErrConflict, "SKU %s already exists", v.SKU)
+	}
+	return err
+}
+
+// GetVariantsByProduct : Get the variants of products with their options,
+// sorted by ID. Duplicate IDs are looked up once and long lists in chunks,
+// like GetProductsByID.
+func (s *Store) GetVariantsByProduct(ctx context.Context, productIDs []int) ([]types.Variant, error) {
+	ids := uniqueIDs(productIDs)
+
+	variants := []types.Variant{}
+	for len(ids) > 0 {
+		chunk := ids[:min(len(ids), lookupChunkSize)]
+		ids = ids[len(chunk):]
+
+		vs, err := s.getVariantsByProduct(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, vs...)
+	}
+
+	slices.SortFunc(variants, func(a, b types.Variant) int { return cmp.Compare(a.ID, b.ID) })
+	return variants, nil
+}
+
+// getVariantsByProduct gets the variants of one chunk of unique product IDs.
+func (s *Store) getVariantsByProduct(ctx context.Context, productIDs []int) ([]types.Variant, error) {
+	in := "?" + strings.Repeat(",?", len(productIDs)-1)
+	args := make([]any, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.Read(ctx).QueryContext(ctx, variantsTable.SelectWith("WHERE product_id IN ("+in+") ORDER BY id", inventory.VariantAvailableStock), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []types.Variant{}
+	index := map[int]int{}
+	for rows.Next() {
+		v, err := scanRowIntoVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		v.Options = []types.VariantOption{}
+		index[v.ID] = len(variants)
+		variants = append(variants, *v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	optionRows, err := s.db.Read(ctx).QueryContext(ctx, `SELECT vv.variant_id, o.name, ov.value FROM product_variant_values vv
+		JOIN product_option_values ov ON ov.id = vv.option_value_id
+		JOIN product_options o ON o.id = ov.option_id
+		WHERE o.product_id IN (`+in+`) ORDER BY vv.variant_id, o.sort_order`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer optionRows.Close()
+
+	for optionRows.Next() {
+		var variantID int
+		var o types.VariantOption
+		if err := optionRows.Scan(&variantID, &o.Name, &o.Value); err != nil {
+			return nil, err
+		}
+		if i, ok := index[variantID]; ok {
+			variants[i].Options = append(variants[i].Options, o)
+		}
+	}
+
+	return variants, optionRows.Err()
+}
+
+// GetProductOptions : Get the options of a product and their values
+func (s *Store) GetProductOptions(ctx context.Context, productID int) ([]types.ProductOption, error) {
+	rows, err := s.db.Read(ctx).QueryContext(ctx, `SELECT o.name, ov.value FROM product_options o
+		JOIN product_option_values ov ON ov.option_id = o.id
+		WHERE o.product_id = ? ORDER BY o.sort_order, ov.sort_order`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []types.ProductOption{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		if len(options) == 0 || options[len(options)-1].Name != name {
+			options = append(options, types.ProductOption{Name: name, Values: []string{}})
+		}
+		last := &options[len(options)-1]
+		last.Values = append(last.Values, value)
+	}
+
+	return options, rows.Err()
+}
+
+// uniqueNames returns a sorted copy of names without duplicates.
+func uniqueNames(names []string) []string {
+	unique := slices.Clone(names)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
+
+// price stores variants sold at their product's price with a NULL price.
+func price(v types.Variant) any {
+	if v.Price == nil {
+		return nil
+	}
+	return *v.Price
+}
+
+func scanRowIntoVariant(rows *sql.Rows) (*types.Variant, error) {
+	v := &types.Variant{}
+	var price sql.NullFloat64
+	err := rows.Scan(&v.ID, &v.ProductID, &v.SKU, &price, &v.Quantity, &v.Image, &v.CreatedAt, &v.Available)
+	if err != nil {
+		return nil, err
+	}
+	if price.Valid {
+		v.Price = &price.Float64
+	}
+	return v, nil
+}
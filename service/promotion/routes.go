@@ -0,0 +1,116 @@
+package promotion
+
+import (
+	"net/http"
+
+	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/types"
+	vd "github.com/davidado/go-api-reference/validator"
+	"github.com/gorilla/mux"
+)
+
+// Handler : Promotion handler
+type Handler struct {
+	store     types.PromotionStore
+	userStore types.UserStore
+}
+
+// NewHandler creates a new promotion handler
+func NewHandler(store types.PromotionStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+// RegisterRoutes registers promotion routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// admin routes
+	router.HandleFunc("/promotions", auth.WithAdminAuth(h.handleGetPromotions, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/promotions", auth.WithAdminAuth(h.handleCreatePromotion, h.userStore)).Methods(http.MethodPost)
+}
+
+// Operations describes the promotion routes for the OpenAPI document
+func (h *Handler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{
+			Method:     http.MethodGet,
+			Path:       "/promotions",
+			Summary:    "List promotions (admins only)",
+			Tags:       []string{"promotions"},
+			Auth:       true,
+			Response:   []types.Promotion{},
+			MediaTypes: netjson.MediaTypes(),
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/promotions",
+			Summary:  "Create a coupon code or an automatic promotion (admins only)",
+			Tags:     []string{"promotions"},
+			Auth:     true,
+			Request:  types.CreatePromotionPayload{},
+			Response: types.Promotion{},
+			Status:   http.StatusCreated,
+		},
+	}
+}
+
+func (h *Handler) handleGetPromotions(w http.ResponseWriter, r *http.Request) {
+	promotions, err := h.store.ListPromotions(r.Context())
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.Respond(w, r, http.StatusOK, promotions)
+}
+
+func (h *Handler) handleCreatePromotion(w http.ResponseWriter, r *http.Request) {
+	var payload types.CreatePromotionPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if payload.Kind == types.PromotionPercent && payload.Value > 100 {
+		netjson.WriteError(w, r, types.Errorf(types.ErrValidation, "a percentage can't be over 100"))
+		return
+	}
+	if payload.StartsAt != nil && payload.EndsAt != nil && !payload.EndsAt.After(*payload.StartsAt) {
+		netjson.WriteError(w, r, types.Errorf(types.ErrValidation, "endsAt must be after startsAt"))
+		return
+	}
+
+	p := types.Promotion{
+		Name:           payload.Name,
+		Code:           payload.Code,
+		Kind:           payload.Kind,
+		Value:          payload.Value,
+		MinSpend:       payload.MinSpend,
+		MaxUses:        payload.MaxUses,
+		MaxUsesPerUser: payload.MaxUsesPerUser,
+		Stackable:      payload.Stackable,
+		StartsAt:       payload.StartsAt,
+		EndsAt:         payload.EndsAt,
+		ProductIDs:     payload.ProductIDs,
+		CategoryIDs:    payload.CategoryIDs,
+	}
+	id, err := h.store.CreatePromotion(r.Context(), p)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	p.ID = id
+	if p.ProductIDs == nil {
+		p.ProductIDs = []int{}
+	}
+	if p.CategoryIDs == nil {
+		p.CategoryIDs = []int{}
+	}
+	netjson.Write(w, http.StatusCreated, p)
+}
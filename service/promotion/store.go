@@ -0,0 +1,212 @@
+// Package promotion : Promotion (coupon) service
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// promotionsTable lists the columns scanRowIntoPromotion reads, in order.
+var promotionsTable = db.Table{
+	Name: "promotions",
+	Columns: []string{
+		"id", "name", "code", "kind", "value", "min_spend", "max_uses", "max_uses_per_user",
+		"uses", "stackable", "starts_at", "ends_at", "created_at",
+	},
+}
+
+// promotionProductsTable and promotionCategoriesTable list the columns of
+// the products and categories a promotion is limited to.
+var (
+	promotionProductsTable = db.Table{
+		Name:    "promotion_products",
+		Columns: []string{"promotion_id", "product_id"},
+	}
+	promotionCategoriesTable = db.Table{
+		Name:    "promotion_categories",
+		Columns: []string{"promotion_id", "category_id"},
+	}
+)
+
+// Tables : Tables and columns the store expects the migrations to create
+func Tables() []db.Table {
+	return []db.Table{promotionsTable, promotionProductsTable, promotionCategoriesTable}
+}
+
+// Store : Promotion store
+type Store struct {
+	db *db.DB
+}
+
+// NewStore creates a new promotion store
+func NewStore(db *db.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreatePromotion creates a promotion with the products and categories
+// it's limited to
+func (s *Store) CreatePromotion(ctx context.Context, p types.Promotion) (int, error) {
+	// Automatic promotions have no code; NULLs don't clash in the unique
+	// index.
+	var code any
+	if p.Code != "" {
+		code = p.Code
+	}
+
+	var id int
+	err := s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		var err error
+		id, err = tx.InsertID(ctx,
+			"INSERT INTO promotions (name, code, kind, value, min_spend, max_uses, max_uses_per_user, stackable, starts_at, ends_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			p.Name, code, p.Kind, p.Value, p.MinSpend, p.MaxUses, p.MaxUsesPerUser, p.Stackable, utc(p.StartsAt), utc(p.EndsAt))
+		if err != nil {
+			if s.db.Dialect.IsUniqueViolation(err) {
+				return types.Errorf(types.ErrConflict, "promotion code %s already exists", p.Code)
+			}
+			return err
+		}
+
+		for _, productID := range p.ProductIDs {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO promotion_products (promotion_id, product_id) VALUES (?, ?)", id, productID); err != nil {
+				return err
+			}
+		}
+		for _, categoryID := range p.CategoryIDs {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO promotion_categories (promotion_id, category_id) VALUES (?, ?)", id, categoryID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+// GetPromotionByCode gets a promotion by its coupon code
+func (s *Store) GetPromotionByCode(ctx context.Context, code string) (*types.Promotion, error) {
+	promotions, err := s.listPromotions(ctx, "WHERE code = ?", code)
+	if err != nil {
+		return nil, err
+	}
+	if len(promotions) == 0 {
+		return nil, types.Errorf(types.ErrNotFound, "promotion %s not found", code)
+	}
+	return &promotions[0], nil
+}
+
+// GetAutomaticPromotions gets the promotions without a code in ID order
+func (s *Store) GetAutomaticPromotions(ctx context.Context) ([]types.Promotion, error) {
+	return s.listPromotions(ctx, "WHERE code IS NULL")
+}
+
+// ListPromotions lists every promotion in ID order
+func (s *Store) ListPromotions(ctx context.Context) ([]types.Promotion, error) {
+	return s.listPromotions(ctx, "")
+}
+
+// CountUserRedemptions counts the orders of a user that used a promotion,
+// leaving out failed orders
+func (s *Store) CountUserRedemptions(ctx context.Context, promotionID, userID int) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM order_discounts d JOIN orders o ON o.id = d.order_id WHERE d.promotion_id = ? AND o.user_id = ? AND o.status <> ?",
+		promotionID, userID, types.OrderFailed).Scan(&n)
+	return n, err
+}
+
+// listPromotions lists the promotions matching where in ID order, and
+// loads the products and categories they're limited to.
+func (s *Store) listPromotions(ctx context.Context, where string, args ...any) ([]types.Promotion, error) {
+	rows, err := s.db.QueryContext(ctx, promotionsTable.Select(where+" ORDER BY id"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []types.Promotion{}
+	index := map[int]int{}
+	for rows.Next() {
+		p, err := scanRowIntoPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		p.ProductIDs = []int{}
+		p.CategoryIDs = []int{}
+		index[p.ID] = len(promotions)
+		promotions = append(promotions, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(promotions) == 0 {
+		return promotions, nil
+	}
+
+	err = s.loadLinks(ctx, promotionProductsTable, "product_id", where, args, func(promotionID, productID int) {
+		if i, ok := index[promotionID]; ok {
+			promotions[i].ProductIDs = append(promotions[i].ProductIDs, productID)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.loadLinks(ctx, promotionCategoriesTable, "category_id", where, args, func(promotionID, categoryID int) {
+		if i, ok := index[promotionID]; ok {
+			promotions[i].CategoryIDs = append(promotions[i].CategoryIDs, categoryID)
+		}
+	})
+	return promotions, err
+}
+
+// loadLinks calls fn for every row of a link table whose promotion matches
+// where, ordered by the linked column.
+func (s *Store) loadLinks(ctx context.Context, table db.Table, column, where string, args []any, fn func(promotionID, id int)) error {
+	rows, err := s.db.QueryContext(ctx, table.Select("WHERE promotion_id IN (SELECT id FROM promotions "+where+") ORDER BY "+column), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var promotionID, id int
+		if err := rows.Scan(&promotionID, &id); err != nil {
+			return err
+		}
+		fn(promotionID, id)
+	}
+
+	return rows.Err()
+}
+
+// utc stores times in UTC, so every backend reads back the same instant.
+func utc(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func scanRowIntoPromotion(rows *sql.Rows) (*types.Promotion, error) {
+	p := &types.Promotion{}
+	var code sql.NullString
+	var startsAt, endsAt sql.NullTime
+	err := rows.Scan(&p.ID, &p.Name, &code, &p.Kind, &p.Value, &p.MinSpend, &p.MaxUses, &p.MaxUsesPerUser,
+		&p.Uses, &p.Stackable, &startsAt, &endsAt, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Code = code.String
+	if startsAt.Valid {
+		p.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+	return p, nil
+}
@@ -0,0 +1,260 @@
+package returns
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/types"
+	vd "github.com/davidado/go-api-reference/validator"
+	"github.com/gorilla/mux"
+)
+
+// Handler : Return handler
+type Handler struct {
+	store        types.ReturnStore
+	orderStore   types.OrderStore
+	paymentStore types.PaymentStore
+	userStore    types.UserStore
+	payments     types.PaymentProvider
+}
+
+// NewHandler creates a new return handler. Approved returns are refunded
+// through payments.
+func NewHandler(store types.ReturnStore, orderStore types.OrderStore, paymentStore types.PaymentStore, userStore types.UserStore, payments types.PaymentProvider) *Handler {
+	return &Handler{
+		store:        store,
+		orderStore:   orderStore,
+		paymentStore: paymentStore,
+		userStore:    userStore,
+		payments:     payments,
+	}
+}
+
+// RegisterRoutes registers return routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/orders/{orderID}/returns", auth.WithJWTAuth(h.handleCreateReturn, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/returns", auth.WithJWTAuth(h.handleListReturns, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/returns/{returnID}", auth.WithJWTAuth(h.handleGetReturn, h.userStore)).Methods(http.MethodGet)
+
+	// admin routes
+	router.HandleFunc("/returns/{returnID}/approve", auth.WithAdminAuth(h.handleApproveReturn, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/returns/{returnID}/reject", auth.WithAdminAuth(h.handleRejectReturn, h.userStore)).Methods(http.MethodPost)
+}
+
+// Operations describes the return routes for the OpenAPI document
+func (h *Handler) Operations() []openapi.Operation {
+	returnID := []openapi.Param{{Name: "returnID", Type: "integer", Description: "Return ID"}}
+
+	return []openapi.Operation{
+		{
+			Method:   http.MethodPost,
+			Path:     "/orders/{orderID}/returns",
+			Summary:  "Request to return items of an order",
+			Tags:     []string{"returns"},
+			Auth:     true,
+			Params:   []openapi.Param{{Name: "orderID", Type: "integer", Description: "Order ID"}},
+			Request:  types.CreateReturnPayload{},
+			Response: types.Return{},
+			Status:   http.StatusCreated,
+		},
+		{
+			Method:     http.MethodGet,
+			Path:       "/returns",
+			Summary:    "List the returns of the logged in user, or every return for admins",
+			Tags:       []string{"returns"},
+			Auth:       true,
+			Response:   []types.Return{},
+			MediaTypes: netjson.MediaTypes(),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/returns/{returnID}",
+			Summary:  "Get a return with its audit trail",
+			Tags:     []string{"returns"},
+			Auth:     true,
+			Params:   returnID,
+			Response: types.Return{},
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/returns/{returnID}/approve",
+			Summary:  "Approve a return: refund, restock and update the order (admins only)",
+			Tags:     []string{"returns"},
+			Auth:     true,
+			Params:   returnID,
+			Request:  types.ReviewReturnPayload{},
+			Response: types.Return{},
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/returns/{returnID}/reject",
+			Summary:  "Reject a return (admins only)",
+			Tags:     []string{"returns"},
+			Auth:     true,
+			Params:   returnID,
+			Request:  types.ReviewReturnPayload{},
+			Response: types.Return{},
+		},
+	}
+}
+
+func (h *Handler) handleCreateReturn(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	orderID, err := pathID(r, "orderID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	var payload types.CreateReturnPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	// The quantity checks read the returns they're about to add to.
+	ctx := db.WithPrimary(r.Context())
+
+	id, err := h.createReturn(ctx, userID, orderID, payload)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	ret, err := h.store.GetReturn(ctx, id)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.Write(w, http.StatusCreated, ret)
+}
+
+func (h *Handler) handleListReturns(w http.ResponseWriter, r *http.Request) {
+	filter := types.ReturnFilter{Status: r.URL.Query().Get("status")}
+
+	admin, err := h.isAdmin(r)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	if !admin {
+		filter.UserID = auth.GetUserIDFromContext(r.Context())
+	}
+
+	returns, err := h.store.ListReturns(r.Context(), filter)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.Respond(w, r, http.StatusOK, returns)
+}
+
+func (h *Handler) handleGetReturn(w http.ResponseWriter, r *http.Request) {
+	ret, ok := h.getReturn(w, r)
+	if !ok {
+		return
+	}
+
+	admin, err := h.isAdmin(r)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+	if !admin && ret.UserID != auth.GetUserIDFromContext(r.Context()) {
+		netjson.WriteError(w, r, types.Errorf(types.ErrNotFound, "return %d not found", ret.ID))
+		return
+	}
+
+	netjson.Write(w, http.StatusOK, ret)
+}
+
+func (h *Handler) handleApproveReturn(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, h.approveReturn)
+}
+
+func (h *Handler) handleRejectReturn(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, h.rejectReturn)
+}
+
+// review runs an admin's decision on a return and responds with the
+// updated return.
+func (h *Handler) review(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, adminID int, ret *types.Return, note string) error) {
+	var payload types.ReviewReturnPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	// Decisions depend on the current status, stock and refunds.
+	r = r.WithContext(db.WithPrimary(r.Context()))
+
+	ret, ok := h.getReturn(w, r)
+	if !ok {
+		return
+	}
+
+	if err := decide(r.Context(), auth.GetUserIDFromContext(r.Context()), ret, payload.Note); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	ret, ok = h.getReturn(w, r)
+	if !ok {
+		return
+	}
+
+	netjson.Write(w, http.StatusOK, ret)
+}
+
+// getReturn gets the return of the returnID path variable. When it fails,
+// it writes the error response and returns false.
+func (h *Handler) getReturn(w http.ResponseWriter, r *http.Request) (*types.Return, bool) {
+	id, err := pathID(r, "returnID")
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return nil, false
+	}
+
+	ret, err := h.store.GetReturn(r.Context(), id)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return nil, false
+	}
+	return ret, true
+}
+
+// isAdmin reports whether the logged in user is an admin.
+func (h *Handler) isAdmin(r *http.Request) (bool, error) {
+	u, err := h.userStore.GetUserByID(r.Context(), auth.GetUserIDFromContext(r.Context()))
+	if err != nil {
+		return false, err
+	}
+	return auth.IsAdmin(u), nil
+}
+
+// pathID parses an integer path variable.
+func pathID(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		return 0, types.Errorf(types.ErrBadRequest, "invalid %s", name)
+	}
+	return id, nil
+}
@@ -0,0 +1,484 @@
+package returns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/davidado/go-api-reference/config"
+	"github.com/davidado/go-api-reference/memstore"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/service/payment"
+	"github.com/davidado/go-api-reference/types"
+	"github.com/gorilla/mux"
+)
+
+func TestReturns(t *testing.T) {
+	ctx := context.Background()
+
+	admins := config.Envs.AdminEmails
+	config.Envs.AdminEmails = []string{"Admin@example.com"}
+	t.Cleanup(func() { config.Envs.AdminEmails = admins })
+
+	// fixture is a paid order of two mugs at 5 and a plate at 10.
+	type fixture struct {
+		s        *memstore.Store
+		router   *mux.Router
+		payments *payment.FakeProvider
+
+		buyer, other, admin string
+		mugID, plateID      int
+		orderID             int
+		mugItem, plateItem  int
+		paymentID           int
+	}
+
+	newUser := func(t *testing.T, s *memstore.Store, email string) string {
+		t.Helper()
+
+		if err := s.CreateUser(ctx, types.User{FirstName: "A", LastName: "B", Email: email, Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.GetUserByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	setup := func(t *testing.T) *fixture {
+		t.Helper()
+
+		f := &fixture{s: memstore.New(), payments: payment.NewFakeProvider()}
+		f.buyer = newUser(t, f.s, "buyer@example.com")
+		f.other = newUser(t, f.s, "other@example.com")
+		f.admin = newUser(t, f.s, "admin@example.com")
+
+		buyer, err := f.s.GetUserByEmail(ctx, "buyer@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.mugID, err = f.s.CreateProduct(ctx, types.Product{Name: "mug", Price: 5, Quantity: 10}); err != nil {
+			t.Fatal(err)
+		}
+		if f.plateID, err = f.s.CreateProduct(ctx, types.Product{Name: "plate", Price: 10, Quantity: 5}); err != nil {
+			t.Fatal(err)
+		}
+		if f.orderID, err = f.s.CreateOrder(ctx, types.Order{UserID: buyer.ID, Total: 20, Status: types.OrderPaid, Address: "1 Main St"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, oi := range []types.OrderItem{
+			{OrderID: f.orderID, ProductID: f.mugID, Quantity: 2, Price: 5},
+			{OrderID: f.orderID, ProductID: f.plateID, Quantity: 1, Price: 10},
+		} {
+			if err := f.s.CreateOrderItem(ctx, oi); err != nil {
+				t.Fatal(err)
+			}
+		}
+		items, err := f.s.GetOrderItems(ctx, f.orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.mugItem, f.plateItem = items[0].ID, items[1].ID
+
+		res, err := f.payments.Authorize(ctx, types.PaymentRequest{Amount: 20, Method: payment.CardSuccess})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.payments.Capture(ctx, res.Reference); err != nil {
+			t.Fatal(err)
+		}
+		f.paymentID, err = f.s.CreatePayment(ctx, types.Payment{OrderID: f.orderID, Provider: payment.FakeName, Reference: res.Reference, Status: types.PaymentCaptured, Amount: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.router = mux.NewRouter()
+		NewHandler(f.s, f.s, f.s, f.s, f.payments).RegisterRoutes(f.router)
+		return f
+	}
+
+	do := func(t *testing.T, f *fixture, method, path, token string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+
+		var buf bytes.Buffer
+		if body != nil {
+			if err := json.NewEncoder(&buf).Encode(body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+
+		rr := httptest.NewRecorder()
+		f.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// request opens a return and fails the test unless it's created.
+	request := func(t *testing.T, f *fixture, items ...types.ReturnItemPayload) types.Return {
+		t.Helper()
+
+		rr := do(t, f, http.MethodPost, fmt.Sprintf("/orders/%d/returns", f.orderID), f.buyer, types.CreateReturnPayload{Items: items, Reason: "broken"})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		var r types.Return
+		if err := json.NewDecoder(rr.Body).Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	review := func(t *testing.T, f *fixture, id int, decision, token string) (*httptest.ResponseRecorder, types.Return) {
+		t.Helper()
+
+		rr := do(t, f, http.MethodPost, fmt.Sprintf("/returns/%d/%s", id, decision), token, types.ReviewReturnPayload{Note: "ok"})
+		var r types.Return
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr, r
+	}
+
+	stock := func(t *testing.T, f *fixture, id int) int {
+		t.Helper()
+
+		ps, err := f.s.GetProductsByID(ctx, []int{id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ps[0].Quantity
+	}
+
+	orderStatus := func(t *testing.T, f *fixture) string {
+		t.Helper()
+
+		o, err := f.s.GetOrderByID(ctx, f.orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o.Status
+	}
+
+	actions := func(r types.Return) []string {
+		var a []string
+		for _, e := range r.Events {
+			a = append(a, e.Action)
+		}
+		return a
+	}
+
+	t.Run("should open a return with an audit entry", func(t *testing.T) {
+		f := setup(t)
+
+		r := request(t, f, types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 1})
+		if r.Status != types.ReturnRequested || r.OrderID != f.orderID || len(r.Items) != 1 || r.Items[0].Quantity != 1 {
+			t.Errorf("unexpected return %+v", r)
+		}
+		if got := actions(r); len(got) != 1 || got[0] != types.ReturnEventRequested {
+			t.Errorf("expected a requested event, got %v", got)
+		}
+	})
+
+	t.Run("should refuse to return more than was ordered", func(t *testing.T) {
+		f := setup(t)
+		request(t, f, types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 1})
+
+		rr := do(t, f, http.MethodPost, fmt.Sprintf("/orders/%d/returns", f.orderID), f.buyer, types.CreateReturnPayload{
+			Items:  []types.ReturnItemPayload{{OrderItemID: f.mugItem, Quantity: 2}},
+			Reason: "broken",
+		})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body)
+		}
+	})
+
+	t.Run("should open one return when the same items are returned together", func(t *testing.T) {
+		f := setup(t)
+
+		codes := make(chan int, 2)
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rr := do(t, f, http.MethodPost, fmt.Sprintf("/orders/%d/returns", f.orderID), f.buyer, types.CreateReturnPayload{
+					Items:  []types.ReturnItemPayload{{OrderItemID: f.mugItem, Quantity: 2}},
+					Reason: "broken",
+				})
+				codes <- rr.Code
+			}()
+		}
+		wg.Wait()
+		close(codes)
+
+		var got []int
+		for code := range codes {
+			got = append(got, code)
+		}
+		slices.Sort(got)
+		if want := []int{http.StatusCreated, http.StatusBadRequest}; !slices.Equal(got, want) {
+			t.Errorf("expected status codes %v, got %v", want, got)
+		}
+		returns, err := f.s.ListReturns(ctx, types.ReturnFilter{OrderID: f.orderID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(returns) != 1 {
+			t.Errorf("expected one return, got %+v", returns)
+		}
+	})
+
+	t.Run("should hide other users' orders and returns", func(t *testing.T) {
+		f := setup(t)
+		r := request(t, f, types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 1})
+
+		rr := do(t, f, http.MethodPost, fmt.Sprintf("/orders/%d/returns", f.orderID), f.other, types.CreateReturnPayload{
+			Items:  []types.ReturnItemPayload{{OrderItemID: f.mugItem, Quantity: 1}},
+			Reason: "broken",
+		})
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusNotFound, rr.Code, rr.Body)
+		}
+
+		if rr := do(t, f, http.MethodGet, fmt.Sprintf("/returns/%d", r.ID), f.other, nil); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+		if rr := do(t, f, http.MethodGet, fmt.Sprintf("/returns/%d", r.ID), f.admin, nil); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d for an admin, got %d", http.StatusOK, rr.Code)
+		}
+
+		for token, want := range map[string]int{f.buyer: 1, f.other: 0, f.admin: 1} {
+			var returns []types.Return
+			if err := json.NewDecoder(do(t, f, http.MethodGet, "/returns", token, nil).Body).Decode(&returns); err != nil {
+				t.Fatal(err)
+			}
+			if len(returns) != want {
+				t.Errorf("expected %d returns, got %+v", want, returns)
+			}
+		}
+	})
+
+	t.Run("should let only admins review returns", func(t *testing.T) {
+		f := setup(t)
+		r := request(t, f, types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 1})
+
+		if rr, _ := review(t, f, r.ID, "approve", f.buyer); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+		if rr, _ := review(t, f, r.ID, "approve", ""); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should refund, restock and update the order on approval", func(t *testing.T) {
+		f := setup(t)
+		r := request(t, f, types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 1})
+
+		rr, approved := review(t, f, r.ID, "approve", f.admin)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if approved.Status != types.ReturnApproved || approved.Refund != 5 {
+			t.Errorf("unexpected return %+v", approved)
+		}
+		want := []string{types.ReturnEventRequested, types.ReturnEventApproved, types.ReturnEventRefunded, types.ReturnEventRestocked, types.ReturnEventOrder}
+		if got := actions(approved); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("expected events %v, got %v", want, got)
+		}
+		if got := stock(t, f, f.mugID); got != 11 {
+			t.Errorf("expected 11 mugs in stock, got %d", got)
+		}
+		if got := orderStatus(t, f); got != types.OrderPartiallyRefunded {
+			t.Errorf("expected the order to be partially refunded, got %s", got)
+		}
+
+		if rr, _ := review(t, f, r.ID, "approve", f.admin); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d approving twice, got %d", http.StatusConflict, rr.Code)
+		}
+
+		// Returning the rest refunds the whole order.
+		r = request(t, f,
+			types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 1},
+			types.ReturnItemPayload{OrderItemID: f.plateItem, Quantity: 1},
+		)
+		if rr, _ := review(t, f, r.ID, "approve", f.admin); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if got := orderStatus(t, f); got != types.OrderRefunded {
+			t.Errorf("expected the order to be refunded, got %s", got)
+		}
+		payments, err := f.s.GetPaymentsByOrder(ctx, f.orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payments[0].Refunded != 20 || payments[0].Status != types.PaymentRefunded {
+			t.Errorf("expected the payment to be refunded in full, got %+v", payments[0])
+		}
+	})
+
+	t.Run("should refund an order with shipping once its items are returned", func(t *testing.T) {
+		f := setup(t)
+		buyer, err := f.s.GetUserByEmail(ctx, "buyer@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		orderID, err := f.s.CreateOrder(ctx, types.Order{UserID: buyer.ID, Total: 9, Status: types.OrderPaid, Address: "1 Main St", ShippingMethod: "Standard", ShippingCost: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.s.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: f.mugID, Quantity: 1, Price: 5}); err != nil {
+			t.Fatal(err)
+		}
+		items, err := f.s.GetOrderItems(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := f.payments.Authorize(ctx, types.PaymentRequest{Amount: 9, Method: payment.CardSuccess})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.payments.Capture(ctx, res.Reference); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.s.CreatePayment(ctx, types.Payment{OrderID: orderID, Provider: payment.FakeName, Reference: res.Reference, Status: types.PaymentCaptured, Amount: 9}); err != nil {
+			t.Fatal(err)
+		}
+
+		rr := do(t, f, http.MethodPost, fmt.Sprintf("/orders/%d/returns", orderID), f.buyer, types.CreateReturnPayload{
+			Items:  []types.ReturnItemPayload{{OrderItemID: items[0].ID, Quantity: 1}},
+			Reason: "broken",
+		})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+		var r types.Return
+		if err := json.NewDecoder(rr.Body).Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+
+		rr, approved := review(t, f, r.ID, "approve", f.admin)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if approved.Refund != 5 {
+			t.Errorf("expected the mug's 5.00 refunded, got %.2f", approved.Refund)
+		}
+		o, err := f.s.GetOrderByID(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Status != types.OrderRefunded {
+			t.Errorf("expected the order to be refunded, got %s", o.Status)
+		}
+	})
+
+	t.Run("should refund a return once when admins approve it together", func(t *testing.T) {
+		f := setup(t)
+		r := request(t, f, types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 1})
+
+		codes := make(chan int, 2)
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rr := do(t, f, http.MethodPost, fmt.Sprintf("/returns/%d/approve", r.ID), f.admin, types.ReviewReturnPayload{Note: "ok"})
+				codes <- rr.Code
+			}()
+		}
+		wg.Wait()
+		close(codes)
+
+		var got []int
+		for code := range codes {
+			got = append(got, code)
+		}
+		slices.Sort(got)
+		if want := []int{http.StatusOK, http.StatusConflict}; !slices.Equal(got, want) {
+			t.Errorf("expected status codes %v, got %v", want, got)
+		}
+		if got := stock(t, f, f.mugID); got != 11 {
+			t.Errorf("expected 11 mugs in stock, got %d", got)
+		}
+		payments, err := f.s.GetPaymentsByOrder(ctx, f.orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payments[0].Refunded != 5 {
+			t.Errorf("expected 5.00 refunded, got %.2f", payments[0].Refunded)
+		}
+	})
+
+	t.Run("should keep a return requested when its refund fails", func(t *testing.T) {
+		f := setup(t)
+		r := request(t, f, types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 1})
+
+		// Refunded at the provider behind the store's back, so the
+		// refund of the return is refused.
+		payments, err := f.s.GetPaymentsByOrder(ctx, f.orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.payments.Refund(ctx, payments[0].Reference, 20); err != nil {
+			t.Fatal(err)
+		}
+
+		if rr, _ := review(t, f, r.ID, "approve", f.admin); rr.Code == http.StatusOK {
+			t.Fatal("expected the approval to fail")
+		}
+		got, err := f.s.GetReturn(ctx, r.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != types.ReturnRequested {
+			t.Errorf("expected the return to stay requested, got %s", got.Status)
+		}
+		payments, err = f.s.GetPaymentsByOrder(ctx, f.orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payments[0].Refunded != 0 {
+			t.Errorf("expected nothing refunded, got %.2f", payments[0].Refunded)
+		}
+		if got := stock(t, f, f.mugID); got != 10 {
+			t.Errorf("expected 10 mugs in stock, got %d", got)
+		}
+	})
+
+	t.Run("should close rejected returns without refunding", func(t *testing.T) {
+		f := setup(t)
+		r := request(t, f, types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 2})
+
+		rr, rejected := review(t, f, r.ID, "reject", f.admin)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+		if rejected.Status != types.ReturnRejected || rejected.Refund != 0 {
+			t.Errorf("unexpected return %+v", rejected)
+		}
+		if got := stock(t, f, f.mugID); got != 10 {
+			t.Errorf("expected 10 mugs in stock, got %d", got)
+		}
+		if got := orderStatus(t, f); got != types.OrderPaid {
+			t.Errorf("expected the order to stay paid, got %s", got)
+		}
+
+		// Rejected returns don't count against what's left to return.
+		request(t, f, types.ReturnItemPayload{OrderItemID: f.mugItem, Quantity: 2})
+	})
+}
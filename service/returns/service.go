@@ -0,0 +1,214 @@
+package returns
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// returnable lists the order statuses whose items can be sent back.
+var returnable = []string{types.OrderPaid, types.OrderPartiallyRefunded, types.OrderCompleted}
+
+// cents rounds an amount of money to cents.
+func cents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// createReturn opens a return of a user's order. Items can't be returned
+// more often than they were ordered, counting the requested and approved
+// returns of the order.
+func (h *Handler) createReturn(ctx context.Context, userID, orderID int, payload types.CreateReturnPayload) (int, error) {
+	order, err := h.orderStore.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return 0, err
+	}
+	// Other users' orders don't exist as far as the customer knows.
+	if order.UserID != userID {
+		return 0, types.Errorf(types.ErrNotFound, "order %d not found", orderID)
+	}
+	if !slices.Contains(returnable, order.Status) {
+		return 0, types.Errorf(types.ErrConflict, "order %d is %s and can't be returned", orderID, order.Status)
+	}
+
+	var items []types.ReturnItem
+	var detail []string
+	for _, p := range payload.Items {
+		items = append(items, types.ReturnItem{OrderItemID: p.OrderItemID, Quantity: p.Quantity})
+		detail = append(detail, fmt.Sprintf("order item %d x%d", p.OrderItemID, p.Quantity))
+	}
+
+	// The store checks the items against the order and its other returns
+	// as it creates the return.
+	id, err := h.store.CreateReturn(ctx, types.Return{
+		OrderID: orderID,
+		UserID:  userID,
+		Reason:  payload.Reason,
+		Items:   items,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := h.audit(ctx, id, userID, types.ReturnEventRequested, strings.Join(detail, ", ")+": "+payload.Reason); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// approveReturn refunds the returned items through the order's payment,
+// restocks them and updates the order's status. The return and its refund
+// are claimed before the refund, so two admins can't both refund it and
+// returns can't refund more than was paid, and given back when the refund
+// fails, so it can be approved again.
+func (h *Handler) approveReturn(ctx context.Context, adminID int, r *types.Return, note string) error {
+	if r.Status != types.ReturnRequested {
+		return types.Errorf(types.ErrConflict, "return %d is already %s", r.ID, r.Status)
+	}
+
+	order, err := h.orderStore.GetOrderByID(ctx, r.OrderID)
+	if err != nil {
+		return err
+	}
+	orderItems, err := h.orderStore.GetOrderItems(ctx, r.OrderID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]types.OrderItem, len(orderItems))
+	for _, oi := range orderItems {
+		byID[oi.ID] = oi
+	}
+
+	refund := 0.0
+	restock := make([]types.CartItem, len(r.Items))
+	for i, item := range r.Items {
+		oi := byID[item.OrderItemID]
+		refund += oi.Price * float64(item.Quantity)
+		restock[i] = types.CartItem{ProductID: oi.ProductID, VariantID: oi.VariantID, Quantity: item.Quantity}
+	}
+
+	// The order's discounts are spread over its items in proportion to
+	// their price.
+	discounts, err := h.orderStore.GetOrderDiscounts(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	if len(discounts) > 0 {
+		subtotal, discount := 0.0, 0.0
+		for _, oi := range orderItems {
+			subtotal += oi.Price * float64(oi.Quantity)
+		}
+		for _, d := range discounts {
+			discount += d.Amount
+		}
+		refund -= refund * discount / subtotal
+	}
+
+	// Tax added on top of the prices is refunded with its items.
+	if !order.TaxInclusive {
+		for _, item := range r.Items {
+			oi := byID[item.OrderItemID]
+			refund += oi.Tax * float64(item.Quantity) / float64(oi.Quantity)
+		}
+	}
+	refund = cents(refund)
+
+	payment, err := h.capturedPayment(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	// The claim checks the refund against what's left of the payment, so
+	// returns of the same order approved together can't refund more than
+	// was paid.
+	refunded, err := h.store.ClaimRefund(ctx, r.ID, payment.ID, refund)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.payments.Refund(ctx, payment.Reference, refund)
+	if err != nil {
+		if err := h.store.ReleaseRefund(ctx, r.ID, payment.ID, refund); err != nil {
+			log.Printf("reopen return %d: %v", r.ID, err)
+		}
+		return fmt.Errorf("refund payment %s: %w", payment.Reference, err)
+	}
+
+	// Shipping isn't refunded, so an order is refunded once its items are.
+	status := types.OrderPartiallyRefunded
+	if cents(refunded) >= cents(order.Total-order.ShippingCost) {
+		status = types.OrderRefunded
+	}
+
+	event := func(action, detail string) types.ReturnEvent {
+		return types.ReturnEvent{ReturnID: r.ID, ActorID: adminID, Action: action, Detail: detail}
+	}
+	events := []types.ReturnEvent{
+		event(types.ReturnEventApproved, note),
+		event(types.ReturnEventRefunded, fmt.Sprintf("%.2f on payment %s", refund, payment.Reference)),
+	}
+	for _, item := range restock {
+		detail := fmt.Sprintf("product %d +%d", item.ProductID, item.Quantity)
+		if item.VariantID != 0 {
+			detail = fmt.Sprintf("variant %d of product %d +%d", item.VariantID, item.ProductID, item.Quantity)
+		}
+		events = append(events, event(types.ReturnEventRestocked, detail))
+	}
+	events = append(events, event(types.ReturnEventOrder, fmt.Sprintf("order %d is %s", order.ID, status)))
+
+	// The money is back with the customer: record everything else at
+	// once, so a failure can't leave the return half done.
+	err = h.store.ApproveReturn(ctx, types.ReturnApproval{
+		ReturnID:      r.ID,
+		PaymentID:     payment.ID,
+		PaymentStatus: res.Status,
+		Restock:       restock,
+		OrderID:       order.ID,
+		OrderStatus:   status,
+		Events:        events,
+	})
+	if err != nil {
+		return fmt.Errorf("approve return %d refunded %.2f on payment %s: %w", r.ID, refund, payment.Reference, err)
+	}
+	return nil
+}
+
+// rejectReturn closes a return without refunding or restocking it.
+func (h *Handler) rejectReturn(ctx context.Context, adminID int, r *types.Return, note string) error {
+	if err := h.store.SetReturnStatus(ctx, r.ID, types.ReturnRequested, types.ReturnRejected); err != nil {
+		return err
+	}
+	return h.audit(ctx, r.ID, adminID, types.ReturnEventRejected, note)
+}
+
+// capturedPayment returns the order's payment that collected its money.
+func (h *Handler) capturedPayment(ctx context.Context, orderID int) (*types.Payment, error) {
+	payments, err := h.paymentStore.GetPaymentsByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range payments {
+		if p.Status == types.PaymentCaptured && p.Provider == h.payments.Name() {
+			return &p, nil
+		}
+	}
+	return nil, types.Errorf(types.ErrConflict, "order %d has no captured payment to refund", orderID)
+}
+
+// audit records a step of a return.
+func (h *Handler) audit(ctx context.Context, returnID, actorID int, action, detail string) error {
+	err := h.store.AddReturnEvent(ctx, types.ReturnEvent{
+		ReturnID: returnID,
+		ActorID:  actorID,
+		Action:   action,
+		Detail:   detail,
+	})
+	if err != nil {
+		return fmt.Errorf("audit return %d: %w", returnID, err)
+	}
+	return nil
+}
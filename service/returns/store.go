@@ -0,0 +1,351 @@
+// Package returns : Returns (RMA) service
+package returns
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// returnsTable lists the columns scanRowIntoReturn reads, in order.
+var returnsTable = db.Table{
+	Name:    "returns",
+	Columns: []string{"id", "order_id", "user_id", "status", "reason", "refund", "created_at"},
+}
+
+// returnItemsTable lists the columns scanRowIntoReturnItem reads, in order.
+var returnItemsTable = db.Table{
+	Name:    "return_items",
+	Columns: []string{"id", "return_id", "order_item_id", "quantity"},
+}
+
+// returnEventsTable lists the columns scanRowIntoReturnEvent reads, in
+// order.
+var returnEventsTable = db.Table{
+	Name:    "return_events",
+	Columns: []string{"id", "return_id", "actor_id", "action", "detail", "created_at"},
+}
+
+// Tables : Tables and columns the store expects the migrations to create
+func Tables() []db.Table {
+	return []db.Table{returnsTable, returnItemsTable, returnEventsTable}
+}
+
+// Store : Return store
+type Store struct {
+	db *db.DB
+}
+
+// NewStore creates a new return store
+func NewStore(db *db.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateReturn creates a return with its items, once it has checked them
+// against the order's items and its other returns
+func (s *Store) CreateReturn(ctx context.Context, r types.Return) (int, error) {
+	var id int
+	err := s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		// Lock the order first, so concurrent returns of it are checked
+		// one after the other.
+		if _, err := tx.ExecContext(ctx, "UPDATE orders SET status = status WHERE id = ?", r.OrderID); err != nil {
+			return err
+		}
+
+		left, err := unreturned(ctx, tx, r.OrderID)
+		if err != nil {
+			return err
+		}
+		if err := types.CheckReturnItems(r.OrderID, left, r.Items); err != nil {
+			return err
+		}
+
+		id, err = tx.InsertID(ctx, "INSERT INTO returns (order_id, user_id, status, reason) VALUES (?, ?, ?, ?)", r.OrderID, r.UserID, types.ReturnRequested, r.Reason)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range r.Items {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO return_items (return_id, order_item_id, quantity) VALUES (?, ?, ?)", id, item.OrderItemID, item.Quantity); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+// unreturned returns how many of each item of an order, by ID, aren't in
+// one of its returns that wasn't rejected.
+func unreturned(ctx context.Context, tx *db.Tx, orderID int) (map[int]int, error) {
+	left := map[int]int{}
+	rows, err := tx.QueryContext(ctx, "SELECT id, quantity FROM order_items WHERE order_id = ?", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, quantity int
+		if err := rows.Scan(&id, &quantity); err != nil {
+			return nil, err
+		}
+		left[id] = quantity
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	returned, err := tx.QueryContext(ctx, `SELECT ri.order_item_id, ri.quantity FROM return_items ri
+		JOIN returns r ON r.id = ri.return_id
+		WHERE r.order_id = ? AND r.status <> ?`, orderID, types.ReturnRejected)
+	if err != nil {
+		return nil, err
+	}
+	defer returned.Close()
+
+	for returned.Next() {
+		var id, quantity int
+		if err := returned.Scan(&id, &quantity); err != nil {
+			return nil, err
+		}
+		left[id] -= quantity
+	}
+	return left, returned.Err()
+}
+
+// GetReturn gets a return with its items and events
+func (s *Store) GetReturn(ctx context.Context, id int) (*types.Return, error) {
+	returns, err := s.listReturns(ctx, "WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(returns) == 0 {
+		return nil, types.Errorf(types.ErrNotFound, "return %d not found", id)
+	}
+	r := &returns[0]
+
+	rows, err := s.db.QueryContext(ctx, returnEventsTable.Select("WHERE return_id = ? ORDER BY id"), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanRowIntoReturnEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		r.Events = append(r.Events, *e)
+	}
+
+	return r, rows.Err()
+}
+
+// ListReturns lists the returns matching filter with their items, newest
+// first
+func (s *Store) ListReturns(ctx context.Context, filter types.ReturnFilter) ([]types.Return, error) {
+	var conds []string
+	var args []any
+	if filter.UserID != 0 {
+		conds = append(conds, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.OrderID != 0 {
+		conds = append(conds, "order_id = ?")
+		args = append(args, filter.OrderID)
+	}
+	if filter.Status != "" {
+		conds = append(conds, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	return s.listReturns(ctx, where, args...)
+}
+
+// listReturns lists the returns matching where, newest first, and loads
+// their items.
+func (s *Store) listReturns(ctx context.Context, where string, args ...any) ([]types.Return, error) {
+	rows, err := s.db.QueryContext(ctx, returnsTable.Select(where+" ORDER BY id DESC"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	returns := []types.Return{}
+	index := map[int]int{}
+	for rows.Next() {
+		r, err := scanRowIntoReturn(rows)
+		if err != nil {
+			return nil, err
+		}
+		r.Items = []types.ReturnItem{}
+		index[r.ID] = len(returns)
+		returns = append(returns, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(returns) == 0 {
+		return returns, nil
+	}
+
+	itemRows, err := s.db.QueryContext(ctx, returnItemsTable.Select("WHERE return_id IN (SELECT id FROM returns "+where+") ORDER BY id"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		item, err := scanRowIntoReturnItem(itemRows)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[item.ReturnID]; ok {
+			returns[i].Items = append(returns[i].Items, *item)
+		}
+	}
+
+	return returns, itemRows.Err()
+}
+
+// SetReturnStatus moves a return from one status to another, failing with
+// ErrConflict when it's no longer in status from
+func (s *Store) SetReturnStatus(ctx context.Context, id int, from, to string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE returns SET status = ? WHERE id = ? AND status = ?", to, id, from)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return types.Errorf(types.ErrConflict, "return %d is no longer %s", id, from)
+	}
+	return nil
+}
+
+// ClaimRefund approves a requested return and adds its refund to its
+// payment in one transaction, failing with ErrConflict when the return is
+// no longer requested or the payment has less than refund left
+func (s *Store) ClaimRefund(ctx context.Context, returnID, paymentID int, refund float64) (float64, error) {
+	var refunded float64
+	err := s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE returns SET status = ?, refund = ? WHERE id = ? AND status = ?", types.ReturnApproved, refund, returnID, types.ReturnRequested)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return types.Errorf(types.ErrConflict, "return %d is no longer %s", returnID, types.ReturnRequested)
+		}
+
+		// The update locks the payment, so concurrent claims check what's
+		// left one after the other.
+		if _, err := tx.ExecContext(ctx, "UPDATE payments SET refunded = refunded + ? WHERE id = ?", refund, paymentID); err != nil {
+			return err
+		}
+		var amount float64
+		if err := tx.QueryRowContext(ctx, "SELECT amount, refunded FROM payments WHERE id = ?", paymentID).Scan(&amount, &refunded); err != nil {
+			return err
+		}
+		if cents(refunded) > cents(amount) {
+			return types.Errorf(types.ErrConflict, "only %.2f of payment %d is left to refund", amount-refunded+refund, paymentID)
+		}
+		return nil
+	})
+	return refunded, err
+}
+
+// ReleaseRefund reopens a claimed return and takes its refund off its
+// payment again
+func (s *Store) ReleaseRefund(ctx context.Context, returnID, paymentID int, refund float64) error {
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE returns SET status = ?, refund = 0 WHERE id = ? AND status = ?", types.ReturnRequested, returnID, types.ReturnApproved); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE payments SET refunded = refunded - ? WHERE id = ?", refund, paymentID)
+		return err
+	})
+}
+
+// ApproveReturn records what the refund of a claimed return changed,
+// restocks its items and updates its payment and order in one transaction
+func (s *Store) ApproveReturn(ctx context.Context, a types.ReturnApproval) error {
+	return s.db.RetryTx(ctx, func(tx *db.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE payments SET status = ? WHERE id = ?", a.PaymentStatus, a.PaymentID); err != nil {
+			return err
+		}
+
+		// Add to the stock rather than writing it back, so stock taken by
+		// concurrent checkouts isn't lost.
+		for _, item := range a.Restock {
+			query := "UPDATE products SET quantity = quantity + ? WHERE id = ?"
+			id := item.ProductID
+			if item.VariantID != 0 {
+				query = "UPDATE product_variants SET quantity = quantity + ? WHERE id = ?"
+				id = item.VariantID
+			}
+			if _, err := tx.ExecContext(ctx, query, item.Quantity, id); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE orders SET status = ? WHERE id = ?", a.OrderStatus, a.OrderID); err != nil {
+			return err
+		}
+		for _, e := range a.Events {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO return_events (return_id, actor_id, action, detail) VALUES (?, ?, ?, ?)", e.ReturnID, e.ActorID, e.Action, e.Detail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddReturnEvent appends an entry to the audit trail of a return
+func (s *Store) AddReturnEvent(ctx context.Context, e types.ReturnEvent) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO return_events (return_id, actor_id, action, detail) VALUES (?, ?, ?, ?)", e.ReturnID, e.ActorID, e.Action, e.Detail)
+	return err
+}
+
+func scanRowIntoReturn(rows *sql.Rows) (*types.Return, error) {
+	r := &types.Return{}
+	err := rows.Scan(&r.ID, &r.OrderID, &r.UserID, &r.Status, &r.Reason, &r.Refund, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func scanRowIntoReturnItem(rows *sql.Rows) (*types.ReturnItem, error) {
+	item := &types.ReturnItem{}
+	err := rows.Scan(&item.ID, &item.ReturnID, &item.OrderItemID, &item.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func scanRowIntoReturnEvent(rows *sql.Rows) (*types.ReturnEvent, error) {
+	e := &types.ReturnEvent{}
+	err := rows.Scan(&e.ID, &e.ReturnID, &e.ActorID, &e.Action, &e.Detail, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
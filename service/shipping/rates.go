@@ -0,0 +1,70 @@
+package shipping
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// volumetricDivisor converts a parcel's volume in cubic centimeters to the
+// weight in kilograms carriers bill bulky parcels by.
+const volumetricDivisor = 5000
+
+// Weight returns the billable weight of a cart in kilograms: the sum of the
+// larger of each item's actual and volumetric weight.
+func Weight(items []types.CartItem, products map[int]types.Product) float64 {
+	weight := 0.0
+	for _, item := range items {
+		p := products[item.ProductID]
+		volumetric := p.Length * p.Width * p.Height / volumetricDivisor
+		weight += max(p.Weight, volumetric) * float64(item.Quantity)
+	}
+	return weight
+}
+
+// Quote works out what a method costs to deliver a cart of the given
+// weight and subtotal to address. It reports false when the method doesn't
+// deliver there or can't carry the cart.
+func Quote(m types.ShippingMethod, weight, subtotal float64, address types.Address) (types.ShippingOption, bool) {
+	if len(m.Countries) > 0 && !slices.Contains(m.Countries, strings.ToUpper(address.Country)) {
+		return types.ShippingOption{}, false
+	}
+	if m.MaxWeight > 0 && weight > m.MaxWeight {
+		return types.ShippingOption{}, false
+	}
+
+	cost := 0.0
+	switch m.Kind {
+	case types.ShippingFlat:
+		cost = m.Price
+	case types.ShippingWeight:
+		// Every started kilogram is charged.
+		cost = m.Price + m.PricePerKg*math.Ceil(weight)
+	}
+	if m.FreeOver > 0 && subtotal >= m.FreeOver {
+		cost = 0
+	}
+
+	return types.ShippingOption{
+		MethodID: m.ID,
+		Name:     m.Name,
+		Kind:     m.Kind,
+		Cost:     math.Round(cost*100) / 100,
+	}, true
+}
+
+// Options quotes every method that can deliver a cart to address, in the
+// order of methods.
+func Options(methods []types.ShippingMethod, items []types.CartItem, products map[int]types.Product, subtotal float64, address types.Address) []types.ShippingOption {
+	weight := Weight(items, products)
+
+	options := []types.ShippingOption{}
+	for _, m := range methods {
+		if o, ok := Quote(m, weight, subtotal, address); ok {
+			options = append(options, o)
+		}
+	}
+	return options
+}
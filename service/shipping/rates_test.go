@@ -0,0 +1,73 @@
+package shipping
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+func TestWeight(t *testing.T) {
+	// A dense mug weighs more than its volume; a pillow is billed by its
+	// volumetric weight of 50*40*15/5000 = 6 kg.
+	products := map[int]types.Product{
+		1: {ID: 1, Weight: 0.4, Length: 10, Width: 10, Height: 10},
+		2: {ID: 2, Weight: 0.5, Length: 50, Width: 40, Height: 15},
+	}
+
+	if got := Weight([]types.CartItem{{ProductID: 1, Quantity: 3}, {ProductID: 2, Quantity: 1}}, products); got != 7.2 {
+		t.Errorf("expected a weight of 7.2 kg, got %v", got)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	us := types.Address{Country: "us"}
+
+	tests := []struct {
+		name     string
+		method   types.ShippingMethod
+		weight   float64
+		subtotal float64
+		address  types.Address
+		want     float64
+		ok       bool
+	}{
+		{name: "flat rate", method: types.ShippingMethod{Kind: types.ShippingFlat, Price: 4.95}, weight: 12, subtotal: 20, address: us, want: 4.95, ok: true},
+		{name: "weight-based charges every started kilogram", method: types.ShippingMethod{Kind: types.ShippingWeight, Price: 5, PricePerKg: 1.5}, weight: 2.1, subtotal: 20, address: us, want: 9.5, ok: true},
+		{name: "free over the threshold", method: types.ShippingMethod{Kind: types.ShippingFlat, Price: 4.95, FreeOver: 50}, weight: 1, subtotal: 50, address: us, want: 0, ok: true},
+		{name: "charged under the threshold", method: types.ShippingMethod{Kind: types.ShippingFlat, Price: 4.95, FreeOver: 50}, weight: 1, subtotal: 49.99, address: us, want: 4.95, ok: true},
+		{name: "pickup is free", method: types.ShippingMethod{Kind: types.ShippingPickup, Price: 3, Countries: []string{"US"}}, weight: 40, subtotal: 20, address: us, want: 0, ok: true},
+		{name: "other countries", method: types.ShippingMethod{Kind: types.ShippingPickup, Countries: []string{"US"}}, weight: 1, subtotal: 20, address: types.Address{Country: "CA"}},
+		{name: "too heavy", method: types.ShippingMethod{Kind: types.ShippingFlat, Price: 4.95, MaxWeight: 20}, weight: 20.5, subtotal: 20, address: us},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, ok := Quote(tt.method, tt.weight, tt.subtotal, tt.address)
+			if ok != tt.ok {
+				t.Fatalf("expected available %v, got %v", tt.ok, ok)
+			}
+			if ok && o.Cost != tt.want {
+				t.Errorf("expected a cost of %v, got %v", tt.want, o.Cost)
+			}
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	methods := []types.ShippingMethod{
+		{ID: 1, Name: "Standard", Kind: types.ShippingFlat, Price: 4.95},
+		{ID: 2, Name: "Freight", Kind: types.ShippingWeight, Price: 20, PricePerKg: 1, Countries: []string{"DE"}},
+		{ID: 3, Name: "Pickup", Kind: types.ShippingPickup},
+	}
+	products := map[int]types.Product{1: {ID: 1, Price: 10, Weight: 1}}
+
+	options := Options(methods, []types.CartItem{{ProductID: 1, Quantity: 2}}, products, 20, types.Address{Country: "US"})
+	want := []types.ShippingOption{
+		{MethodID: 1, Name: "Standard", Kind: types.ShippingFlat, Cost: 4.95},
+		{MethodID: 3, Name: "Pickup", Kind: types.ShippingPickup, Cost: 0},
+	}
+	if !slices.Equal(options, want) {
+		t.Errorf("expected options %+v, got %+v", want, options)
+	}
+}
@@ -0,0 +1,106 @@
+package shipping
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
+	"github.com/davidado/go-api-reference/service/auth"
+	"github.com/davidado/go-api-reference/types"
+	vd "github.com/davidado/go-api-reference/validator"
+	"github.com/gorilla/mux"
+)
+
+// Handler : Shipping method handler
+type Handler struct {
+	store     types.ShippingStore
+	userStore types.UserStore
+}
+
+// NewHandler creates a new shipping method handler
+func NewHandler(store types.ShippingStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+// RegisterRoutes registers shipping method routes
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/shipping-methods", h.handleGetShippingMethods).Methods(http.MethodGet)
+
+	// admin routes
+	router.HandleFunc("/shipping-methods", auth.WithAdminAuth(h.handleCreateShippingMethod, h.userStore)).Methods(http.MethodPost)
+}
+
+// Operations describes the shipping method routes for the OpenAPI document
+func (h *Handler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{
+			Method:     http.MethodGet,
+			Path:       "/shipping-methods",
+			Summary:    "List shipping methods",
+			Tags:       []string{"shipping"},
+			Response:   []types.ShippingMethod{},
+			MediaTypes: netjson.MediaTypes(),
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/shipping-methods",
+			Summary:  "Create a shipping method (admins only)",
+			Tags:     []string{"shipping"},
+			Auth:     true,
+			Request:  types.CreateShippingMethodPayload{},
+			Response: types.ShippingMethod{},
+			Status:   http.StatusCreated,
+		},
+	}
+}
+
+func (h *Handler) handleGetShippingMethods(w http.ResponseWriter, r *http.Request) {
+	methods, err := h.store.ListShippingMethods(r.Context())
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	netjson.Respond(w, r, http.StatusOK, methods)
+}
+
+func (h *Handler) handleCreateShippingMethod(w http.ResponseWriter, r *http.Request) {
+	var payload types.CreateShippingMethodPayload
+	if err := netjson.Parse(r, &payload); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	m := types.ShippingMethod{
+		Name:      payload.Name,
+		Kind:      payload.Kind,
+		FreeOver:  payload.FreeOver,
+		MaxWeight: payload.MaxWeight,
+		Countries: []string{},
+	}
+	// Pickups are free; only weight-based methods charge by weight.
+	if m.Kind != types.ShippingPickup {
+		m.Price = payload.Price
+	}
+	if m.Kind == types.ShippingWeight {
+		m.PricePerKg = payload.PricePerKg
+	}
+	for _, c := range payload.Countries {
+		m.Countries = append(m.Countries, strings.ToUpper(c))
+	}
+
+	id, err := h.store.CreateShippingMethod(r.Context(), m)
+	if err != nil {
+		netjson.WriteError(w, r, err)
+		return
+	}
+
+	m.ID = id
+	netjson.Write(w, http.StatusCreated, m)
+}
@@ -0,0 +1,92 @@
+// Package shipping : Shipping method service
+package shipping
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/types"
+)
+
+// shippingMethodsTable lists the columns scanRowIntoShippingMethod reads, in
+// order.
+var shippingMethodsTable = db.Table{
+	Name:    "shipping_methods",
+	Columns: []string{"id", "name", "kind", "price", "price_per_kg", "free_over", "max_weight", "countries", "created_at"},
+}
+
+// Tables : Tables and columns the store expects the migrations to create
+func Tables() []db.Table {
+	return []db.Table{shippingMethodsTable}
+}
+
+// Store : Shipping method store
+type Store struct {
+	db *db.DB
+}
+
+// NewStore creates a new shipping method store
+func NewStore(db *db.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateShippingMethod creates a shipping method
+func (s *Store) CreateShippingMethod(ctx context.Context, m types.ShippingMethod) (int, error) {
+	return s.db.InsertID(ctx,
+		"INSERT INTO shipping_methods (name, kind, price, price_per_kg, free_over, max_weight, countries) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		m.Name, m.Kind, m.Price, m.PricePerKg, m.FreeOver, m.MaxWeight, strings.ToUpper(strings.Join(m.Countries, ",")))
+}
+
+// GetShippingMethodByID gets a shipping method by ID
+func (s *Store) GetShippingMethodByID(ctx context.Context, id int) (*types.ShippingMethod, error) {
+	rows, err := s.db.QueryContext(ctx, shippingMethodsTable.Select("WHERE id = ?"), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, types.Errorf(types.ErrNotFound, "shipping method %d not found", id)
+	}
+	return scanRowIntoShippingMethod(rows)
+}
+
+// ListShippingMethods lists every shipping method in ID order
+func (s *Store) ListShippingMethods(ctx context.Context) ([]types.ShippingMethod, error) {
+	rows, err := s.db.QueryContext(ctx, shippingMethodsTable.Select("ORDER BY id"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	methods := []types.ShippingMethod{}
+	for rows.Next() {
+		m, err := scanRowIntoShippingMethod(rows)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, *m)
+	}
+
+	return methods, rows.Err()
+}
+
+func scanRowIntoShippingMethod(rows *sql.Rows) (*types.ShippingMethod, error) {
+	m := &types.ShippingMethod{}
+	var countries string
+	err := rows.Scan(&m.ID, &m.Name, &m.Kind, &m.Price, &m.PricePerKg, &m.FreeOver, &m.MaxWeight, &countries, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Countries = []string{}
+	if countries != "" {
+		m.Countries = strings.Split(countries, ",")
+	}
+	return m, nil
+}
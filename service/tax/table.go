@@ -0,0 +1,105 @@
+// Package tax : Sales tax calculation
+package tax
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// Rate : Tax rate of a country, or of a region of it
+type Rate struct {
+	Country string
+	// Region is empty for the country's own rate.
+	Region string
+	// Rate is a fraction, e.g. 0.19 for 19%.
+	Rate float64
+}
+
+// Table : Tax calculator that looks rates up by destination
+//
+// A region's rate takes precedence over its country's; destinations
+// without a rate aren't taxed.
+type Table struct {
+	rates     map[string]float64
+	inclusive bool
+}
+
+var _ types.TaxCalculator = (*Table)(nil)
+
+// NewTable creates a table of rates. With inclusive set, prices already
+// include their tax, which is taken out of them instead of added.
+func NewTable(rates []Rate, inclusive bool) *Table {
+	t := &Table{rates: make(map[string]float64, len(rates)), inclusive: inclusive}
+	for _, r := range rates {
+		t.rates[key(r.Country, r.Region)] = r.Rate
+	}
+	return t
+}
+
+// ParseRates parses comma-separated COUNTRY[-REGION]=RATE pairs, as set in
+// config.Envs.TaxRates, e.g. "DE=0.19,US-CA=0.0725".
+func ParseRates(s string) ([]Rate, error) {
+	var rates []Rate
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		place, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("tax: invalid rate %q, want COUNTRY[-REGION]=RATE", pair)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate < 0 || rate >= 1 {
+			return nil, fmt.Errorf("tax: invalid rate %q, want a fraction such as 0.19", pair)
+		}
+		country, region, _ := strings.Cut(strings.TrimSpace(place), "-")
+		if len(country) != 2 {
+			return nil, fmt.Errorf("tax: invalid country in %q, want a two-letter code", pair)
+		}
+
+		rates = append(rates, Rate{Country: country, Region: region, Rate: rate})
+	}
+	return rates, nil
+}
+
+// Calculate taxes every line at the rate of address
+func (t *Table) Calculate(_ context.Context, lines []types.TaxLine, address types.Address) (types.Tax, error) {
+	rate, ok := t.rates[key(address.Country, address.Region)]
+	if !ok {
+		rate = t.rates[key(address.Country, "")]
+	}
+
+	tax := types.Tax{Inclusive: t.inclusive, Lines: make([]types.TaxLine, len(lines))}
+	for i, line := range lines {
+		line.Rate = rate
+		if t.inclusive {
+			line.Tax = cents(line.Taxable - line.Taxable/(1+rate))
+		} else {
+			line.Tax = cents(line.Taxable * rate)
+		}
+		tax.Lines[i] = line
+		tax.Total += line.Tax
+	}
+	tax.Total = cents(tax.Total)
+	return tax, nil
+}
+
+// key identifies a destination regardless of case.
+func key(country, region string) string {
+	if region == "" {
+		return strings.ToUpper(country)
+	}
+	return strings.ToUpper(country + "-" + region)
+}
+
+// cents rounds an amount of money to cents.
+func cents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
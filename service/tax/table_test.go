@@ -0,0 +1,61 @@
+package tax
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+func TestParseRates(t *testing.T) {
+	rates, err := ParseRates(" DE=0.19, us-ca=0.0725 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates[0] != (Rate{Country: "DE", Rate: 0.19}) || rates[1] != (Rate{Country: "us", Region: "ca", Rate: 0.0725}) {
+		t.Errorf("unexpected rates %+v", rates)
+	}
+
+	for _, s := range []string{"DE", "DE=abc", "DE=19", "DE=-0.1", "GER=0.19"} {
+		if _, err := ParseRates(s); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	ctx := context.Background()
+	rates := []Rate{{Country: "US", Rate: 0.05}, {Country: "US", Region: "CA", Rate: 0.1}, {Country: "DE", Rate: 0.19}}
+	lines := []types.TaxLine{{ProductID: 1, Taxable: 20}, {ProductID: 2, Taxable: 9.99}}
+
+	tests := []struct {
+		name      string
+		inclusive bool
+		address   types.Address
+		rate      float64
+		taxes     []float64
+		total     float64
+	}{
+		{name: "region rate", address: types.Address{Country: "US", Region: "ca"}, rate: 0.1, taxes: []float64{2, 1}, total: 3},
+		{name: "country rate without a region rate", address: types.Address{Country: "US", Region: "OR"}, rate: 0.05, taxes: []float64{1, 0.5}, total: 1.5},
+		{name: "no rate", address: types.Address{Country: "FR"}, taxes: []float64{0, 0}},
+		{name: "inclusive", inclusive: true, address: types.Address{Country: "DE"}, rate: 0.19, taxes: []float64{3.19, 1.6}, total: 4.79},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tax, err := NewTable(rates, tt.inclusive).Calculate(ctx, lines, tt.address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tax.Inclusive != tt.inclusive || tax.Total != tt.total || len(tax.Lines) != len(lines) {
+				t.Fatalf("unexpected tax %+v", tax)
+			}
+			for i, line := range tax.Lines {
+				if line.ProductID != lines[i].ProductID || line.Rate != tt.rate || line.Tax != tt.taxes[i] {
+					t.Errorf("unexpected line %+v", line)
+				}
+			}
+		})
+	}
+}
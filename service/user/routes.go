@@ -2,19 +2,23 @@
 package user
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/davidado/go-api-reference/config"
 	"github.com/davidado/go-api-reference/netjson"
+	"github.com/davidado/go-api-reference/openapi"
 	"github.com/davidado/go-api-reference/service/auth"
 	"github.com/davidado/go-api-reference/types"
 	vd "github.com/davidado/go-api-reference/validator"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
+// errInvalidCredentials is returned for both unknown emails and wrong
+// passwords so clients can't probe for registered accounts.
+var errInvalidCredentials = types.Errorf(types.ErrUnauthorized, "invalid email or password")
+
 // Handler : User handler
 type Handler struct {
 	store types.UserStore
@@ -34,102 +38,140 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/users/{userID}", auth.WithJWTAuth(h.handleGetUser, h.store)).Methods(http.MethodGet)
 }
 
+// Operations describes the user routes for the OpenAPI document
+func (h *Handler) Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{
+			Method:   http.MethodPost,
+			Path:     "/login",
+			Summary:  "Log in and receive a JWT",
+			Tags:     []string{"users"},
+			Request:  types.LoginUserPayload{},
+			Response: types.LoginResponse{},
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/register",
+			Summary:  "Register a new user",
+			Tags:     []string{"users"},
+			Request:  types.RegisterUserPayload{},
+			Response: types.MessageResponse{},
+			Status:   http.StatusCreated,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/users/{userID}",
+			Summary:  "Get a user by ID",
+			Tags:     []string{"users"},
+			Auth:     true,
+			Params:   []openapi.Param{{Name: "userID", Type: "integer", Description: "User ID"}},
+			Response: types.User{},
+		},
+	}
+}
+
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 	// get JSON payload.
 	var payload types.LoginUserPayload
 	if err := netjson.Parse(r, &payload); err != nil {
-		netjson.WriteError(w, http.StatusBadRequest, err)
+		netjson.WriteError(w, r, err)
 		return
 	}
 
 	// validate the payload.
-	if err := vd.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		netjson.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
 		return
 	}
 
-	u, err := h.store.GetUserByEmail(payload.Email)
+	u, err := h.store.GetUserByEmail(r.Context(), payload.Email)
+	if errors.Is(err, types.ErrNotFound) {
+		netjson.WriteError(w, r, errInvalidCredentials)
+		return
+	}
 	if err != nil {
-		netjson.WriteError(w, http.StatusBadRequest, fmt.Errorf("not found, invalid email or password"))
+		netjson.WriteError(w, r, err)
 		return
 	}
 
 	if !auth.ComparePasswords(u.Password, []byte(payload.Password)) {
-		netjson.WriteError(w, http.StatusBadRequest, fmt.Errorf("not found, invalid email or password"))
+		netjson.WriteError(w, r, errInvalidCredentials)
 		return
 	}
 
 	secret := []byte(config.Envs.JWTSecret)
 	token, err := auth.CreateJWT(secret, u.ID)
 	if err != nil {
-		netjson.WriteError(w, http.StatusInternalServerError, err)
+		netjson.WriteError(w, r, err)
 		return
 	}
 
-	netjson.Write(w, http.StatusOK, map[string]string{"token": token})
+	netjson.Write(w, http.StatusOK, types.LoginResponse{Token: token})
 }
 
 func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	// get JSON payload.
 	var payload types.RegisterUserPayload
 	if err := netjson.Parse(r, &payload); err != nil {
-		netjson.WriteError(w, http.StatusBadRequest, err)
+		netjson.WriteError(w, r, err)
 		return
 	}
 
 	// validate the payload.
-	if err := vd.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		netjson.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+	if err := vd.Struct(payload, r.Header.Get("Accept-Language")); err != nil {
+		netjson.WriteError(w, r, err)
 		return
 	}
 
 	// Check if the user exists.
-	_, err := h.store.GetUserByEmail(payload.Email)
-	if err != nil {
-		netjson.WriteError(w, http.StatusBadRequest, fmt.Errorf("user with email %s already exists", payload.Email))
+	_, err := h.store.GetUserByEmail(r.Context(), payload.Email)
+	if err == nil {
+		netjson.WriteError(w, r, types.Errorf(types.ErrConflict, "user with email %s already exists", payload.Email))
+		return
+	}
+	if !errors.Is(err, types.ErrNotFound) {
+		netjson.WriteError(w, r, err)
 		return
 	}
 
 	hashedPassword, err := auth.HashPassword(payload.Password)
 	if err != nil {
-		netjson.WriteError(w, http.StatusInternalServerError, err)
+		netjson.WriteError(w, r, err)
 		return
 	}
 
 	// if it doesn't, we create the new user.
-	err = h.store.CreateUser(types.User{
+	err = h.store.CreateUser(r.Context(), types.User{
 		FirstName: payload.FirstName,
 		LastName:  payload.LastName,
 		Email:     payload.Email,
 		Password:  hashedPassword,
 	})
 	if err != nil {
-		netjson.WriteError(w, http.StatusInternalServerError, err)
+		netjson.WriteError(w, r, err)
 		return
 	}
 
-	netjson.Write(w, http.StatusCreated, map[string]string{"message": "user created"})
+	netjson.Write(w, http.StatusCreated, types.MessageResponse{Message: "user created"})
 }
 
 func (h *Handler) handleGetUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	str, ok := vars["userID"]
 	if !ok {
-		netjson.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing user ID"))
+		netjson.WriteError(w, r, types.Errorf(types.ErrBadRequest, "missing user ID"))
 		return
 	}
 
 	userID, err := strconv.Atoi(str)
 	if err != nil {
-		netjson.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid user ID"))
+		netjson.WriteError(w, r, types.Errorf(types.ErrBadRequest, "invalid user ID"))
 		return
 	}
 
-	user, err := h.store.GetUserByID(userID)
+	user, err := h.store.GetUserByID(r.Context(), userID)
 	if err != nil {
-		netjson.WriteError(w, http.StatusInternalServerError, err)
+		netjson.WriteError(w, r, err)
 		return
 	}
 
@@ -2,19 +2,32 @@ package user
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/davidado/go-api-reference/memstore"
+	"github.com/davidado/go-api-reference/netjson"
 	"github.com/davidado/go-api-reference/types"
 	"github.com/gorilla/mux"
 )
 
 func TestUserServiceHandlers(t *testing.T) {
-	userStore := &mockUserStore{}
+	userStore := memstore.New()
 	handler := NewHandler(userStore)
 
+	ctx := context.Background()
+	if err := userStore.CreateUser(ctx, types.User{FirstName: "Jane", LastName: "Doe", Email: "taken@mail.com", Password: "hash"}); err != nil {
+		t.Fatal(err)
+	}
+	taken, err := userStore.GetUserByEmail(ctx, "taken@mail.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	t.Run("should fail if the user ID is not a number", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, "/user/abc", nil)
 		if err != nil {
@@ -34,7 +47,7 @@ func TestUserServiceHandlers(t *testing.T) {
 	})
 
 	t.Run("should handle get user by ID", func(t *testing.T) {
-		req, err := http.NewRequest(http.MethodGet, "/user/42", nil)
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/user/%d", taken.ID), nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -49,6 +62,32 @@ func TestUserServiceHandlers(t *testing.T) {
 		if rr.Code != http.StatusOK {
 			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
 		}
+
+		var u types.User
+		if err := json.NewDecoder(rr.Body).Decode(&u); err != nil {
+			t.Fatal(err)
+		}
+		if u.ID != taken.ID || u.Email != "taken@mail.com" {
+			t.Errorf("unexpected user %+v", u)
+		}
+	})
+
+	t.Run("should return not found for an unknown user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/user/9999", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}", handler.handleGetUser).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
 	})
 
 	t.Run("should fail if the user payload is invalid", func(t *testing.T) {
@@ -64,6 +103,7 @@ func TestUserServiceHandlers(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		router := mux.NewRouter()
@@ -74,6 +114,14 @@ func TestUserServiceHandlers(t *testing.T) {
 		if rr.Code != http.StatusBadRequest {
 			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
 		}
+
+		var p netjson.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Errors) != 1 || p.Errors[0].JSONPointer != "/email" || p.Errors[0].Rule != "email" {
+			t.Errorf("unexpected field errors %+v", p.Errors)
+		}
 	})
 
 	t.Run("should correctly register the user", func(t *testing.T) {
@@ -89,6 +137,7 @@ func TestUserServiceHandlers(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
 		router := mux.NewRouter()
@@ -99,23 +148,50 @@ func TestUserServiceHandlers(t *testing.T) {
 		if rr.Code != http.StatusCreated {
 			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
 		}
+
+		u, err := userStore.GetUserByEmail(ctx, "valid@mail.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Password == "password" {
+			t.Error("expected the password to be stored hashed")
+		}
 	})
-}
 
-type mockUserStore struct{}
+	t.Run("should return a conflict problem if the email is taken", func(t *testing.T) {
+		payload := types.RegisterUserPayload{
+			FirstName: "John",
+			LastName:  "Doe",
+			Email:     "taken@mail.com",
+			Password:  "password",
+		}
+		marshalled, _ := json.Marshal(payload)
 
-func (m *mockUserStore) UpdateUser(_ types.User) error {
-	return nil
-}
+		req, err := http.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
 
-func (m *mockUserStore) GetUserByEmail(_ string) (*types.User, error) {
-	return &types.User{}, nil
-}
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
 
-func (m *mockUserStore) GetUserByID(_ int) (*types.User, error) {
-	return &types.User{}, nil
-}
+		router.HandleFunc("/register", handler.handleRegister)
+		router.ServeHTTP(rr, req)
 
-func (m *mockUserStore) CreateUser(_ types.User) error {
-	return nil
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != netjson.ProblemContentType {
+			t.Errorf("expected content type %q, got %q", netjson.ProblemContentType, ct)
+		}
+
+		var p netjson.Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Code != "conflict" || p.Instance != "/register" {
+			t.Errorf("unexpected problem %+v", p)
+		}
+	})
 }
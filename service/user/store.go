@@ -1,68 +1,77 @@
 package user
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 
+	"github.com/davidado/go-api-reference/db"
 	"github.com/davidado/go-api-reference/types"
 )
 
+// usersTable lists the columns scanRowIntoUser reads, in order.
+var usersTable = db.Table{
+	Name:    "users",
+	Columns: []string{"id", "first_name", "last_name", "email", "password", "created_at"},
+}
+
+// Tables : Tables and columns the store expects the migrations to create
+func Tables() []db.Table {
+	return []db.Table{usersTable}
+}
+
 // Store : User store
 type Store struct {
-	db *sql.DB
+	db *db.DB
 }
 
 // NewStore : Create a new user store
-func NewStore(db *sql.DB) *Store {
+func NewStore(db *db.DB) *Store {
 	return &Store{db: db}
 }
 
 // GetUserByEmail : Get user by email
-func (s *Store) GetUserByEmail(email string) (*types.User, error) {
-	rows, err := s.db.Query("SELECT * FROM users WHERE email = ? LIMIT 1", email)
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	rows, err := s.db.QueryContext(ctx, usersTable.Select("WHERE email = ? LIMIT 1"), email)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	u := &types.User{}
-	for rows.Next() {
-		u, err = scanRowIntoUser(rows)
-		if err != nil {
-			if err != sql.ErrNoRows {
-				return nil, fmt.Errorf("user not found")
-			}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
 			return nil, err
 		}
+		return nil, types.Errorf(types.ErrNotFound, "user not found")
 	}
 
-	return u, nil
+	return scanRowIntoUser(rows)
 }
 
 // GetUserByID : Get user by ID
-func (s *Store) GetUserByID(id int) (*types.User, error) {
-	rows, err := s.db.Query("SELECT * FROM users WHERE id = ? LIMIT 1", id)
+func (s *Store) GetUserByID(ctx context.Context, id int) (*types.User, error) {
+	rows, err := s.db.Read(ctx).QueryContext(ctx, usersTable.Select("WHERE id = ? LIMIT 1"), id)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	u := &types.User{}
-	for rows.Next() {
-		u, err = scanRowIntoUser(rows)
-		if err != nil {
-			if err != sql.ErrNoRows {
-				return nil, fmt.Errorf("user not found")
-			}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
 			return nil, err
 		}
+		return nil, types.Errorf(types.ErrNotFound, "user %d not found", id)
 	}
 
-	return u, nil
+	return scanRowIntoUser(rows)
 }
 
 // CreateUser : Create a new user
-func (s *Store) CreateUser(u types.User) error {
-	_, err := s.db.Exec("INSERT INTO users (first_name, last_name, email, password) VALUES (?, ?, ?, ?)", u.FirstName, u.LastName, u.Email, u.Password)
+func (s *Store) CreateUser(ctx context.Context, u types.User) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO users (first_name, last_name, email, password) VALUES (?, ?, ?, ?)", u.FirstName, u.LastName, u.Email, u.Password)
 	if err != nil {
+		if s.db.Dialect.IsUniqueViolation(err) {
+			return types.Errorf(types.ErrConflict, "user with email %s already exists", u.Email)
+		}
 		return err
 	}
 	return nil
@@ -0,0 +1,115 @@
+package storetest_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidado/go-api-reference/cmd/migrate/migrations"
+	"github.com/davidado/go-api-reference/db"
+	"github.com/davidado/go-api-reference/memstore"
+	"github.com/davidado/go-api-reference/mysqltest"
+	"github.com/davidado/go-api-reference/service/category"
+	"github.com/davidado/go-api-reference/service/inventory"
+	"github.com/davidado/go-api-reference/service/order"
+	"github.com/davidado/go-api-reference/service/payment"
+	"github.com/davidado/go-api-reference/service/product"
+	"github.com/davidado/go-api-reference/service/promotion"
+	"github.com/davidado/go-api-reference/service/returns"
+	"github.com/davidado/go-api-reference/service/shipping"
+	"github.com/davidado/go-api-reference/service/user"
+	"github.com/davidado/go-api-reference/storetest"
+)
+
+func TestMemStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		s := memstore.New()
+		return storetest.Stores{Users: s, Products: s, Orders: s, Payments: s, Returns: s, Promotions: s, Shipping: s, Inventory: s, Variants: s, Categories: s}
+	})
+}
+
+func TestMySQLStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		conn := mysqltest.New(t)
+		return storetest.Stores{
+			Users:      user.NewStore(conn),
+			Products:   product.NewStore(conn),
+			Orders:     order.NewStore(conn),
+			Payments:   payment.NewStore(conn),
+			Returns:    returns.NewStore(conn),
+			Promotions: promotion.NewStore(conn),
+			Shipping:   shipping.NewStore(conn),
+			Inventory:  inventory.NewStore(conn),
+			Variants:   product.NewStore(conn),
+			Categories: category.NewStore(conn),
+		}
+	})
+}
+
+func TestSQLiteStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		sqlDB, err := db.NewSQLiteStorage(filepath.Join(t.TempDir(), "ecom.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sqlStores(t, sqlDB, db.SQLite, false)
+	})
+}
+
+// TestPostgresStore runs against the database in TEST_POSTGRES_DSN. Every
+// subtest drops and re-creates its schema, so point it at a scratch
+// database.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) storetest.Stores {
+		sqlDB, err := db.NewPostgresStorage(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sqlStores(t, sqlDB, db.Postgres, true)
+	})
+}
+
+// sqlStores migrates sqlDB and builds the SQL stores on it. drop clears a
+// shared database first; fresh SQLite files don't need it.
+func sqlStores(t *testing.T, sqlDB *sql.DB, driver string, drop bool) storetest.Stores {
+	t.Helper()
+	t.Cleanup(func() { sqlDB.Close() })
+
+	dialect, err := db.DialectFor(driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := db.New(sqlDB, dialect)
+
+	if drop {
+		m, err := migrations.New(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Drop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := migrations.Up(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	return storetest.Stores{
+		Users:      user.NewStore(conn),
+		Products:   product.NewStore(conn),
+		Orders:     order.NewStore(conn),
+		Payments:   payment.NewStore(conn),
+		Returns:    returns.NewStore(conn),
+		Promotions: promotion.NewStore(conn),
+		Shipping:   shipping.NewStore(conn),
+		Inventory:  inventory.NewStore(conn),
+		Variants:   product.NewStore(conn),
+		Categories: category.NewStore(conn),
+	}
+}
@@ -0,0 +1,1827 @@
+// Package storetest : Conformance tests every store backend must pass
+//
+// A backend's test calls Run with a function returning fresh, empty stores:
+//
+//	func TestStores(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) storetest.Stores {
+//			s := memstore.New()
+//			return storetest.Stores{Users: s, Products: s, Orders: s, Payments: s, Returns: s, Promotions: s, Shipping: s, Inventory: s, Variants: s, Categories: s}
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+// Stores : The stores of one backend under test
+type Stores struct {
+	Users      types.UserStore
+	Products   types.ProductStore
+	Orders     types.OrderStore
+	Payments   types.PaymentStore
+	Returns    types.ReturnStore
+	Promotions types.PromotionStore
+	Shipping   types.ShippingStore
+	Inventory  types.InventoryStore
+	Variants   types.VariantStore
+	Categories types.CategoryStore
+}
+
+// Run runs the conformance suite. newStores is called once per subtest and
+// must return stores backed by an empty database.
+func Run(t *testing.T, newStores func(t *testing.T) Stores) {
+	t.Run("UserStore", func(t *testing.T) { testUserStore(t, newStores) })
+	t.Run("ProductStore", func(t *testing.T) { testProductStore(t, newStores) })
+	t.Run("OrderStore", func(t *testing.T) { testOrderStore(t, newStores) })
+	t.Run("PaymentStore", func(t *testing.T) { testPaymentStore(t, newStores) })
+	t.Run("ReturnStore", func(t *testing.T) { testReturnStore(t, newStores) })
+	t.Run("PromotionStore", func(t *testing.T) { testPromotionStore(t, newStores) })
+	t.Run("ShippingStore", func(t *testing.T) { testShippingStore(t, newStores) })
+	t.Run("InventoryStore", func(t *testing.T) { testInventoryStore(t, newStores) })
+	t.Run("VariantStore", func(t *testing.T) { testVariantStore(t, newStores) })
+	t.Run("CategoryStore", func(t *testing.T) { testCategoryStore(t, newStores) })
+}
+
+func testUserStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+
+	t.Run("should create and get a user by email and ID", func(t *testing.T) {
+		s := newStores(t)
+
+		want := types.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "hash"}
+		if err := s.Users.CreateUser(ctx, want); err != nil {
+			t.Fatal(err)
+		}
+
+		byEmail, err := s.Users.GetUserByEmail(ctx, want.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if byEmail.ID == 0 || byEmail.CreatedAt.IsZero() {
+			t.Errorf("expected the ID and creation time to be set, got %+v", byEmail)
+		}
+		if byEmail.FirstName != want.FirstName || byEmail.LastName != want.LastName || byEmail.Password != want.Password {
+			t.Errorf("expected %+v, got %+v", want, byEmail)
+		}
+
+		byID, err := s.Users.GetUserByID(ctx, byEmail.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if byID.Email != want.Email {
+			t.Errorf("expected email %q, got %q", want.Email, byID.Email)
+		}
+	})
+
+	t.Run("should return not found for unknown users", func(t *testing.T) {
+		s := newStores(t)
+
+		if _, err := s.Users.GetUserByEmail(ctx, "nobody@example.com"); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound by email, got %v", err)
+		}
+		if _, err := s.Users.GetUserByID(ctx, 42); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound by ID, got %v", err)
+		}
+	})
+
+	t.Run("should reject a duplicate email", func(t *testing.T) {
+		s := newStores(t)
+
+		u := types.User{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "hash"}
+		if err := s.Users.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Users.CreateUser(ctx, u); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict, got %v", err)
+		}
+	})
+}
+
+func testProductStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+
+	seed := func(t *testing.T, s Stores, names ...string) []int {
+		t.Helper()
+
+		ids := make([]int, len(names))
+		for i, name := range names {
+			id, err := s.Products.CreateProduct(ctx, types.Product{
+				Name:        name,
+				Description: name + " description",
+				Image:       name + ".jpg",
+				Price:       float64(i+1) * 1.25,
+				Quantity:    10 * (i + 1),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids[i] = id
+		}
+		return ids
+	}
+
+	t.Run("should list products in ID order", func(t *testing.T) {
+		s := newStores(t)
+		ids := seed(t, s, "mug", "kettle", "grinder")
+
+		ps, err := s.Products.GetProducts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ps) != 3 {
+			t.Fatalf("expected 3 products, got %d", len(ps))
+		}
+		for i, p := range ps {
+			if p.ID != ids[i] {
+				t.Errorf("expected product %d at position %d, got %d", ids[i], i, p.ID)
+			}
+		}
+		if p := ps[1]; p.Name != "kettle" || p.Description != "kettle description" || p.Image != "kettle.jpg" || p.Price != 2.5 || p.Quantity != 20 || p.CreatedAt.IsZero() {
+			t.Errorf("unexpected product %+v", p)
+		}
+	})
+
+	t.Run("should return an empty list without products", func(t *testing.T) {
+		s := newStores(t)
+
+		ps, err := s.Products.GetProducts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps == nil || len(ps) != 0 {
+			t.Errorf("expected an empty non-nil list, got %#v", ps)
+		}
+	})
+
+	t.Run("should stream every product", func(t *testing.T) {
+		s := newStores(t)
+		seed(t, s, "mug", "kettle")
+
+		var names []string
+		err := s.Products.StreamProducts(ctx, types.ProductFilter{}, func(p types.Product) error {
+			names = append(names, p.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 2 || names[0] != "mug" || names[1] != "kettle" {
+			t.Errorf("unexpected products %v", names)
+		}
+	})
+
+	t.Run("should stop streaming when the callback fails", func(t *testing.T) {
+		s := newStores(t)
+		seed(t, s, "mug", "kettle")
+
+		stop := errors.New("stop")
+		calls := 0
+		err := s.Products.StreamProducts(ctx, types.ProductFilter{}, func(types.Product) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("expected the callback error after 1 call, got %v after %d", err, calls)
+		}
+	})
+
+	t.Run("should get products by ID, skipping unknown IDs", func(t *testing.T) {
+		s := newStores(t)
+		ids := seed(t, s, "mug", "kettle", "grinder")
+
+		ps, err := s.Products.GetProductsByID(ctx, []int{ids[2], ids[0], 9999})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ps) != 2 || ps[0].ID != ids[0] || ps[1].ID != ids[2] {
+			t.Errorf("unexpected products %+v", ps)
+		}
+	})
+
+	t.Run("should look up products once each and report missing IDs", func(t *testing.T) {
+		s := newStores(t)
+		ids := seed(t, s, "mug", "kettle", "grinder")
+
+		ps, err := s.Products.GetProductsByID(ctx, []int{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps == nil || len(ps) != 0 {
+			t.Errorf("expected an empty list, got %#v", ps)
+		}
+
+		lookup, err := s.Products.LookupProducts(ctx, []int{ids[1], 9999, ids[1], ids[0], 9998, 9999})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lookup.Products) != 2 || lookup.Products[0].ID != ids[0] || lookup.Products[1].ID != ids[1] {
+			t.Errorf("expected each product once, got %+v", lookup.Products)
+		}
+		if len(lookup.Missing) != 2 || lookup.Missing[0] != 9998 || lookup.Missing[1] != 9999 {
+			t.Errorf("expected missing IDs [9998 9999], got %v", lookup.Missing)
+		}
+
+		// More IDs than a single query takes.
+		many := []int{ids[2]}
+		for id := 10000; id < 11200; id++ {
+			many = append(many, id)
+		}
+		many = append(many, ids[0])
+		lookup, err = s.Products.LookupProducts(ctx, many)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lookup.Products) != 2 || lookup.Products[0].ID != ids[0] || lookup.Products[1].ID != ids[2] || len(lookup.Missing) != 1200 {
+			t.Errorf("unexpected lookup of %d IDs: %d products, %d missing", len(many), len(lookup.Products), len(lookup.Missing))
+		}
+	})
+
+	t.Run("should update a product", func(t *testing.T) {
+		s := newStores(t)
+		ids := seed(t, s, "mug")
+
+		ps, err := s.Products.GetProductsByID(ctx, ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := ps[0]
+		p.Name = "large mug"
+		p.Price = 9.99
+		p.Quantity = 3
+		p.Weight = 0.35
+		p.Length, p.Width, p.Height = 12, 9.5, 10
+
+		if err := s.Products.UpdateProduct(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+
+		ps, err = s.Products.GetProductsByID(ctx, ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ps[0]; got.Name != "large mug" || got.Price != 9.99 || got.Quantity != 3 ||
+			got.Weight != 0.35 || got.Length != 12 || got.Width != 9.5 || got.Height != 10 {
+			t.Errorf("unexpected product after update %+v", got)
+		}
+	})
+}
+
+func testOrderStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T, s Stores, email string) (userID, productID int) {
+		t.Helper()
+
+		if err := s.Users.CreateUser(ctx, types.User{FirstName: "A", LastName: "B", Email: email, Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.Users.GetUserByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		productID, err = s.Products.CreateProduct(ctx, types.Product{Name: "mug", Description: "", Image: "", Price: 5, Quantity: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.ID, productID
+	}
+
+	t.Run("should create orders with items", func(t *testing.T) {
+		s := newStores(t)
+		userID, productID := setup(t, s, "buyer@example.com")
+
+		methodID, err := s.Shipping.CreateShippingMethod(ctx, types.ShippingMethod{Name: "Standard", Kind: types.ShippingFlat, Price: 4.95})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		orderID, err := s.Orders.CreateOrder(ctx, types.Order{
+			UserID: userID, Total: 10, Status: "pending", Address: "1 Main St", TaxInclusive: true,
+			ShippingMethodID: methodID, ShippingMethod: "Standard", ShippingCost: 4.95,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if orderID == 0 {
+			t.Fatal("expected an order ID")
+		}
+
+		err = s.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: productID, Quantity: 2, Price: 5, TaxRate: 0.0725, Tax: 0.68})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		o, err := s.Orders.GetOrderByID(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.ID != orderID || o.UserID != userID || o.Total != 10 || !o.TaxInclusive || o.CreatedAt.IsZero() ||
+			o.ShippingMethodID != methodID || o.ShippingMethod != "Standard" || o.ShippingCost != 4.95 {
+			t.Errorf("unexpected order %+v", o)
+		}
+
+		items, err := s.Orders.GetOrderItems(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 || items[0].ID == 0 || items[0].OrderID != orderID || items[0].ProductID != productID || items[0].Quantity != 2 || items[0].Price != 5 || items[0].TaxRate != 0.0725 || items[0].Tax != 0.68 {
+			t.Errorf("unexpected order items %+v", items)
+		}
+	})
+
+	t.Run("should return not found for unknown orders", func(t *testing.T) {
+		s := newStores(t)
+
+		if _, err := s.Orders.GetOrderByID(ctx, 42); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should stream a user's orders newest first", func(t *testing.T) {
+		s := newStores(t)
+		userID, _ := setup(t, s, "buyer@example.com")
+		otherID, _ := setup(t, s, "other@example.com")
+
+		first, err := s.Orders.CreateOrder(ctx, types.Order{UserID: userID, Total: 10, Status: "pending", Address: "1 Main St"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Orders.CreateOrder(ctx, types.Order{UserID: otherID, Total: 1, Status: "pending", Address: "2 Main St"}); err != nil {
+			t.Fatal(err)
+		}
+		second, err := s.Orders.CreateOrder(ctx, types.Order{UserID: userID, Total: 20.5, Status: "pending", Address: "1 Main St"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var orders []types.Order
+		err = s.Orders.StreamOrdersByUser(ctx, userID, func(o types.Order) error {
+			orders = append(orders, o)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(orders) != 2 || orders[0].ID != second || orders[1].ID != first {
+			t.Fatalf("unexpected orders %+v", orders)
+		}
+		if o := orders[0]; o.UserID != userID || o.Total != 20.5 || o.Status != "pending" || o.Address != "1 Main St" || o.CreatedAt.IsZero() {
+			t.Errorf("unexpected order %+v", o)
+		}
+	})
+
+	t.Run("should update an order's status", func(t *testing.T) {
+		s := newStores(t)
+		userID, _ := setup(t, s, "buyer@example.com")
+
+		orderID, err := s.Orders.CreateOrder(ctx, types.Order{UserID: userID, Total: 10, Status: types.OrderPending, Address: "1 Main St"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Orders.UpdateOrderStatus(ctx, orderID, types.OrderPaid); err != nil {
+			t.Fatal(err)
+		}
+
+		var orders []types.Order
+		err = s.Orders.StreamOrdersByUser(ctx, userID, func(o types.Order) error {
+			orders = append(orders, o)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(orders) != 1 || orders[0].Status != types.OrderPaid {
+			t.Errorf("expected a paid order, got %+v", orders)
+		}
+	})
+}
+
+func testPaymentStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+
+	newOrder := func(t *testing.T, s Stores) int {
+		t.Helper()
+
+		if err := s.Users.CreateUser(ctx, types.User{FirstName: "A", LastName: "B", Email: "buyer@example.com", Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.Users.GetUserByEmail(ctx, "buyer@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		orderID, err := s.Orders.CreateOrder(ctx, types.Order{UserID: u.ID, Total: 12.5, Status: types.OrderPending, Address: "1 Main St"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return orderID
+	}
+
+	t.Run("should create, get and update a payment", func(t *testing.T) {
+		s := newStores(t)
+		orderID := newOrder(t, s)
+
+		id, err := s.Payments.CreatePayment(ctx, types.Payment{OrderID: orderID, Provider: "fake", Reference: "fake_1", Status: types.PaymentAuthorized, Amount: 12.5})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := s.Payments.GetPaymentByReference(ctx, "fake", "fake_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ID != id || p.OrderID != orderID || p.Status != types.PaymentAuthorized || p.Amount != 12.5 || p.CreatedAt.IsZero() {
+			t.Errorf("unexpected payment %+v", p)
+		}
+
+		if err := s.Payments.UpdatePaymentStatus(ctx, id, types.PaymentCaptured); err != nil {
+			t.Fatal(err)
+		}
+		p, err = s.Payments.GetPaymentByReference(ctx, "fake", "fake_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Status != types.PaymentCaptured {
+			t.Errorf("expected a captured payment, got %s", p.Status)
+		}
+
+		payments, err := s.Payments.GetPaymentsByOrder(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(payments) != 1 || payments[0].ID != id || payments[0].Refunded != 0 {
+			t.Errorf("expected the payment without refunds, got %+v", payments)
+		}
+	})
+
+	t.Run("should refuse a duplicate reference", func(t *testing.T) {
+		s := newStores(t)
+		orderID := newOrder(t, s)
+
+		p := types.Payment{OrderID: orderID, Provider: "fake", Reference: "fake_1", Status: types.PaymentAuthorized, Amount: 12.5}
+		if _, err := s.Payments.CreatePayment(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Payments.CreatePayment(ctx, p); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict, got %v", err)
+		}
+	})
+
+	t.Run("should report unknown references", func(t *testing.T) {
+		s := newStores(t)
+
+		if _, err := s.Payments.GetPaymentByReference(ctx, "fake", "fake_1"); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func testReturnStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+
+	// setup creates a user with an order of two items and returns the IDs
+	// of the user, the order and the items.
+	setup := func(t *testing.T, s Stores, email string) (userID, orderID int, itemIDs []int) {
+		t.Helper()
+
+		if err := s.Users.CreateUser(ctx, types.User{FirstName: "A", LastName: "B", Email: email, Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.Users.GetUserByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		productID, err := s.Products.CreateProduct(ctx, types.Product{Name: "mug", Price: 5, Quantity: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		orderID, err = s.Orders.CreateOrder(ctx, types.Order{UserID: u.ID, Total: 15, Status: types.OrderPaid, Address: "1 Main St"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, qty := range []int{1, 2} {
+			if err := s.Orders.CreateOrderItem(ctx, types.OrderItem{OrderID: orderID, ProductID: productID, Quantity: qty, Price: 5}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		items, err := s.Orders.GetOrderItems(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, oi := range items {
+			itemIDs = append(itemIDs, oi.ID)
+		}
+		return u.ID, orderID, itemIDs
+	}
+
+	t.Run("should create and get a return with items and events", func(t *testing.T) {
+		s := newStores(t)
+		userID, orderID, itemIDs := setup(t, s, "buyer@example.com")
+
+		id, err := s.Returns.CreateReturn(ctx, types.Return{
+			OrderID: orderID,
+			UserID:  userID,
+			Reason:  "broken",
+			Items:   []types.ReturnItem{{OrderItemID: itemIDs[0], Quantity: 1}, {OrderItemID: itemIDs[1], Quantity: 2}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, action := range []string{types.ReturnEventRequested, types.ReturnEventApproved} {
+			if err := s.Returns.AddReturnEvent(ctx, types.ReturnEvent{ReturnID: id, ActorID: userID, Action: action, Detail: "note"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Returns.SetReturnStatus(ctx, id, types.ReturnRequested, types.ReturnApproved); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Returns.SetReturnStatus(ctx, id, types.ReturnRequested, types.ReturnRejected); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected %v for a return that's no longer requested, got %v", types.ErrConflict, err)
+		}
+
+		r, err := s.Returns.GetReturn(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.ID != id || r.OrderID != orderID || r.UserID != userID || r.Status != types.ReturnApproved || r.Reason != "broken" || r.Refund != 0 || r.CreatedAt.IsZero() {
+			t.Errorf("unexpected return %+v", r)
+		}
+		if len(r.Items) != 2 || r.Items[0].ReturnID != id || r.Items[0].OrderItemID != itemIDs[0] || r.Items[1].Quantity != 2 {
+			t.Errorf("unexpected return items %+v", r.Items)
+		}
+		if len(r.Events) != 2 || r.Events[0].Action != types.ReturnEventRequested || r.Events[1].Action != types.ReturnEventApproved || r.Events[0].ActorID != userID || r.Events[0].CreatedAt.IsZero() {
+			t.Errorf("unexpected return events %+v", r.Events)
+		}
+	})
+
+	t.Run("should approve a return, restock it and refund its payment at once", func(t *testing.T) {
+		s := newStores(t)
+		userID, orderID, itemIDs := setup(t, s, "buyer@example.com")
+		items, err := s.Orders.GetOrderItems(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mugID := items[0].ProductID
+
+		teeID, err := s.Products.CreateProduct(ctx, types.Product{Name: "tee", Price: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		smallID, err := s.Variants.CreateVariant(ctx, types.Variant{ProductID: teeID, SKU: "TEE-S", Quantity: 3, Options: []types.VariantOption{{Name: "size", Value: "S"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paymentID, err := s.Payments.CreatePayment(ctx, types.Payment{OrderID: orderID, Provider: "fake", Reference: "fake_1", Status: types.PaymentCaptured, Amount: 15})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, err := s.Returns.CreateReturn(ctx, types.Return{OrderID: orderID, UserID: userID, Reason: "broken", Items: []types.ReturnItem{{OrderItemID: itemIDs[1], Quantity: 2}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		refunded, err := s.Returns.ClaimRefund(ctx, id, paymentID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refunded != 10 {
+			t.Errorf("expected 10.00 refunded, got %.2f", refunded)
+		}
+		if _, err := s.Returns.ClaimRefund(ctx, id, paymentID, 10); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict claiming a return twice, got %v", err)
+		}
+
+		err = s.Returns.ApproveReturn(ctx, types.ReturnApproval{
+			ReturnID:      id,
+			PaymentID:     paymentID,
+			PaymentStatus: types.PaymentCaptured,
+			Restock:       []types.CartItem{{ProductID: mugID, Quantity: 2}, {ProductID: teeID, VariantID: smallID, Quantity: 1}},
+			OrderID:       orderID,
+			OrderStatus:   types.OrderPartiallyRefunded,
+			Events: []types.ReturnEvent{
+				{ReturnID: id, ActorID: userID, Action: types.ReturnEventApproved},
+				{ReturnID: id, ActorID: userID, Action: types.ReturnEventRefunded, Detail: "10.00"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := s.Returns.GetReturn(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Status != types.ReturnApproved || r.Refund != 10 || len(r.Events) != 2 || r.Events[1].Action != types.ReturnEventRefunded {
+			t.Errorf("unexpected return %+v", r)
+		}
+		payments, err := s.Payments.GetPaymentsByOrder(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(payments) != 1 || payments[0].Refunded != 10 || payments[0].Status != types.PaymentCaptured {
+			t.Errorf("expected a payment with 10.00 refunded, got %+v", payments)
+		}
+		ps, err := s.Products.GetProductsByID(ctx, []int{mugID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps[0].Quantity != 12 {
+			t.Errorf("expected 12 mugs in stock, got %d", ps[0].Quantity)
+		}
+		variants, err := s.Variants.GetVariantsByProduct(ctx, []int{teeID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(variants) != 1 || variants[0].Quantity != 4 {
+			t.Errorf("expected 4 small tees in stock, got %+v", variants)
+		}
+		o, err := s.Orders.GetOrderByID(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Status != types.OrderPartiallyRefunded {
+			t.Errorf("expected a partially refunded order, got %s", o.Status)
+		}
+	})
+
+	t.Run("should refuse refunds over what's left of the payment", func(t *testing.T) {
+		s := newStores(t)
+		userID, orderID, itemIDs := setup(t, s, "buyer@example.com")
+		paymentID, err := s.Payments.CreatePayment(ctx, types.Payment{OrderID: orderID, Provider: "fake", Reference: "fake_1", Status: types.PaymentCaptured, Amount: 15})
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, err := s.Returns.CreateReturn(ctx, types.Return{OrderID: orderID, UserID: userID, Reason: "a", Items: []types.ReturnItem{{OrderItemID: itemIDs[0], Quantity: 1}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := s.Returns.CreateReturn(ctx, types.Return{OrderID: orderID, UserID: userID, Reason: "b", Items: []types.ReturnItem{{OrderItemID: itemIDs[1], Quantity: 2}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.Returns.ClaimRefund(ctx, first, paymentID, 10); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Returns.ClaimRefund(ctx, second, paymentID, 10); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict refunding more than was paid, got %v", err)
+		}
+		r, err := s.Returns.GetReturn(ctx, second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Status != types.ReturnRequested {
+			t.Errorf("expected the refused return to stay requested, got %s", r.Status)
+		}
+
+		// Releasing a claim gives the refund back.
+		if err := s.Returns.ReleaseRefund(ctx, first, paymentID, 10); err != nil {
+			t.Fatal(err)
+		}
+		refunded, err := s.Returns.ClaimRefund(ctx, second, paymentID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refunded != 10 {
+			t.Errorf("expected 10.00 refunded, got %.2f", refunded)
+		}
+		r, err = s.Returns.GetReturn(ctx, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Status != types.ReturnRequested || r.Refund != 0 {
+			t.Errorf("expected the released return to be requested again, got %+v", r)
+		}
+	})
+
+	t.Run("should refuse returning more than was ordered", func(t *testing.T) {
+		s := newStores(t)
+		userID, orderID, itemIDs := setup(t, s, "buyer@example.com")
+		otherID, _, otherItemIDs := setup(t, s, "other@example.com")
+
+		create := func(items ...types.ReturnItem) (int, error) {
+			return s.Returns.CreateReturn(ctx, types.Return{OrderID: orderID, UserID: userID, Reason: "broken", Items: items})
+		}
+
+		first, err := create(types.ReturnItem{OrderItemID: itemIDs[1], Quantity: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := create(types.ReturnItem{OrderItemID: itemIDs[1], Quantity: 2}); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("expected ErrValidation returning more than is left, got %v", err)
+		}
+		if _, err := create(types.ReturnItem{OrderItemID: itemIDs[0], Quantity: 1}, types.ReturnItem{OrderItemID: itemIDs[0], Quantity: 1}); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("expected ErrValidation returning an item twice over, got %v", err)
+		}
+		if _, err := create(types.ReturnItem{OrderItemID: otherItemIDs[0], Quantity: 1}); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("expected ErrValidation returning user %d's item, got %v", otherID, err)
+		}
+
+		// Rejected returns give their items back.
+		if err := s.Returns.SetReturnStatus(ctx, first, types.ReturnRequested, types.ReturnRejected); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := create(types.ReturnItem{OrderItemID: itemIDs[1], Quantity: 2}); err != nil {
+			t.Errorf("expected the rejected items to be returnable again, got %v", err)
+		}
+	})
+
+	t.Run("should list returns by user, order and status, newest first", func(t *testing.T) {
+		s := newStores(t)
+		userID, orderID, itemIDs := setup(t, s, "buyer@example.com")
+		otherID, otherOrderID, otherItemIDs := setup(t, s, "other@example.com")
+
+		first, err := s.Returns.CreateReturn(ctx, types.Return{OrderID: orderID, UserID: userID, Reason: "a", Items: []types.ReturnItem{{OrderItemID: itemIDs[0], Quantity: 1}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Returns.CreateReturn(ctx, types.Return{OrderID: otherOrderID, UserID: otherID, Reason: "b", Items: []types.ReturnItem{{OrderItemID: otherItemIDs[0], Quantity: 1}}}); err != nil {
+			t.Fatal(err)
+		}
+		second, err := s.Returns.CreateReturn(ctx, types.Return{OrderID: orderID, UserID: userID, Reason: "c", Items: []types.ReturnItem{{OrderItemID: itemIDs[1], Quantity: 1}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Returns.SetReturnStatus(ctx, second, types.ReturnRequested, types.ReturnRejected); err != nil {
+			t.Fatal(err)
+		}
+
+		returns, err := s.Returns.ListReturns(ctx, types.ReturnFilter{UserID: userID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(returns) != 2 || returns[0].ID != second || returns[1].ID != first {
+			t.Fatalf("unexpected returns %+v", returns)
+		}
+		if len(returns[1].Items) != 1 || returns[1].Items[0].OrderItemID != itemIDs[0] {
+			t.Errorf("unexpected return items %+v", returns[1].Items)
+		}
+
+		returns, err = s.Returns.ListReturns(ctx, types.ReturnFilter{OrderID: orderID, Status: types.ReturnRequested})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(returns) != 1 || returns[0].ID != first {
+			t.Errorf("expected only the requested return, got %+v", returns)
+		}
+
+		returns, err = s.Returns.ListReturns(ctx, types.ReturnFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(returns) != 3 {
+			t.Errorf("expected every return, got %+v", returns)
+		}
+	})
+
+	t.Run("should return not found for unknown returns", func(t *testing.T) {
+		s := newStores(t)
+
+		if _, err := s.Returns.GetReturn(ctx, 42); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+		returns, err := s.Returns.ListReturns(ctx, types.ReturnFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if returns == nil || len(returns) != 0 {
+			t.Errorf("expected an empty list, got %#v", returns)
+		}
+	})
+}
+
+func testPromotionStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+
+	// newUser creates a user and returns its ID.
+	newUser := func(t *testing.T, s Stores, email string) int {
+		t.Helper()
+
+		if err := s.Users.CreateUser(ctx, types.User{FirstName: "A", LastName: "B", Email: email, Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.Users.GetUserByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.ID
+	}
+
+	// order places an order of a user using promotion.
+	order := func(s Stores, userID int, promotion types.Promotion) error {
+		_, err := s.Orders.CreateOrder(ctx, types.Order{
+			UserID:    userID,
+			Total:     9,
+			Status:    types.OrderPending,
+			Address:   "1 Main St",
+			Discounts: []types.OrderDiscount{{PromotionID: promotion.ID, Name: promotion.Name, Code: promotion.Code, Amount: 1}},
+		})
+		return err
+	}
+
+	t.Run("should create and get promotions by code", func(t *testing.T) {
+		s := newStores(t)
+
+		productID, err := s.Products.CreateProduct(ctx, types.Product{Name: "mug", Price: 5, Quantity: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		categoryID, err := s.Categories.CreateCategory(ctx, types.Category{Name: "Kitchen", Slug: "kitchen"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		starts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		ends := time.Date(2024, 2, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+		want := types.Promotion{
+			Name: "Winter sale", Code: "WINTER", Kind: types.PromotionPercent, Value: 15, MinSpend: 20,
+			MaxUses: 100, MaxUsesPerUser: 1, Stackable: true, StartsAt: &starts, EndsAt: &ends,
+			ProductIDs: []int{productID}, CategoryIDs: []int{categoryID},
+		}
+		id, err := s.Promotions.CreatePromotion(ctx, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := s.Promotions.GetPromotionByCode(ctx, "WINTER")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ID != id || p.Name != want.Name || p.Kind != want.Kind || p.Value != 15 || p.MinSpend != 20 ||
+			p.MaxUses != 100 || p.MaxUsesPerUser != 1 || p.Uses != 0 || !p.Stackable || p.CreatedAt.IsZero() {
+			t.Errorf("unexpected promotion %+v", p)
+		}
+		if p.StartsAt == nil || !p.StartsAt.Equal(starts) || p.EndsAt == nil || !p.EndsAt.Equal(ends) {
+			t.Errorf("expected the validity window %v to %v, got %v to %v", starts, ends, p.StartsAt, p.EndsAt)
+		}
+		if len(p.ProductIDs) != 1 || p.ProductIDs[0] != productID {
+			t.Errorf("expected the promotion to be limited to product %d, got %v", productID, p.ProductIDs)
+		}
+		if len(p.CategoryIDs) != 1 || p.CategoryIDs[0] != categoryID {
+			t.Errorf("expected the promotion to be limited to category %d, got %v", categoryID, p.CategoryIDs)
+		}
+
+		if _, err := s.Promotions.GetPromotionByCode(ctx, "SUMMER"); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+		if _, err := s.Promotions.CreatePromotion(ctx, types.Promotion{Name: "Again", Code: "WINTER", Kind: types.PromotionFixed, Value: 5}); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict for a duplicate code, got %v", err)
+		}
+	})
+
+	t.Run("should list automatic promotions apart", func(t *testing.T) {
+		s := newStores(t)
+
+		for _, p := range []types.Promotion{
+			{Name: "Everything 10% off", Kind: types.PromotionPercent, Value: 10},
+			{Name: "Coupon", Code: "TAKE5", Kind: types.PromotionFixed, Value: 5},
+			{Name: "Free gift", Kind: types.PromotionFixed, Value: 1},
+		} {
+			if _, err := s.Promotions.CreatePromotion(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		automatic, err := s.Promotions.GetAutomaticPromotions(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(automatic) != 2 || automatic[0].Name != "Everything 10% off" || automatic[1].Name != "Free gift" || automatic[0].Code != "" {
+			t.Errorf("unexpected automatic promotions %+v", automatic)
+		}
+
+		all, err := s.Promotions.ListPromotions(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 3 || all[1].Code != "TAKE5" || len(all[0].ProductIDs) != 0 {
+			t.Errorf("unexpected promotions %+v", all)
+		}
+	})
+
+	t.Run("should record discounts and count uses with the order", func(t *testing.T) {
+		s := newStores(t)
+		userID := newUser(t, s, "buyer@example.com")
+
+		id, err := s.Promotions.CreatePromotion(ctx, types.Promotion{Name: "Take 1", Code: "TAKE1", Kind: types.PromotionFixed, Value: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := s.Promotions.GetPromotionByCode(ctx, "TAKE1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		orderID, err := s.Orders.CreateOrder(ctx, types.Order{
+			UserID:    userID,
+			Total:     9,
+			Status:    types.OrderPending,
+			Address:   "1 Main St",
+			Discounts: []types.OrderDiscount{{PromotionID: id, Name: "Take 1", Code: "TAKE1", Amount: 1}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		discounts, err := s.Orders.GetOrderDiscounts(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(discounts) != 1 || discounts[0].ID == 0 || discounts[0].OrderID != orderID || discounts[0].PromotionID != id ||
+			discounts[0].Name != "Take 1" || discounts[0].Code != "TAKE1" || discounts[0].Amount != 1 {
+			t.Errorf("unexpected discounts %+v", discounts)
+		}
+
+		if err := order(s, userID, *p); err != nil {
+			t.Fatal(err)
+		}
+		p, err = s.Promotions.GetPromotionByCode(ctx, "TAKE1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Uses != 2 {
+			t.Errorf("expected 2 uses, got %d", p.Uses)
+		}
+		n, err := s.Promotions.CountUserRedemptions(ctx, id, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 redemptions, got %d", n)
+		}
+	})
+
+	t.Run("should refuse orders over the usage limits", func(t *testing.T) {
+		s := newStores(t)
+		buyer := newUser(t, s, "buyer@example.com")
+		other := newUser(t, s, "other@example.com")
+
+		for _, p := range []types.Promotion{
+			{Name: "Once each", Code: "ONCE", Kind: types.PromotionFixed, Value: 1, MaxUsesPerUser: 1},
+			{Name: "Twice", Code: "TWICE", Kind: types.PromotionFixed, Value: 1, MaxUses: 2},
+		} {
+			if _, err := s.Promotions.CreatePromotion(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		once, err := s.Promotions.GetPromotionByCode(ctx, "ONCE")
+		if err != nil {
+			t.Fatal(err)
+		}
+		twice, err := s.Promotions.GetPromotionByCode(ctx, "TWICE")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := order(s, buyer, *once); err != nil {
+			t.Fatal(err)
+		}
+		if err := order(s, buyer, *once); !errors.Is(err, types.ErrCoupon) {
+			t.Errorf("expected ErrCoupon for a second use by the same user, got %v", err)
+		}
+		if err := order(s, other, *once); err != nil {
+			t.Errorf("expected another user to use the promotion, got %v", err)
+		}
+
+		for _, userID := range []int{buyer, other} {
+			if err := order(s, userID, *twice); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := order(s, buyer, *twice); !errors.Is(err, types.ErrCoupon) {
+			t.Errorf("expected ErrCoupon once the promotion is used up, got %v", err)
+		}
+
+		// The refused orders were rolled back with their uses.
+		var orders int
+		err = s.Orders.StreamOrdersByUser(ctx, buyer, func(types.Order) error {
+			orders++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if orders != 2 {
+			t.Errorf("expected 2 orders, got %d", orders)
+		}
+		twice, err = s.Promotions.GetPromotionByCode(ctx, "TWICE")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if twice.Uses != 2 {
+			t.Errorf("expected 2 uses, got %d", twice.Uses)
+		}
+	})
+
+	t.Run("should give back the uses of failed orders", func(t *testing.T) {
+		s := newStores(t)
+		buyer := newUser(t, s, "buyer@example.com")
+
+		id, err := s.Promotions.CreatePromotion(ctx, types.Promotion{Name: "Once", Code: "ONCE", Kind: types.PromotionFixed, Value: 1, MaxUses: 1, MaxUsesPerUser: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		once, err := s.Promotions.GetPromotionByCode(ctx, "ONCE")
+		if err != nil {
+			t.Fatal(err)
+		}
+		orderID, err := s.Orders.CreateOrder(ctx, types.Order{
+			UserID:    buyer,
+			Total:     9,
+			Status:    types.OrderPending,
+			Address:   "1 Main St",
+			Discounts: []types.OrderDiscount{{PromotionID: id, Name: once.Name, Code: once.Code, Amount: 1}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Failing an order twice gives its uses back once.
+		for range 2 {
+			if err := s.Orders.UpdateOrderStatus(ctx, orderID, types.OrderFailed); err != nil {
+				t.Fatal(err)
+			}
+		}
+		once, err = s.Promotions.GetPromotionByCode(ctx, "ONCE")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if once.Uses != 0 {
+			t.Errorf("expected no uses, got %d", once.Uses)
+		}
+		n, err := s.Promotions.CountUserRedemptions(ctx, id, buyer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("expected no redemptions, got %d", n)
+		}
+
+		if err := order(s, buyer, *once); err != nil {
+			t.Errorf("expected the promotion to be used again, got %v", err)
+		}
+	})
+}
+
+func testShippingStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+
+	t.Run("should create and list shipping methods", func(t *testing.T) {
+		s := newStores(t)
+
+		methods, err := s.Shipping.ListShippingMethods(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if methods == nil || len(methods) != 0 {
+			t.Errorf("expected an empty non-nil list, got %#v", methods)
+		}
+
+		want := types.ShippingMethod{Name: "Express", Kind: types.ShippingWeight, Price: 9, PricePerKg: 2.5, FreeOver: 150, MaxWeight: 30.5, Countries: []string{"us", "CA"}}
+		id, err := s.Shipping.CreateShippingMethod(ctx, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Shipping.CreateShippingMethod(ctx, types.ShippingMethod{Name: "Pickup", Kind: types.ShippingPickup}); err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := s.Shipping.GetShippingMethodByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.ID != id || m.Name != "Express" || m.Kind != types.ShippingWeight || m.Price != 9 || m.PricePerKg != 2.5 ||
+			m.FreeOver != 150 || m.MaxWeight != 30.5 || len(m.Countries) != 2 || m.Countries[0] != "US" || m.Countries[1] != "CA" || m.CreatedAt.IsZero() {
+			t.Errorf("unexpected shipping method %+v", m)
+		}
+
+		methods, err = s.Shipping.ListShippingMethods(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(methods) != 2 || methods[0].ID != id || methods[1].Name != "Pickup" || methods[1].Countries == nil || len(methods[1].Countries) != 0 {
+			t.Errorf("unexpected shipping methods %+v", methods)
+		}
+	})
+
+	t.Run("should return not found for unknown shipping methods", func(t *testing.T) {
+		s := newStores(t)
+
+		if _, err := s.Shipping.GetShippingMethodByID(ctx, 42); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func testInventoryStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+	hour := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// setup creates a pending order and two products with 5 and 2 in
+	// stock, and returns the order's ID and the products' IDs.
+	setup := func(t *testing.T, s Stores) (int, int, int) {
+		t.Helper()
+
+		if err := s.Users.CreateUser(ctx, types.User{FirstName: "A", LastName: "B", Email: "a@example.com", Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.Users.GetUserByEmail(ctx, "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		orderID, err := s.Orders.CreateOrder(ctx, types.Order{UserID: u.ID, Total: 10, Status: types.OrderPending, Address: "1 Main St"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mugID, err := s.Products.CreateProduct(ctx, types.Product{Name: "mug", Price: 5, Quantity: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		teeID, err := s.Products.CreateProduct(ctx, types.Product{Name: "tee", Price: 20, Quantity: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return orderID, mugID, teeID
+	}
+
+	// stock returns the quantity and the available stock of a product.
+	stock := func(t *testing.T, s Stores, productID int) (int, int) {
+		t.Helper()
+
+		ps, err := s.Products.GetProductsByID(ctx, []int{productID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ps) != 1 {
+			t.Fatalf("expected product %d, got %+v", productID, ps)
+		}
+		return ps[0].Quantity, ps[0].Available
+	}
+
+	t.Run("should hold stock until the reservations are committed", func(t *testing.T) {
+		s := newStores(t)
+		orderID, mugID, teeID := setup(t, s)
+
+		items := []types.CartItem{{ProductID: mugID, Quantity: 1}, {ProductID: teeID, Quantity: 2}, {ProductID: mugID, Quantity: 2}}
+		if err := s.Inventory.ReserveStock(ctx, orderID, items, hour); err != nil {
+			t.Fatal(err)
+		}
+
+		reservations, err := s.Inventory.GetReservations(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reservations) != 2 {
+			t.Fatalf("expected a reservation per product, got %+v", reservations)
+		}
+		for _, r := range reservations {
+			if r.ID == 0 || r.OrderID != orderID || r.Status != types.ReservationActive || !r.ExpiresAt.Equal(hour) || r.CreatedAt.IsZero() {
+				t.Errorf("unexpected reservation %+v", r)
+			}
+		}
+		if q, a := stock(t, s, mugID); q != 5 || a != 2 {
+			t.Errorf("expected 5 mugs with 2 available, got %d and %d", q, a)
+		}
+
+		if err := s.Inventory.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: mugID, Quantity: 1}, {ProductID: teeID, Quantity: 1}}, hour); !errors.Is(err, types.ErrOutOfStock) {
+			t.Errorf("expected ErrOutOfStock, got %v", err)
+		}
+		if q, a := stock(t, s, mugID); q != 5 || a != 2 {
+			t.Errorf("expected a failed reservation to hold nothing, got %d and %d", q, a)
+		}
+
+		if err := s.Inventory.CommitReservations(ctx, orderID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Inventory.CommitReservations(ctx, orderID); err != nil {
+			t.Fatalf("expected committing twice to do nothing, got %v", err)
+		}
+		if q, a := stock(t, s, mugID); q != 2 || a != 2 {
+			t.Errorf("expected 2 mugs left, got %d and %d available", q, a)
+		}
+		if q, a := stock(t, s, teeID); q != 0 || a != 0 {
+			t.Errorf("expected no tees left, got %d and %d available", q, a)
+		}
+	})
+
+	t.Run("should release reservations", func(t *testing.T) {
+		s := newStores(t)
+		orderID, mugID, _ := setup(t, s)
+
+		if err := s.Inventory.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: mugID, Quantity: 4}}, hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Inventory.ReleaseReservations(ctx, orderID); err != nil {
+			t.Fatal(err)
+		}
+		if q, a := stock(t, s, mugID); q != 5 || a != 5 {
+			t.Errorf("expected the mugs back in stock, got %d and %d available", q, a)
+		}
+		if err := s.Inventory.CommitReservations(ctx, orderID); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict committing released reservations, got %v", err)
+		}
+
+		reservations, err := s.Inventory.GetReservations(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reservations) != 1 || reservations[0].Status != types.ReservationReleased {
+			t.Errorf("expected a released reservation, got %+v", reservations)
+		}
+	})
+
+	t.Run("should commit a paid order in one transaction", func(t *testing.T) {
+		// status returns the status of the order and of its payment.
+		status := func(t *testing.T, s Stores, orderID int) (string, string) {
+			t.Helper()
+
+			o, err := s.Orders.GetOrderByID(ctx, orderID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payments, err := s.Payments.GetPaymentsByOrder(ctx, orderID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(payments) != 1 {
+				t.Fatalf("expected a payment, got %+v", payments)
+			}
+			return o.Status, payments[0].Status
+		}
+
+		s := newStores(t)
+		orderID, mugID, _ := setup(t, s)
+		paymentID, err := s.Payments.CreatePayment(ctx, types.Payment{OrderID: orderID, Provider: "fake", Reference: "fake_1", Status: types.PaymentAuthorized, Amount: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Inventory.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: mugID, Quantity: 2}}, hour); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Inventory.CommitPaidOrder(ctx, orderID, paymentID); err != nil {
+			t.Fatal(err)
+		}
+		if o, p := status(t, s, orderID); o != types.OrderPaid || p != types.PaymentCaptured {
+			t.Errorf("expected a paid order and a captured payment, got %s and %s", o, p)
+		}
+		if q, a := stock(t, s, mugID); q != 3 || a != 3 {
+			t.Errorf("expected 3 mugs left, got %d and %d available", q, a)
+		}
+
+		// An order whose stock was released changes nothing.
+		s = newStores(t)
+		orderID, mugID, _ = setup(t, s)
+		paymentID, err = s.Payments.CreatePayment(ctx, types.Payment{OrderID: orderID, Provider: "fake", Reference: "fake_1", Status: types.PaymentAuthorized, Amount: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Inventory.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: mugID, Quantity: 2}}, hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Inventory.ReleaseReservations(ctx, orderID); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Inventory.CommitPaidOrder(ctx, orderID, paymentID); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict, got %v", err)
+		}
+		if o, p := status(t, s, orderID); o != types.OrderPending || p != types.PaymentAuthorized {
+			t.Errorf("expected a pending order and an authorized payment, got %s and %s", o, p)
+		}
+		if q, a := stock(t, s, mugID); q != 5 || a != 5 {
+			t.Errorf("expected 5 mugs, got %d and %d available", q, a)
+		}
+	})
+
+	t.Run("should release expired reservations", func(t *testing.T) {
+		s := newStores(t)
+		orderID, mugID, teeID := setup(t, s)
+
+		if err := s.Inventory.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: mugID, Quantity: 1}, {ProductID: teeID, Quantity: 1}}, hour); err != nil {
+			t.Fatal(err)
+		}
+
+		orderIDs, err := s.Inventory.ReleaseExpiredReservations(ctx, hour.Add(-time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(orderIDs) != 0 {
+			t.Errorf("expected nothing to expire yet, got %v", orderIDs)
+		}
+
+		orderIDs, err = s.Inventory.ReleaseExpiredReservations(ctx, hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(orderIDs) != 1 || orderIDs[0] != orderID {
+			t.Errorf("expected order %d to expire, got %v", orderID, orderIDs)
+		}
+		if _, a := stock(t, s, mugID); a != 5 {
+			t.Errorf("expected 5 mugs available, got %d", a)
+		}
+	})
+
+	t.Run("should return not found for unknown products", func(t *testing.T) {
+		s := newStores(t)
+		orderID, _, _ := setup(t, s)
+
+		if err := s.Inventory.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: 42, Quantity: 1}}, hour); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+		reservations, err := s.Inventory.GetReservations(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reservations == nil || len(reservations) != 0 {
+			t.Errorf("expected an empty list, got %#v", reservations)
+		}
+	})
+}
+
+func testVariantStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+	hour := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	large := 25.0
+
+	// setup creates a tee with a small red variant, at the tee's price,
+	// and a large blue one with its own price, and returns their IDs.
+	setup := func(t *testing.T, s Stores) (int, int, int) {
+		t.Helper()
+
+		teeID, err := s.Products.CreateProduct(ctx, types.Product{Name: "tee", Price: 20, Quantity: 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		smallID, err := s.Variants.CreateVariant(ctx, types.Variant{ProductID: teeID, SKU: "TEE-S-RED", Quantity: 3, Image: "tee-red.jpg",
+			Options: []types.VariantOption{{Name: "size", Value: "S"}, {Name: "color", Value: "red"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		largeID, err := s.Variants.CreateVariant(ctx, types.Variant{ProductID: teeID, SKU: "TEE-L-BLUE", Price: &large, Quantity: 1,
+			Options: []types.VariantOption{{Name: "color", Value: "blue"}, {Name: "size", Value: "L"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return teeID, smallID, largeID
+	}
+
+	t.Run("should create variants and get the option matrix", func(t *testing.T) {
+		s := newStores(t)
+		teeID, smallID, largeID := setup(t, s)
+
+		variants, err := s.Variants.GetVariantsByProduct(ctx, []int{teeID, teeID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(variants) != 2 || variants[0].ID != smallID || variants[1].ID != largeID {
+			t.Fatalf("expected the two variants, got %+v", variants)
+		}
+		small, big := variants[0], variants[1]
+		if small.ProductID != teeID || small.SKU != "TEE-S-RED" || small.Price != nil || small.Quantity != 3 || small.Available != 3 || small.Image != "tee-red.jpg" || small.CreatedAt.IsZero() {
+			t.Errorf("unexpected variant %+v", small)
+		}
+		if big.Price == nil || *big.Price != 25 || big.Available != 1 {
+			t.Errorf("unexpected variant %+v", big)
+		}
+		// Options come in the product's order, whatever order they were
+		// given in.
+		wantOptions := []types.VariantOption{{Name: "size", Value: "L"}, {Name: "color", Value: "blue"}}
+		if !reflect.DeepEqual(big.Options, wantOptions) {
+			t.Errorf("expected options %+v, got %+v", wantOptions, big.Options)
+		}
+
+		// More IDs than a single query takes.
+		many := []int{teeID}
+		for id := 10000; id < 11200; id++ {
+			many = append(many, id)
+		}
+		variants, err = s.Variants.GetVariantsByProduct(ctx, many)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(variants) != 2 || variants[0].ID != smallID || len(variants[1].Options) != 2 {
+			t.Errorf("unexpected variants of %d products: %+v", len(many), variants)
+		}
+
+		options, err := s.Variants.GetProductOptions(ctx, teeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []types.ProductOption{{Name: "size", Values: []string{"S", "L"}}, {Name: "color", Values: []string{"red", "blue"}}}
+		if !reflect.DeepEqual(options, want) {
+			t.Errorf("expected options %+v, got %+v", want, options)
+		}
+
+		ps, err := s.Products.GetProductsByID(ctx, []int{teeID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ps) != 1 {
+			t.Fatalf("expected the tee, got %+v", ps)
+		}
+		if got := small.PriceOf(ps[0]); got != 20 {
+			t.Errorf("expected the small tee at the tee's price, got %v", got)
+		}
+		if got := big.PriceOf(ps[0]); got != 25 {
+			t.Errorf("expected the large tee at its own price, got %v", got)
+		}
+	})
+
+	t.Run("should list no variants or options for plain products", func(t *testing.T) {
+		s := newStores(t)
+		mugID, err := s.Products.CreateProduct(ctx, types.Product{Name: "mug", Price: 5, Quantity: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		variants, err := s.Variants.GetVariantsByProduct(ctx, []int{mugID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if variants == nil || len(variants) != 0 {
+			t.Errorf("expected an empty list, got %#v", variants)
+		}
+		options, err := s.Variants.GetProductOptions(ctx, mugID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if options == nil || len(options) != 0 {
+			t.Errorf("expected an empty list, got %#v", options)
+		}
+	})
+
+	t.Run("should refuse conflicting variants", func(t *testing.T) {
+		s := newStores(t)
+		teeID, _, _ := setup(t, s)
+
+		tests := []struct {
+			name    string
+			variant types.Variant
+			want    error
+		}{
+			{"unknown product", types.Variant{ProductID: 42, SKU: "X", Options: []types.VariantOption{{Name: "size", Value: "S"}}}, types.ErrNotFound},
+			{"duplicate SKU", types.Variant{ProductID: teeID, SKU: "TEE-S-RED", Options: []types.VariantOption{{Name: "size", Value: "M"}, {Name: "color", Value: "red"}}}, types.ErrConflict},
+			{"same options", types.Variant{ProductID: teeID, SKU: "TEE-S-RED-2", Options: []types.VariantOption{{Name: "color", Value: "red"}, {Name: "size", Value: "S"}}}, types.ErrConflict},
+			{"missing option", types.Variant{ProductID: teeID, SKU: "TEE-M", Options: []types.VariantOption{{Name: "size", Value: "M"}}}, types.ErrValidation},
+			{"other option", types.Variant{ProductID: teeID, SKU: "TEE-M-COTTON", Options: []types.VariantOption{{Name: "size", Value: "M"}, {Name: "fabric", Value: "cotton"}}}, types.ErrValidation},
+			{"option twice", types.Variant{ProductID: teeID, SKU: "TEE-M-M", Options: []types.VariantOption{{Name: "size", Value: "M"}, {Name: "size", Value: "L"}}}, types.ErrValidation},
+		}
+		for _, tt := range tests {
+			if _, err := s.Variants.CreateVariant(ctx, tt.variant); !errors.Is(err, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+			}
+		}
+
+		variants, err := s.Variants.GetVariantsByProduct(ctx, []int{teeID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(variants) != 2 {
+			t.Errorf("expected refused variants not to be created, got %+v", variants)
+		}
+	})
+
+	t.Run("should update a variant", func(t *testing.T) {
+		s := newStores(t)
+		teeID, smallID, largeID := setup(t, s)
+
+		variants, err := s.Variants.GetVariantsByProduct(ctx, []int{teeID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		small := variants[0]
+		small.SKU = "TEE-S-CRIMSON"
+		small.Price = &large
+		small.Quantity = 7
+		if err := s.Variants.UpdateVariant(ctx, small); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Variants.UpdateVariant(ctx, types.Variant{ID: largeID, SKU: "TEE-S-CRIMSON"}); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict for a taken SKU, got %v", err)
+		}
+
+		variants, err = s.Variants.GetVariantsByProduct(ctx, []int{teeID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := variants[0]; v.ID != smallID || v.SKU != "TEE-S-CRIMSON" || v.Price == nil || *v.Price != 25 || v.Quantity != 7 || len(v.Options) != 2 {
+			t.Errorf("unexpected variant %+v", v)
+		}
+	})
+
+	t.Run("should hold and take the stock of variants", func(t *testing.T) {
+		s := newStores(t)
+		teeID, smallID, largeID := setup(t, s)
+		if err := s.Users.CreateUser(ctx, types.User{FirstName: "A", LastName: "B", Email: "a@example.com", Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.Users.GetUserByEmail(ctx, "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		orderID, err := s.Orders.CreateOrder(ctx, types.Order{UserID: u.ID, Total: 10, Status: types.OrderPending, Address: "1 Main St"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Inventory.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: teeID, VariantID: largeID, Quantity: 2}}, hour); !errors.Is(err, types.ErrOutOfStock) {
+			t.Errorf("expected ErrOutOfStock, got %v", err)
+		}
+		if err := s.Inventory.ReserveStock(ctx, orderID, []types.CartItem{{ProductID: teeID + 1, VariantID: smallID, Quantity: 1}}, hour); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for a variant of another product, got %v", err)
+		}
+
+		items := []types.CartItem{{ProductID: teeID, VariantID: smallID, Quantity: 2}, {ProductID: teeID, VariantID: largeID, Quantity: 1}}
+		if err := s.Inventory.ReserveStock(ctx, orderID, items, hour); err != nil {
+			t.Fatal(err)
+		}
+		reservations, err := s.Inventory.GetReservations(ctx, orderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reservations) != 2 || reservations[0].VariantID == 0 || reservations[1].VariantID == 0 {
+			t.Errorf("expected a reservation per variant, got %+v", reservations)
+		}
+
+		variants, err := s.Variants.GetVariantsByProduct(ctx, []int{teeID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if variants[0].Available != 1 || variants[1].Available != 0 {
+			t.Errorf("expected 1 small and no large tee available, got %+v", variants)
+		}
+
+		if err := s.Inventory.CommitReservations(ctx, orderID); err != nil {
+			t.Fatal(err)
+		}
+		variants, err = s.Variants.GetVariantsByProduct(ctx, []int{teeID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := variants[0]; v.Quantity != 1 || v.Available != 1 {
+			t.Errorf("expected 1 small tee left, got %+v", v)
+		}
+		if v := variants[1]; v.Quantity != 0 || v.Available != 0 {
+			t.Errorf("expected no large tee left, got %+v", v)
+		}
+	})
+}
+
+func testCategoryStore(t *testing.T, newStores func(t *testing.T) Stores) {
+	ctx := context.Background()
+
+	// create creates a category and returns its ID.
+	create := func(t *testing.T, s Stores, c types.Category) int {
+		t.Helper()
+
+		id, err := s.Categories.CreateCategory(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	// names streams the names of the products filter matches.
+	names := func(t *testing.T, s Stores, filter types.ProductFilter) []string {
+		t.Helper()
+
+		names := []string{}
+		err := s.Products.StreamProducts(ctx, filter, func(p types.Product) error {
+			names = append(names, p.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+
+	t.Run("should create, list, update and delete categories", func(t *testing.T) {
+		s := newStores(t)
+		kitchenID := create(t, s, types.Category{Name: "Kitchen", Slug: "kitchen", SortOrder: 2})
+		apparelID := create(t, s, types.Category{Name: "Apparel", Slug: "apparel", SortOrder: 1})
+		mugsID := create(t, s, types.Category{ParentID: &kitchenID, Name: "Mugs", Slug: "mugs"})
+
+		categories, err := s.Categories.ListCategories(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(categories) != 3 || categories[0].ID != mugsID || categories[1].ID != apparelID || categories[2].ID != kitchenID {
+			t.Fatalf("expected the categories by sort order, got %+v", categories)
+		}
+		if c := categories[0]; c.ParentID == nil || *c.ParentID != kitchenID || c.Name != "Mugs" || c.Slug != "mugs" || c.CreatedAt.IsZero() {
+			t.Errorf("unexpected category %+v", c)
+		}
+		if categories[1].ParentID != nil {
+			t.Errorf("expected a top-level category, got %+v", categories[1])
+		}
+
+		if err := s.Categories.UpdateCategory(ctx, types.Category{ID: mugsID, ParentID: &apparelID, Name: "Cups", Slug: "cups", SortOrder: 3}); err != nil {
+			t.Fatal(err)
+		}
+		categories, err = s.Categories.ListCategories(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := categories[2]; c.ID != mugsID || *c.ParentID != apparelID || c.Name != "Cups" || c.Slug != "cups" || c.SortOrder != 3 {
+			t.Errorf("unexpected updated category %+v", c)
+		}
+
+		if err := s.Categories.DeleteCategory(ctx, apparelID); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict deleting a category with subcategories, got %v", err)
+		}
+		if err := s.Categories.DeleteCategory(ctx, mugsID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Categories.DeleteCategory(ctx, mugsID); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+		}
+	})
+
+	t.Run("should refuse invalid categories", func(t *testing.T) {
+		s := newStores(t)
+		kitchenID := create(t, s, types.Category{Name: "Kitchen", Slug: "kitchen"})
+		mugsID := create(t, s, types.Category{ParentID: &kitchenID, Name: "Mugs", Slug: "mugs"})
+		unknown := 42
+
+		if _, err := s.Categories.CreateCategory(ctx, types.Category{Name: "Kitchen", Slug: "kitchen"}); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict for a taken slug, got %v", err)
+		}
+		if _, err := s.Categories.CreateCategory(ctx, types.Category{ParentID: &unknown, Name: "Bowls", Slug: "bowls"}); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("expected ErrValidation for an unknown parent, got %v", err)
+		}
+		if err := s.Categories.UpdateCategory(ctx, types.Category{ID: kitchenID, ParentID: &mugsID, Name: "Kitchen", Slug: "kitchen"}); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("expected ErrValidation moving a category under its child, got %v", err)
+		}
+		if err := s.Categories.UpdateCategory(ctx, types.Category{ID: kitchenID, ParentID: &kitchenID, Name: "Kitchen", Slug: "kitchen"}); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("expected ErrValidation moving a category under itself, got %v", err)
+		}
+		if err := s.Categories.UpdateCategory(ctx, types.Category{ID: mugsID, Name: "Mugs", Slug: "kitchen"}); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict for a taken slug, got %v", err)
+		}
+		if err := s.Categories.UpdateCategory(ctx, types.Category{ID: unknown, Name: "Bowls", Slug: "bowls"}); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should create, list and delete tags", func(t *testing.T) {
+		s := newStores(t)
+		saleID, err := s.Categories.CreateTag(ctx, "sale")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Categories.CreateTag(ctx, "new"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Categories.CreateTag(ctx, "sale"); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict, got %v", err)
+		}
+
+		tags, err := s.Categories.ListTags(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tags) != 2 || tags[0].Name != "new" || tags[1].ID != saleID || tags[1].CreatedAt.IsZero() {
+			t.Errorf("expected the tags by name, got %+v", tags)
+		}
+
+		if err := s.Categories.RenameTag(ctx, saleID, "new"); !errors.Is(err, types.ErrConflict) {
+			t.Errorf("expected ErrConflict renaming to a taken name, got %v", err)
+		}
+		if err := s.Categories.RenameTag(ctx, saleID, "clearance"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Categories.RenameTag(ctx, 42, "other"); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+		tags, err = s.Categories.ListTags(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tags) != 2 || tags[0].ID != saleID || tags[0].Name != "clearance" {
+			t.Errorf("expected the renamed tag first, got %+v", tags)
+		}
+
+		if err := s.Categories.DeleteTag(ctx, saleID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Categories.DeleteTag(ctx, saleID); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+		}
+	})
+
+	t.Run("should link products and filter them by category and tags", func(t *testing.T) {
+		s := newStores(t)
+		kitchenID := create(t, s, types.Category{Name: "Kitchen", Slug: "kitchen"})
+		mugsID := create(t, s, types.Category{ParentID: &kitchenID, Name: "Mugs", Slug: "mugs"})
+		apparelID := create(t, s, types.Category{Name: "Apparel", Slug: "apparel"})
+
+		ids := map[string]int{}
+		for _, name := range []string{"mug", "kettle", "tee"} {
+			id, err := s.Products.CreateProduct(ctx, types.Product{Name: name, Price: 5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids[name] = id
+		}
+		links := map[string][]int{"mug": {mugsID, mugsID}, "kettle": {kitchenID}, "tee": {apparelID}}
+		for name, categoryIDs := range links {
+			if err := s.Categories.SetProductCategories(ctx, ids[name], categoryIDs); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tags := map[string][]string{"mug": {"sale", "new", "sale"}, "kettle": {"sale"}, "tee": {"new"}}
+		for name, names := range tags {
+			if err := s.Categories.SetProductTags(ctx, ids[name], names); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			filter types.ProductFilter
+			want   []string
+		}{
+			{types.ProductFilter{}, []string{"mug", "kettle", "tee"}},
+			{types.ProductFilter{CategoryIDs: []int{kitchenID, mugsID}}, []string{"mug", "kettle"}},
+			{types.ProductFilter{CategoryIDs: []int{mugsID}}, []string{"mug"}},
+			{types.ProductFilter{Tags: []string{"sale"}}, []string{"mug", "kettle"}},
+			{types.ProductFilter{Tags: []string{"sale", "new"}}, []string{"mug"}},
+			{types.ProductFilter{CategoryIDs: []int{apparelID}, Tags: []string{"sale"}}, []string{}},
+			{types.ProductFilter{Tags: []string{"unknown"}}, []string{}},
+		}
+		for _, tt := range tests {
+			if got := names(t, s, tt.filter); !slices.Equal(got, tt.want) {
+				t.Errorf("filter %+v: expected %v, got %v", tt.filter, tt.want, got)
+			}
+		}
+
+		categories, err := s.Categories.GetProductCategories(ctx, ids["mug"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(categories) != 1 || categories[0].ID != mugsID {
+			t.Errorf("expected the mug in mugs once, got %+v", categories)
+		}
+		byProduct, err := s.Categories.GetCategoryIDsByProduct(ctx, []int{ids["mug"], ids["kettle"], ids["mug"], 42})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(byProduct) != 2 || !slices.Equal(byProduct[ids["mug"]], []int{mugsID}) || !slices.Equal(byProduct[ids["kettle"]], []int{kitchenID}) {
+			t.Errorf("expected the mug in mugs and the kettle in kitchen, got %v", byProduct)
+		}
+		mugTags, err := s.Categories.GetProductTags(ctx, ids["mug"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(mugTags, []string{"new", "sale"}) {
+			t.Errorf("expected the mug's tags once each, got %v", mugTags)
+		}
+
+		// Replacing the links drops the old ones; deleting a category or
+		// a tag unlinks its products.
+		if err := s.Categories.SetProductTags(ctx, ids["mug"], nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(t, s, types.ProductFilter{Tags: []string{"new"}}); !slices.Equal(got, []string{"tee"}) {
+			t.Errorf("expected only the tee to be new, got %v", got)
+		}
+		if err := s.Categories.DeleteCategory(ctx, mugsID); err != nil {
+			t.Fatal(err)
+		}
+		categories, err = s.Categories.GetProductCategories(ctx, ids["mug"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(categories) != 0 {
+			t.Errorf("expected the mug in no category, got %+v", categories)
+		}
+	})
+
+	t.Run("should refuse links to unknown products and categories", func(t *testing.T) {
+		s := newStores(t)
+		mugID, err := s.Products.CreateProduct(ctx, types.Product{Name: "mug", Price: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Categories.SetProductCategories(ctx, 42, nil); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for an unknown product, got %v", err)
+		}
+		if err := s.Categories.SetProductTags(ctx, 42, []string{"sale"}); !errors.Is(err, types.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for an unknown product, got %v", err)
+		}
+		if err := s.Categories.SetProductCategories(ctx, mugID, []int{42}); !errors.Is(err, types.ErrValidation) {
+			t.Errorf("expected ErrValidation for an unknown category, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Domain error kinds. Stores and services return errors that wrap one of
+// these so handlers don't have to pick status codes by hand; netjson maps
+// each kind to a problem response.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrOutOfStock   = errors.New("out of stock")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrBadRequest   = errors.New("bad request")
+	ErrPayment      = errors.New("payment declined")
+	ErrCoupon       = errors.New("coupon not applicable")
+
+	ErrPayloadTooLarge      = errors.New("payload too large")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrNotAcceptable        = errors.New("not acceptable")
+)
+
+// Error : Domain error with a client-facing message
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap lets errors.Is match the error against its kind.
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// Errorf creates a domain error of the given kind with a formatted message.
+func Errorf(kind error, format string, args ...any) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// ItemError : Why checkout can't take an item of a cart
+type ItemError struct {
+	ProductID int
+	VariantID int
+	Err       error
+}
+
+// CartError : Every item of a cart checkout can't take
+//
+// errors.Is matches it against the kind of any of its items.
+type CartError struct {
+	Items []ItemError
+}
+
+func (e *CartError) Error() string {
+	msgs := make([]string, len(e.Items))
+	for i, item := range e.Items {
+		msgs[i] = item.Err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of the items.
+func (e *CartError) Unwrap() []error {
+	errs := make([]error, len(e.Items))
+	for i, item := range e.Items {
+		errs[i] = item.Err
+	}
+	return errs
+}
@@ -1,57 +1,654 @@
 // Package types contains the types used in the application.
 package types
 
-import "time"
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
 
 // UserStore : User store interface
 type UserStore interface {
-	GetUserByEmail(email string) (*User, error)
-	GetUserByID(id int) (*User, error)
-	CreateUser(u User) error
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUserByID(ctx context.Context, id int) (*User, error)
+	CreateUser(ctx context.Context, u User) error
 }
 
 // ProductStore : Product store interface
 type ProductStore interface {
-	GetProducts() ([]Product, error)
-	GetProductsByID(ids []int) ([]Product, error)
-	UpdateProduct(Product) error
+	GetProducts(ctx context.Context) ([]Product, error)
+	// StreamProducts calls fn for every product filter matches, in ID
+	// order.
+	StreamProducts(ctx context.Context, filter ProductFilter, fn func(Product) error) error
+	GetProductsByID(ctx context.Context, ids []int) ([]Product, error)
+	LookupProducts(ctx context.Context, ids []int) (ProductLookup, error)
+	CreateProduct(ctx context.Context, p Product) (int, error)
+	UpdateProduct(ctx context.Context, p Product) error
+}
+
+// VariantStore : Product variant store interface
+type VariantStore interface {
+	// CreateVariant adds a variant to a product, creating the options and
+	// values it names. Every variant of a product has a value of the same
+	// options, and no two have the same values.
+	CreateVariant(ctx context.Context, v Variant) (int, error)
+	// UpdateVariant updates the SKU, price, stock and image of a variant.
+	UpdateVariant(ctx context.Context, v Variant) error
+	// GetVariantsByProduct gets the variants of products, sorted by ID.
+	GetVariantsByProduct(ctx context.Context, productIDs []int) ([]Variant, error)
+	// GetProductOptions gets the options of a product and their values,
+	// in the order they were created.
+	GetProductOptions(ctx context.Context, productID int) ([]ProductOption, error)
 }
 
 // OrderStore : Order store interface
 type OrderStore interface {
-	CreateOrder(o Order) (int, error)
-	CreateOrderItem(oi OrderItem) error
+	CreateOrder(ctx context.Context, o Order) (int, error)
+	CreateOrderItem(ctx context.Context, oi OrderItem) error
+	StreamOrdersByUser(ctx context.Context, userID int, fn func(Order) error) error
+	// UpdateOrderStatus sets the status of an order. An order that fails
+	// gives back the uses of its promotions.
+	UpdateOrderStatus(ctx context.Context, id int, status string) error
+	GetOrderByID(ctx context.Context, id int) (*Order, error)
+	GetOrderItems(ctx context.Context, orderID int) ([]OrderItem, error)
+	GetOrderDiscounts(ctx context.Context, orderID int) ([]OrderDiscount, error)
+}
+
+// PaymentStore : Payment store interface
+type PaymentStore interface {
+	CreatePayment(ctx context.Context, p Payment) (int, error)
+	GetPaymentByReference(ctx context.Context, provider, reference string) (*Payment, error)
+	UpdatePaymentStatus(ctx context.Context, id int, status string) error
+	GetPaymentsByOrder(ctx context.Context, orderID int) ([]Payment, error)
+}
+
+// ReturnStore : Return (RMA) store interface
+type ReturnStore interface {
+	// CreateReturn creates a return with its items, checked with
+	// CheckReturnItems against the order's items and its other returns
+	// that weren't rejected, at once so concurrent returns can't send
+	// back more than was ordered.
+	CreateReturn(ctx context.Context, r Return) (int, error)
+	GetReturn(ctx context.Context, id int) (*Return, error)
+	ListReturns(ctx context.Context, filter ReturnFilter) ([]Return, error)
+	// SetReturnStatus moves a return from one status to another, failing
+	// with ErrConflict when it's no longer in status from.
+	SetReturnStatus(ctx context.Context, id int, from, to string) error
+	// ClaimRefund approves a requested return and adds its refund to the
+	// payment's refunded amount at once, failing with ErrConflict when the
+	// return is no longer requested or the payment has less than refund
+	// left. It returns the payment's refunded amount, refund included.
+	ClaimRefund(ctx context.Context, returnID, paymentID int, refund float64) (float64, error)
+	// ReleaseRefund undoes ClaimRefund when the refund fails.
+	ReleaseRefund(ctx context.Context, returnID, paymentID int, refund float64) error
+	// ApproveReturn records what a claimed return's refund changed:
+	// restocks its items and updates its payment and order at once.
+	ApproveReturn(ctx context.Context, a ReturnApproval) error
+	AddReturnEvent(ctx context.Context, e ReturnEvent) error
+}
+
+// PromotionStore : Promotion store interface
+type PromotionStore interface {
+	CreatePromotion(ctx context.Context, p Promotion) (int, error)
+	GetPromotionByCode(ctx context.Context, code string) (*Promotion, error)
+	// GetAutomaticPromotions returns the promotions without a code, which
+	// apply to every cart that qualifies.
+	GetAutomaticPromotions(ctx context.Context) ([]Promotion, error)
+	ListPromotions(ctx context.Context) ([]Promotion, error)
+	// CountUserRedemptions counts the orders of a user that used a
+	// promotion, leaving out failed orders.
+	CountUserRedemptions(ctx context.Context, promotionID, userID int) (int, error)
+}
+
+// InventoryStore : Stock reservation store interface
+//
+// Checkout reserves the stock of an order while it's being paid for.
+// Committing the reservations takes the stock off the products; releasing
+// them gives it back to other orders.
+type InventoryStore interface {
+	// ReserveStock holds the items of an order until expiresAt. It fails
+	// with ErrOutOfStock, reserving nothing, when the available stock of
+	// an item is short.
+	ReserveStock(ctx context.Context, orderID int, items []CartItem, expiresAt time.Time) error
+	// CommitReservations takes the active reservations of an order off
+	// the stock of its products. It fails with ErrConflict when they
+	// have all been released, and does nothing for orders placed without
+	// reservations.
+	CommitReservations(ctx context.Context, orderID int) error
+	// CommitPaidOrder records the capture of an order's payment in one
+	// transaction: it marks the payment captured, commits the order's
+	// reservations and marks the order paid. It fails like
+	// CommitReservations, changing nothing.
+	CommitPaidOrder(ctx context.Context, orderID, paymentID int) error
+	// ReleaseReservations releases the active reservations of an order.
+	ReleaseReservations(ctx context.Context, orderID int) error
+	// ReleaseExpiredReservations releases the active reservations that
+	// expired by now and returns the IDs of their orders.
+	ReleaseExpiredReservations(ctx context.Context, now time.Time) ([]int, error)
+	GetReservations(ctx context.Context, orderID int) ([]Reservation, error)
+}
+
+// CategoryStore : Category and tag store interface
+type CategoryStore interface {
+	// CreateCategory creates a category under its parent, if it has one.
+	// Slugs are unique.
+	CreateCategory(ctx context.Context, c Category) (int, error)
+	// UpdateCategory updates a category, refusing to move it under itself
+	// or one of its descendants.
+	UpdateCategory(ctx context.Context, c Category) error
+	// DeleteCategory deletes a category without children and unlinks its
+	// products.
+	DeleteCategory(ctx context.Context, id int) error
+	// ListCategories lists every category by sort order, then name.
+	ListCategories(ctx context.Context) ([]Category, error)
+	CreateTag(ctx context.Context, name string) (int, error)
+	// RenameTag renames a tag; names are unique.
+	RenameTag(ctx context.Context, id int, name string) error
+	// DeleteTag deletes a tag and unlinks its products.
+	DeleteTag(ctx context.Context, id int) error
+	// ListTags lists every tag by name.
+	ListTags(ctx context.Context) ([]Tag, error)
+	// SetProductCategories replaces the categories of a product.
+	SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error
+	// SetProductTags replaces the tags of a product, creating the tags it
+	// names that don't exist yet.
+	SetProductTags(ctx context.Context, productID int, tags []string) error
+	// GetProductCategories gets the categories of a product, sorted like
+	// ListCategories.
+	GetProductCategories(ctx context.Context, productID int) ([]Category, error)
+	// GetProductTags gets the names of the tags of a product, sorted.
+	GetProductTags(ctx context.Context, productID int) ([]string, error)
+	// GetCategoryIDsByProduct maps products to the IDs of their
+	// categories, leaving out products without any.
+	GetCategoryIDsByProduct(ctx context.Context, productIDs []int) (map[int][]int, error)
+}
+
+// ShippingStore : Shipping method store interface
+type ShippingStore interface {
+	CreateShippingMethod(ctx context.Context, m ShippingMethod) (int, error)
+	GetShippingMethodByID(ctx context.Context, id int) (*ShippingMethod, error)
+	ListShippingMethods(ctx context.Context) ([]ShippingMethod, error)
+}
+
+// TaxCalculator : Works out the sales tax of an order
+type TaxCalculator interface {
+	// Calculate fills in the rate and tax of lines, which carry the
+	// product and its taxable amount, for a delivery to address. The
+	// returned Tax has one line per input line, in order.
+	Calculate(ctx context.Context, lines []TaxLine, address Address) (Tax, error)
+}
+
+// PaymentProvider : Payment gateway that moves the money of an order
+//
+// Authorize reserves the amount on the customer's payment method, Capture
+// collects an authorized amount, Void releases an authorization that
+// won't be captured and Refund returns captured money. Authorizations the
+// customer has to confirm, e.g. with 3-D Secure, end in a webhook.
+type PaymentProvider interface {
+	// Name identifies the provider in the payments table and in webhooks.
+	Name() string
+
+	Authorize(ctx context.Context, req PaymentRequest) (PaymentResult, error)
+	Capture(ctx context.Context, reference string) (PaymentResult, error)
+	Void(ctx context.Context, reference string) (PaymentResult, error)
+	Refund(ctx context.Context, reference string, amount float64) (PaymentResult, error)
 }
 
+// Order statuses
+const (
+	OrderPending           = "pending"
+	OrderPaid              = "paid"
+	OrderFailed            = "failed"
+	OrderPartiallyRefunded = "partially_refunded"
+	OrderRefunded          = "refunded"
+	OrderCompleted         = "completed"
+	OrderCancelled         = "cancelled"
+)
+
+// Payment statuses
+const (
+	PaymentAuthorized     = "authorized"
+	PaymentRequiresAction = "requires_action"
+	PaymentCaptured       = "captured"
+	PaymentVoided         = "voided"
+	PaymentRefunded       = "refunded"
+	PaymentFailed         = "failed"
+)
+
 // Order : Order type
 type Order struct {
+	ID      int     `json:"id"`
+	UserID  int     `json:"userId"`
+	Total   float64 `json:"total"`
+	Status  string  `json:"status"`
+	Address string  `json:"address"`
+	// TaxInclusive is set when the prices of the order's items include
+	// their tax.
+	TaxInclusive bool `json:"taxInclusive"`
+	// ShippingMethodID is zero for orders placed before shipping methods
+	// existed. ShippingMethod keeps the method's name as it was ordered.
+	ShippingMethodID int       `json:"shippingMethodId"`
+	ShippingMethod   string    `json:"shippingMethod"`
+	ShippingCost     float64   `json:"shippingCost"`
+	CreatedAt        time.Time `json:"createdAt"`
+	// Discounts are recorded by CreateOrder, which also counts the use of
+	// their promotions; the order getters leave them out.
+	Discounts []OrderDiscount `json:"discounts,omitempty"`
+}
+
+// OrderDiscount : Discount line of an order
+type OrderDiscount struct {
+	ID          int     `json:"id"`
+	OrderID     int     `json:"orderId"`
+	PromotionID int     `json:"promotionId"`
+	Name        string  `json:"name"`
+	Code        string  `json:"code,omitempty"`
+	Amount      float64 `json:"amount"`
+}
+
+// Promotion kinds
+const (
+	PromotionPercent = "percent"
+	PromotionFixed   = "fixed"
+)
+
+// Promotion : Discount on carts, by coupon code or automatic
+type Promotion struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Code is what customers enter at checkout. Promotions without a
+	// code apply automatically.
+	Code string `json:"code,omitempty"`
+	Kind string `json:"kind"`
+	// Value is a percentage off for percent promotions and an amount off
+	// for fixed ones.
+	Value    float64 `json:"value"`
+	MinSpend float64 `json:"minSpend"`
+	// MaxUses and MaxUsesPerUser limit the orders using the promotion;
+	// zero means no limit.
+	MaxUses        int `json:"maxUses"`
+	MaxUsesPerUser int `json:"maxUsesPerUser"`
+	Uses           int `json:"uses"`
+	// Stackable promotions combine with each other; the others apply
+	// alone.
+	Stackable bool       `json:"stackable"`
+	StartsAt  *time.Time `json:"startsAt,omitempty"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"`
+	// ProductIDs and CategoryIDs limit the discount to these products and
+	// the products in these categories or their subcategories; both empty
+	// means the whole cart.
+	ProductIDs  []int     `json:"productIds"`
+	CategoryIDs []int     `json:"categoryIds"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Shipping method kinds
+const (
+	ShippingFlat   = "flat"
+	ShippingWeight = "weight"
+	ShippingPickup = "pickup"
+)
+
+// ShippingMethod : Way of delivering orders and what it costs
+type ShippingMethod struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Price is the cost of a flat rate method and the base cost of a
+	// weight-based one, which adds PricePerKg for every kilogram.
+	// Pickups are free.
+	Price      float64 `json:"price"`
+	PricePerKg float64 `json:"pricePerKg"`
+	// FreeOver makes the method free for carts of at least that
+	// subtotal; zero means never.
+	FreeOver float64 `json:"freeOver"`
+	// MaxWeight is the heaviest cart, in kilograms, the method takes;
+	// zero means no limit.
+	MaxWeight float64 `json:"maxWeight"`
+	// Countries lists the ISO 3166-1 alpha-2 codes the method delivers
+	// to; empty means everywhere.
+	Countries []string  `json:"countries"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Reservation statuses
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+)
+
+// Reservation : Stock held for an order until it's paid for or expires
+type Reservation struct {
+	ID        int `json:"id"`
+	OrderID   int `json:"orderId"`
+	ProductID int `json:"productId"`
+	// VariantID is set when the stock held is a variant's.
+	VariantID int       `json:"variantId,omitempty"`
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ShippingOption : Shipping method available to a cart, with its cost
+type ShippingOption struct {
+	MethodID int     `json:"methodId"`
+	Name     string  `json:"name"`
+	Kind     string  `json:"kind"`
+	Cost     float64 `json:"cost"`
+}
+
+// Payment : Payment of an order through a provider
+type Payment struct {
 	ID        int       `json:"id"`
-	UserID    int       `json:"userId"`
-	Total     float64   `json:"total"`
+	OrderID   int       `json:"orderId"`
+	Provider  string    `json:"provider"`
+	Reference string    `json:"reference"`
 	Status    string    `json:"status"`
-	Address   string    `json:"address"`
+	Amount    float64   `json:"amount"`
+	Refunded  float64   `json:"refunded"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// PaymentRequest : Amount to authorize on a payment method
+type PaymentRequest struct {
+	Amount float64
+	// Method is the provider's token for the customer's card.
+	Method string
+}
+
+// PaymentResult : Provider's answer to a payment operation
+type PaymentResult struct {
+	Reference string
+	Status    string
+	// NextActionURL is where the customer confirms a payment that
+	// requires action.
+	NextActionURL string
+}
+
+// PaymentEvent : Webhook a provider sends when a payment settles
+type PaymentEvent struct {
+	ID        string `json:"id" validate:"required"`
+	Type      string `json:"type" validate:"required,oneof=payment.captured payment.failed"`
+	Provider  string `json:"provider" validate:"required"`
+	Reference string `json:"reference" validate:"required"`
+}
+
+// Payment event types
+const (
+	PaymentEventCaptured = "payment.captured"
+	PaymentEventFailed   = "payment.failed"
+)
+
+// Return statuses
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+)
+
+// Return : Customer's request to send back items of an order
+type Return struct {
+	ID      int     `json:"id"`
+	OrderID int     `json:"orderId"`
+	UserID  int     `json:"userId"`
+	Status  string  `json:"status"`
+	Reason  string  `json:"reason"`
+	Refund  float64 `json:"refund"`
+	// Items and Events are filled by GetReturn; ListReturns leaves out
+	// the events.
+	Items     []ReturnItem  `json:"items"`
+	Events    []ReturnEvent `json:"events,omitempty"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// ReturnItem : Quantity of an order item sent back
+type ReturnItem struct {
+	ID          int `json:"id"`
+	ReturnID    int `json:"returnId"`
+	OrderItemID int `json:"orderItemId"`
+	Quantity    int `json:"quantity"`
+}
+
+// CheckReturnItems checks that items can be sent back from an order, left
+// holding how many of each of its order items, by ID, aren't returned yet.
+// It fails with ErrValidation for items of other orders and for more than
+// is left.
+func CheckReturnItems(orderID int, left map[int]int, items []ReturnItem) error {
+	asked := map[int]int{}
+	for _, item := range items {
+		n, ok := left[item.OrderItemID]
+		if !ok {
+			return Errorf(ErrValidation, "order item %d is not part of order %d", item.OrderItemID, orderID)
+		}
+		n -= asked[item.OrderItemID]
+		if item.Quantity > n {
+			return Errorf(ErrValidation, "only %d of order item %d can be returned", max(n, 0), item.OrderItemID)
+		}
+		asked[item.OrderItemID] += item.Quantity
+	}
+	return nil
+}
+
+// ReturnEvent : Audit entry of a step of a return
+type ReturnEvent struct {
+	ID       int `json:"id"`
+	ReturnID int `json:"returnId"`
+	// ActorID is the user who took the step.
+	ActorID   int       `json:"actorId"`
+	Action    string    `json:"action"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Return event actions
+const (
+	ReturnEventRequested = "requested"
+	ReturnEventApproved  = "approved"
+	ReturnEventRejected  = "rejected"
+	ReturnEventRestocked = "restocked"
+	ReturnEventRefunded  = "refunded"
+	ReturnEventOrder     = "order_updated"
+)
+
+// ReturnApproval : What approving a refunded return changes
+type ReturnApproval struct {
+	ReturnID int
+	// PaymentID is the payment refunded, which moves to PaymentStatus.
+	PaymentID     int
+	PaymentStatus string
+	// Restock lists the quantities going back into stock.
+	Restock     []CartItem
+	OrderID     int
+	OrderStatus string
+	Events      []ReturnEvent
+}
+
+// ReturnFilter : Returns to list; zero fields match every return
+type ReturnFilter struct {
+	UserID  int
+	OrderID int
+	Status  string
+}
+
 // OrderItem : Order item type
 type OrderItem struct {
 	ID        int       `json:"id"`
 	OrderID   int       `json:"orderId"`
 	ProductID int       `json:"productId"`
+	VariantID int       `json:"variantId,omitempty"`
 	Quantity  int       `json:"quantity"`
 	Price     float64   `json:"price"`
+	TaxRate   float64   `json:"taxRate"`
+	Tax       float64   `json:"tax"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// Address : Postal address
+type Address struct {
+	Line1      string `json:"line1" validate:"required,max=255"`
+	Line2      string `json:"line2" validate:"max=255"`
+	City       string `json:"city" validate:"required,max=100"`
+	Region     string `json:"region" validate:"max=100"`
+	PostalCode string `json:"postalCode" validate:"max=20"`
+	// Country is an ISO 3166-1 alpha-2 code, e.g. US.
+	Country string `json:"country" validate:"required,len=2,alpha"`
+}
+
+// String formats the address on one line.
+func (a Address) String() string {
+	var parts []string
+	for _, p := range []string{a.Line1, a.Line2, a.City, strings.TrimSpace(a.Region + " " + a.PostalCode), a.Country} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// TaxLine : Tax on a line of an order
+type TaxLine struct {
+	ProductID int `json:"productId"`
+	// Taxable is the line's price after discounts.
+	Taxable float64 `json:"taxable"`
+	Rate    float64 `json:"rate"`
+	Tax     float64 `json:"tax"`
+}
+
+// Tax : Tax breakdown of an order
+type Tax struct {
+	// Inclusive is set when the prices include the tax; otherwise it's
+	// added to the total.
+	Inclusive bool      `json:"inclusive"`
+	Total     float64   `json:"total"`
+	Lines     []TaxLine `json:"lines"`
+}
+
 // Product : Product type
 type Product struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Image       string    `json:"image"`
-	Price       float64   `json:"price"`
-	Quantity    int       `json:"quantity"` // Good enough for now but in a real-world scenario, this should be atomic.
-	CreatedAt   time.Time `json:"createdAt"`
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Image       string  `json:"image"`
+	Price       float64 `json:"price"`
+	Quantity    int     `json:"quantity"` // Good enough for now but in a real-world scenario, this should be atomic.
+	// Available is the stock left for new orders: Quantity minus the
+	// active reservations. The stores fill it in; writes ignore it.
+	Available int `json:"available"`
+	// Weight is in kilograms and the dimensions in centimeters; they
+	// price weight-based shipping.
+	Weight    float64   `json:"weight"`
+	Length    float64   `json:"length"`
+	Width     float64   `json:"width"`
+	Height    float64   `json:"height"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ProductOption : Dimension a product's variants differ in, e.g. size
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// VariantOption : Value of an option a variant has
+type VariantOption struct {
+	Name  string `json:"name" validate:"required,max=50"`
+	Value string `json:"value" validate:"required,max=100"`
+}
+
+// Variant : Version of a product sold under its own SKU, e.g. a size and
+// color of a shirt
+type Variant struct {
+	ID        int    `json:"id"`
+	ProductID int    `json:"productId"`
+	SKU       string `json:"sku"`
+	// Price overrides the product's price when set.
+	Price    *float64 `json:"price"`
+	Quantity int      `json:"quantity"`
+	// Available is the stock left for new orders, like Product's.
+	Available int    `json:"available"`
+	Image     string `json:"image"`
+	// Options has the variant's value of each of the product's options,
+	// in the options' order.
+	Options   []VariantOption `json:"options"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// PriceOf returns what the variant sells for, p being its product.
+func (v Variant) PriceOf(p Product) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return p.Price
+}
+
+// ProductDetail : Product with its option matrix and taxonomy
+type ProductDetail struct {
+	Product
+	Options    []ProductOption `json:"options"`
+	Variants   []Variant       `json:"variants"`
+	Categories []Category      `json:"categories"`
+	Tags       []string        `json:"tags"`
+}
+
+// ProductFilter : Products to list; zero fields match every product
+type ProductFilter struct {
+	// CategoryIDs matches the products in any of the categories.
+	CategoryIDs []int
+	// Tags matches the products with all of the tags.
+	Tags []string
+}
+
+// Category : Group of products in the catalog's hierarchy
+type Category struct {
+	ID int `json:"id"`
+	// ParentID is nil for top-level categories.
+	ParentID  *int      `json:"parentId"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	SortOrder int       `json:"sortOrder"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CategoryNode : Category with its subcategories, as listed by the tree
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// Tag : Free-form label of products
+type Tag struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ProductLookup : Products found by a batch lookup, and the IDs that weren't
+type ProductLookup struct {
+	// Products are sorted by ID, once each.
+	Products []Product `json:"products"`
+	Missing  []int     `json:"missing"`
+}
+
+// NewProductLookup reports the products found for ids, and which of the ids
+// are missing from them, sorted and once each.
+func NewProductLookup(ids []int, products []Product) ProductLookup {
+	found := make(map[int]bool, len(products))
+	for _, p := range products {
+		found[p.ID] = true
+	}
+
+	missing := []int{}
+	for _, id := range ids {
+		if !found[id] {
+			found[id] = true
+			missing = append(missing, id)
+		}
+	}
+	slices.Sort(missing)
+	return ProductLookup{Products: products, Missing: missing}
 }
 
 // User : User type
@@ -78,13 +675,179 @@ type LoginUserPayload struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// LoginResponse : Login response
+type LoginResponse struct {
+	Token string `json:"token"`
+}
+
+// MessageResponse : Response carrying a status message
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
 // CartItem : Cart item type
 type CartItem struct {
-	ProductID int `json:"productId"`
-	Quantity  int `json:"quantity"`
+	ProductID int `json:"productId" validate:"required,gt=0"`
+	// VariantID is required for products sold in variants.
+	VariantID int `json:"variantId,omitempty" validate:"omitempty,gt=0"`
+	Quantity  int `json:"quantity" validate:"required,gt=0"`
 }
 
 // CartCheckoutPayload : Cart checkout payload
 type CartCheckoutPayload struct {
-	Items []CartItem `json:"items" validate:"required"`
+	// Items of the same product are merged into one line.
+	Items []CartItem `json:"items" validate:"required,min=1,dive"`
+	// PaymentMethod is the payment provider's token for the card to
+	// charge; the fake provider takes its test card numbers.
+	PaymentMethod string `json:"paymentMethod" validate:"required"`
+	CouponCode    string `json:"couponCode" validate:"max=50"`
+	// ShippingAddress sets the tax of the order and the shipping
+	// methods it can choose from.
+	ShippingAddress  Address `json:"shippingAddress" validate:"required"`
+	ShippingMethodID int     `json:"shippingMethodId" validate:"required,gt=0"`
+}
+
+// ShippingOptionsQuery : Destination of a shipping quote
+type ShippingOptionsQuery struct {
+	Country    string `json:"country" validate:"required,len=2,alpha"`
+	Region     string `json:"region" validate:"max=100"`
+	PostalCode string `json:"postalCode" validate:"max=20"`
+}
+
+// CreatePromotionPayload : Create promotion payload
+type CreatePromotionPayload struct {
+	Name           string     `json:"name" validate:"required,max=255"`
+	Code           string     `json:"code" validate:"max=50"`
+	Kind           string     `json:"kind" validate:"required,oneof=percent fixed"`
+	Value          float64    `json:"value" validate:"required,gt=0"`
+	MinSpend       float64    `json:"minSpend" validate:"gte=0"`
+	MaxUses        int        `json:"maxUses" validate:"gte=0"`
+	MaxUsesPerUser int        `json:"maxUsesPerUser" validate:"gte=0"`
+	Stackable      bool       `json:"stackable"`
+	StartsAt       *time.Time `json:"startsAt"`
+	EndsAt         *time.Time `json:"endsAt"`
+	ProductIDs     []int      `json:"productIds" validate:"dive,gt=0"`
+	CategoryIDs    []int      `json:"categoryIds" validate:"dive,gt=0"`
+}
+
+// CreateVariantPayload : Create product variant payload
+type CreateVariantPayload struct {
+	SKU      string          `json:"sku" validate:"required,max=64"`
+	Price    *float64        `json:"price" validate:"omitempty,gt=0"`
+	Quantity int             `json:"quantity" validate:"gte=0"`
+	Image    string          `json:"image" validate:"max=255"`
+	Options  []VariantOption `json:"options" validate:"required,min=1,dive"`
+}
+
+// UpdateVariantPayload : Update product variant payload
+type UpdateVariantPayload struct {
+	SKU      string   `json:"sku" validate:"required,max=64"`
+	Price    *float64 `json:"price" validate:"omitempty,gt=0"`
+	Quantity int      `json:"quantity" validate:"gte=0"`
+	Image    string   `json:"image" validate:"max=255"`
+}
+
+// CreateShippingMethodPayload : Create shipping method payload
+type CreateShippingMethodPayload struct {
+	Name       string   `json:"name" validate:"required,max=255"`
+	Kind       string   `json:"kind" validate:"required,oneof=flat weight pickup"`
+	Price      float64  `json:"price" validate:"gte=0"`
+	PricePerKg float64  `json:"pricePerKg" validate:"gte=0"`
+	FreeOver   float64  `json:"freeOver" validate:"gte=0"`
+	MaxWeight  float64  `json:"maxWeight" validate:"gte=0"`
+	Countries  []string `json:"countries" validate:"dive,len=2,alpha"`
+}
+
+// CategoryPayload : Create or update category payload
+type CategoryPayload struct {
+	ParentID  *int   `json:"parentId" validate:"omitempty,gt=0"`
+	Name      string `json:"name" validate:"required,max=100"`
+	Slug      string `json:"slug" validate:"required,max=100"`
+	SortOrder int    `json:"sortOrder"`
+}
+
+// TagPayload : Create or rename tag payload
+type TagPayload struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+// ProductCategoriesPayload : Categories of a product
+type ProductCategoriesPayload struct {
+	CategoryIDs []int `json:"categoryIds" validate:"dive,gt=0"`
+}
+
+// ProductTagsPayload : Tags of a product
+type ProductTagsPayload struct {
+	Tags []string `json:"tags" validate:"dive,required,max=50"`
+}
+
+// ReturnItemPayload : Order item and quantity to send back
+type ReturnItemPayload struct {
+	OrderItemID int `json:"orderItemId" validate:"required,gt=0"`
+	Quantity    int `json:"quantity" validate:"required,gt=0"`
+}
+
+// CreateReturnPayload : Return request payload
+type CreateReturnPayload struct {
+	Items  []ReturnItemPayload `json:"items" validate:"required,min=1,dive"`
+	Reason string              `json:"reason" validate:"required,max=1000"`
+}
+
+// ReviewReturnPayload : Admin's note on approving or rejecting a return
+type ReviewReturnPayload struct {
+	Note string `json:"note" validate:"max=1000"`
+}
+
+// CheckoutResponse : Cart checkout response
+type CheckoutResponse struct {
+	TotalPrice float64 `json:"total_price"`
+	OrderID    int     `json:"order_id"`
+	Status     string  `json:"status"`
+	// NextActionURL is where the customer confirms the payment of a
+	// pending order, e.g. with 3-D Secure.
+	NextActionURL string `json:"next_action_url,omitempty"`
+	// Discounts lists the promotions taken off TotalPrice.
+	Discounts []OrderDiscount `json:"discounts,omitempty"`
+	Tax       Tax             `json:"tax"`
+	Shipping  ShippingOption  `json:"shipping"`
+}
+
+// CartQuote : What checking out a cart would cost, without placing an order
+type CartQuote struct {
+	// Lines has a line per product; lines of the same product are merged
+	// as checkout merges them.
+	Lines     []QuoteLine     `json:"lines"`
+	Subtotal  float64         `json:"subtotal"`
+	Discounts []OrderDiscount `json:"discounts"`
+	Discount  float64         `json:"discount"`
+	Shipping  ShippingOption  `json:"shipping"`
+	Tax       Tax             `json:"tax"`
+	Total     float64         `json:"total"`
+	// CanCheckout reports whether checkout would take every line as it is.
+	CanCheckout bool `json:"canCheckout"`
+}
+
+// QuoteLine : Price of a cart item, and why checkout would refuse it
+type QuoteLine struct {
+	ProductID int     `json:"productId"`
+	VariantID int     `json:"variantId,omitempty"`
+	Name      string  `json:"name"`
+	SKU       string  `json:"sku,omitempty"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+	// Total is Price times Quantity, before discounts and tax.
+	Total     float64 `json:"total"`
+	TaxRate   float64 `json:"taxRate"`
+	Tax       float64 `json:"tax"`
+	Available int     `json:"available"`
+	// Problem is set on lines checkout would refuse. Lines of unknown
+	// products aren't priced.
+	Problem *QuoteProblem `json:"problem,omitempty"`
+}
+
+// QuoteProblem : Error checkout would fail with because of a cart line
+type QuoteProblem struct {
+	// Code is the problem code of the error, e.g. out_of_stock.
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
 }
@@ -1,7 +1,171 @@
 // Package validator : Validator package
 package validator
 
-import "github.com/go-playground/validator/v10"
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/davidado/go-api-reference/types"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	esTranslations "github.com/go-playground/validator/v10/translations/es"
+	frTranslations "github.com/go-playground/validator/v10/translations/fr"
+	"golang.org/x/text/language"
+)
 
 // Validate : Validator instance
-var Validate = validator.New()
+var Validate = newValidate()
+
+var translators = newTranslators()
+
+// languages are the languages messages are translated to. The first is
+// the fallback.
+var languages = language.NewMatcher([]language.Tag{language.English, language.Spanish, language.French})
+
+// FieldError : A single field that failed validation
+type FieldError struct {
+	Field       string `json:"field"`
+	JSONPointer string `json:"jsonPointer"`
+	Rule        string `json:"rule"`
+	Param       string `json:"param,omitempty"`
+	Message     string `json:"message"`
+}
+
+// ValidationError : Validation failure listing every invalid field
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Fields) == 1 {
+		return "1 field failed validation"
+	}
+	return fmt.Sprintf("%d fields failed validation", len(e.Fields))
+}
+
+// Unwrap lets errors.Is match the error against types.ErrValidation.
+func (e *ValidationError) Unwrap() error {
+	return types.ErrValidation
+}
+
+// Struct validates s and returns a *ValidationError describing every
+// invalid field. Messages are translated to the supported language that
+// best matches acceptLanguage (an Accept-Language header value), weighing
+// its q-values, and fall back to English.
+func Struct(s any, acceptLanguage string) error {
+	err := Validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	verrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	trans, _ := translators.GetTranslator(locale(acceptLanguage))
+
+	fields := make([]FieldError, len(verrs))
+	for i, fe := range verrs {
+		path := fieldPath(fe.Namespace())
+		fields[i] = FieldError{
+			Field:       path,
+			JSONPointer: jsonPointer(path),
+			Rule:        fe.Tag(),
+			Param:       fe.Param(),
+			Message:     fe.Translate(trans),
+		}
+	}
+
+	return &ValidationError{Fields: fields}
+}
+
+func newValidate() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their JSON names so clients can map errors back to
+	// the payload they sent.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	return v
+}
+
+func newTranslators() *ut.UniversalTranslator {
+	enLocale := en.New()
+	uni := ut.New(enLocale, enLocale, es.New(), fr.New())
+
+	register := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": enTranslations.RegisterDefaultTranslations,
+		"es": esTranslations.RegisterDefaultTranslations,
+		"fr": frTranslations.RegisterDefaultTranslations,
+	}
+	for locale, fn := range register {
+		trans, _ := uni.GetTranslator(locale)
+		if err := fn(Validate, trans); err != nil {
+			panic(fmt.Sprintf("validator: register %s translations: %v", locale, err))
+		}
+	}
+
+	return uni
+}
+
+// locale returns the base language of the supported language that best
+// matches an Accept-Language header, e.g. "fr" for "de, fr;q=0.8", or
+// "en" when none does.
+func locale(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return "en"
+	}
+	tag, _, confidence := languages.Match(tags...)
+	if confidence == language.No {
+		return "en"
+	}
+	base, _ := tag.Base()
+	return base.String()
+}
+
+// fieldPath strips the root struct name from a validator namespace, e.g.
+// "CartCheckoutPayload.items[0].quantity" becomes "items[0].quantity".
+func fieldPath(namespace string) string {
+	_, path, ok := strings.Cut(namespace, ".")
+	if !ok {
+		return namespace
+	}
+	return path
+}
+
+// jsonPointer converts a field path to an RFC 6901 JSON pointer, e.g.
+// "items[0].quantity" becomes "/items/0/quantity".
+func jsonPointer(path string) string {
+	var b strings.Builder
+	for _, seg := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(seg, "[")
+		b.WriteString("/" + escapePointer(name))
+		for rest != "" {
+			var idx string
+			idx, rest, _ = strings.Cut(rest, "]")
+			rest = strings.TrimPrefix(rest, "[")
+			b.WriteString("/" + escapePointer(idx))
+		}
+	}
+	return b.String()
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,89 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/davidado/go-api-reference/types"
+)
+
+func TestStruct(t *testing.T) {
+	t.Run("should report fields by their JSON path", func(t *testing.T) {
+		payload := types.CartCheckoutPayload{
+			Items:            []types.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2}},
+			PaymentMethod:    "4242424242424242",
+			ShippingAddress:  types.Address{Line1: "1 Main St", City: "Portland", Country: "US"},
+			ShippingMethodID: 1,
+		}
+
+		err := Struct(payload, "")
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+		if !errors.Is(err, types.ErrValidation) {
+			t.Errorf("expected error to match types.ErrValidation")
+		}
+		if len(verr.Fields) != 1 {
+			t.Fatalf("expected 1 field error, got %d", len(verr.Fields))
+		}
+
+		want := FieldError{
+			Field:       "items[1].quantity",
+			JSONPointer: "/items/1/quantity",
+			Rule:        "required",
+			Message:     "quantity is a required field",
+		}
+		if verr.Fields[0] != want {
+			t.Errorf("expected %+v, got %+v", want, verr.Fields[0])
+		}
+	})
+
+	t.Run("should translate messages from Accept-Language", func(t *testing.T) {
+		payload := types.LoginUserPayload{Email: "a@b.com"}
+
+		err := Struct(payload, "es-MX,es;q=0.9,en;q=0.8")
+
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+		if got := verr.Fields[0].Message; got != "password es un campo requerido" {
+			t.Errorf("unexpected message %q", got)
+		}
+	})
+
+	t.Run("should pick the supported language with the highest weight", func(t *testing.T) {
+		payload := types.LoginUserPayload{Email: "a@b.com"}
+
+		tests := []struct {
+			header string
+			want   string
+		}{
+			{"de;q=1, fr;q=0.8", "password est un champ obligatoire"},
+			{"fr;q=0.5, es;q=0.9", "password es un campo requerido"},
+			{"fr-CA", "password est un champ obligatoire"},
+			{"es;q=0, fr;q=0.1", "password est un champ obligatoire"},
+			{"de", "password is a required field"},
+			{"not a;header", "password is a required field"},
+		}
+		for _, tt := range tests {
+			var verr *ValidationError
+			if err := Struct(payload, tt.header); !errors.As(err, &verr) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if got := verr.Fields[0].Message; got != tt.want {
+				t.Errorf("%q: expected %q, got %q", tt.header, tt.want, got)
+			}
+		}
+	})
+
+	t.Run("should pass a valid payload", func(t *testing.T) {
+		payload := types.LoginUserPayload{Email: "a@b.com", Password: "secret"}
+
+		if err := Struct(payload, "en"); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}